@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// topCommands returns the commands of the first word, with their summaries:
+// the commands without subcommands and the groups.
+func topCommands() (names, summaries []string) {
+	seen := map[string]bool{}
+	for _, c := range commands {
+		if c.hidden {
+			continue
+		}
+		name, _, _ := strings.Cut(c.name, " ")
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		summary, ok := groupSummaries[name]
+		if !ok {
+			summary = c.summary
+		}
+		names = append(names, name)
+		summaries = append(summaries, summary)
+	}
+	return names, summaries
+}
+
+// globalFlags are the flags of forro, for the completion.
+var globalFlags = []string{"-dry-run", "-config", "-json", "-v"}
+
+// completion writes the completion script of the shell, e.g. for
+// source <(forro completion bash).
+func completion(w io.Writer, shell string) error {
+	names, summaries := topCommands()
+	groups := map[string][]command{}
+	var groupNames []string
+	for _, c := range commands {
+		if group, _, ok := strings.Cut(c.name, " "); ok {
+			if _, seen := groups[group]; !seen {
+				groupNames = append(groupNames, group)
+			}
+			groups[group] = append(groups[group], c)
+		}
+	}
+
+	switch shell {
+	case "bash":
+		fmt.Fprintf(w, `_forro() {
+	local cur=${COMP_WORDS[COMP_CWORD]} words=() i
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		case ${COMP_WORDS[i]} in
+		-config) ((i++)) ;;
+		-*) ;;
+		*) words+=("${COMP_WORDS[i]}") ;;
+		esac
+	done
+	if [[ ${COMP_WORDS[COMP_CWORD-1]} == -config && ${#words[@]} -eq 0 ]]; then
+		COMPREPLY=($(compgen -f -- "$cur"))
+		return
+	fi
+	if [[ $cur == -* && ${#words[@]} -eq 0 ]]; then
+		COMPREPLY=($(compgen -W "%s" -- "$cur"))
+		return
+	fi
+	case ${#words[@]} in
+	0) COMPREPLY=($(compgen -W "%s" -- "$cur")) ;;
+	1)
+		case ${words[0]} in
+`, strings.Join(globalFlags, " "), strings.Join(names, " "))
+		for _, group := range groupNames {
+			fmt.Fprintf(w, "\t\t%s) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")) ;;\n", group, strings.Join(subcommandsOf(group), " "))
+		}
+		fmt.Fprintf(w, "\t\thelp) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")) ;;\n", strings.Join(names, " "))
+		fmt.Fprintf(w, "\t\tcompletion) COMPREPLY=($(compgen -W \"bash zsh fish\" -- \"$cur\")) ;;\n")
+		fmt.Fprintf(w, `		*) COMPREPLY=($(compgen -f -- "$cur")) ;;
+		esac
+		;;
+	*) COMPREPLY=($(compgen -f -- "$cur")) ;;
+	esac
+}
+complete -F _forro forro
+`)
+	case "zsh":
+		fmt.Fprintf(w, "#compdef forro\n\n_forro() {\n\tlocal -a commands\n\tcommands=(\n")
+		for i, name := range names {
+			fmt.Fprintf(w, "\t\t%s\n", zshItem(name, summaries[i]))
+		}
+		fmt.Fprintf(w, `	)
+	local -a words_
+	local i
+	for ((i = 2; i < CURRENT; i++)); do
+		case ${words[i]} in
+		-config) ((i++)) ;;
+		-*) ;;
+		*) words_+=(${words[i]}) ;;
+		esac
+	done
+	if [[ ${words[CURRENT-1]} == -config && ${#words_} -eq 0 ]]; then
+		_files
+		return
+	fi
+	if [[ $PREFIX == -* && ${#words_} -eq 0 ]]; then
+		compadd -- %s
+		return
+	fi
+	case ${#words_} in
+	0) _describe command commands ;;
+	1)
+		local -a subcommands
+		case ${words_[1]} in
+`, strings.Join(globalFlags, " "))
+		for _, group := range groupNames {
+			fmt.Fprintf(w, "\t\t%s) subcommands=(", group)
+			for i, c := range groups[group] {
+				if i > 0 {
+					fmt.Fprint(w, " ")
+				}
+				_, sub, _ := strings.Cut(c.name, " ")
+				fmt.Fprint(w, zshItem(sub, c.summary))
+			}
+			fmt.Fprint(w, ") ;;\n")
+		}
+		fmt.Fprintf(w, `		help) subcommands=($commands) ;;
+		completion) subcommands=(bash zsh fish) ;;
+		*) _files; return ;;
+		esac
+		_describe command subcommands
+		;;
+	*) _files ;;
+	esac
+}
+
+compdef _forro forro
+`)
+	case "fish":
+		fmt.Fprintf(w, "complete -c forro -f\n")
+		for _, flag := range globalFlags {
+			option := "-o " + strings.TrimPrefix(flag, "-")
+			if flag == "-config" {
+				option += " -r -F"
+			}
+			fmt.Fprintf(w, "complete -c forro -n __fish_use_subcommand %s\n", option)
+		}
+		for i, name := range names {
+			fmt.Fprintf(w, "complete -c forro -n __fish_use_subcommand -a %s -d %s\n", name, fishQuote(summaries[i]))
+			fmt.Fprintf(w, "complete -c forro -n '__fish_seen_subcommand_from help' -a %s -d %s\n", name, fishQuote(summaries[i]))
+		}
+		for _, group := range groupNames {
+			for _, c := range groups[group] {
+				_, sub, _ := strings.Cut(c.name, " ")
+				fmt.Fprintf(w, "complete -c forro -n '__fish_seen_subcommand_from %s' -a %s -d %s\n", group, sub, fishQuote(c.summary))
+			}
+		}
+		fmt.Fprintf(w, "complete -c forro -n '__fish_seen_subcommand_from completion' -a 'bash zsh fish'\n")
+	default:
+		return fmt.Errorf("unknown shell %q, expected bash, zsh or fish", shell)
+	}
+	return nil
+}
+
+// zshItem is a value of _describe, its colons escaped.
+func zshItem(name, summary string) string {
+	return "'" + strings.ReplaceAll(name, ":", `\:`) + ":" + strings.ReplaceAll(summary, "'", `'\''`) + "'"
+}
+
+// fishQuote quotes a fish string.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
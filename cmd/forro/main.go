@@ -0,0 +1,266 @@
+// Command forro runs the tools of the site: publishing and cancelling the
+// events, announcing them in the chats, and checking the site. Run forro help
+// for the commands.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/publish"
+	"github.com/dolanor/forrostrasbourg.fr/internal/send"
+)
+
+// How a command handles the -dry-run global flag.
+const (
+	// noDryRun commands don't change anything.
+	noDryRun = iota
+	// dryRunFlag commands have a -dry-run flag of their own.
+	dryRunFlag
+	// sendFlag commands only send with -send, given unless -dry-run.
+	sendFlag
+	// noDryRunSupport commands write their output without a dry run.
+	noDryRunSupport
+)
+
+// command is a command of forro, run by the publish or send package.
+type command struct {
+	// name is the command, e.g. "event new".
+	name    string
+	summary string
+	// pkg is "publish" or "send".
+	pkg string
+	// mode is the command of the package, e.g. "cancel", empty for its
+	// default one.
+	mode string
+	// args are given after the ones of the command line, e.g. its flags.
+	args   []string
+	dryRun int
+	// config tells if the command reads the send.yaml of -config.
+	config bool
+	hidden bool
+}
+
+var commands = []command{
+	{name: "event new", summary: "Publish an event from a template of the catalog", pkg: "publish", dryRun: dryRunFlag},
+	{name: "event cancel", summary: "Mark an event page as cancelled", pkg: "publish", mode: "cancel", dryRun: dryRunFlag},
+	{name: "event wizard", summary: "Publish an event by answering questions", pkg: "publish", mode: "wizard", dryRun: dryRunFlag, config: true},
+	{name: "event import", summary: "Create the event pages of an iCalendar file or URL", pkg: "publish", mode: "import-ics", dryRun: dryRunFlag},
+	{name: "templates", summary: "List or show the templates of the catalog", pkg: "publish", mode: "templates"},
+	{name: "digest send", summary: "Send the digest of the week to the chats", pkg: "send", dryRun: sendFlag, config: true},
+	{name: "digest draft", summary: "Print the digest of the week without sending it", pkg: "send", config: true},
+	{name: "remind", summary: "Send the reminders of the events starting soon", pkg: "send", mode: "remind", dryRun: sendFlag, config: true},
+	{name: "weather", summary: "Check the weather of the outdoor events and announce the cancellations", pkg: "send", mode: "weather-check", dryRun: sendFlag, config: true},
+	{name: "config validate", summary: "Check the channels of send.yaml and their secrets", pkg: "send", mode: "config", args: []string{"validate"}, config: true},
+	{name: "lint", summary: "Check the event pages", pkg: "publish", mode: "lint"},
+	{name: "calendar", summary: "Write the iCalendar or JSON feed of the events", pkg: "publish", mode: "calendar", dryRun: noDryRunSupport},
+	{name: "jsonld", summary: "Write the schema.org data of the events", pkg: "publish", mode: "jsonld", dryRun: noDryRunSupport},
+	{name: "map", summary: "Write the map of the venues", pkg: "publish", mode: "map", dryRun: noDryRunSupport},
+	{name: "serve", summary: "Serve the form publishing the events", pkg: "publish", mode: "serve", dryRun: dryRunFlag, config: true},
+	{name: "scheduler", summary: "Run the jobs of the schedule when they are due", pkg: "publish", mode: "scheduler", dryRun: dryRunFlag},
+	{name: "completion", summary: "Print the shell completion script: bash, zsh or fish"},
+	{name: "help", summary: "Print the help of forro or of a command"},
+	// The former scripts/publish and scripts/send, e.g. for the schedule
+	{name: "publish", pkg: "publish", hidden: true},
+	{name: "send", pkg: "send", hidden: true},
+}
+
+// groupSummaries are the summaries of the commands having subcommands.
+var groupSummaries = map[string]string{
+	"event":  "Publish, cancel or import events",
+	"digest": "Send or draft the digest of the week",
+	"config": "Check the send configuration",
+}
+
+// globals are the flags shared by every command, given before it.
+type globals struct {
+	dryRun  bool
+	config  string
+	json    bool
+	verbose bool
+}
+
+// logFlags are the global flags of the logs.
+func (g globals) logFlags() []string {
+	var flags []string
+	if g.json {
+		flags = append(flags, "-json")
+	}
+	if g.verbose {
+		flags = append(flags, "-v")
+	}
+	return flags
+}
+
+func newFlagSet(g *globals) *flag.FlagSet {
+	fs := flag.NewFlagSet("forro", flag.ExitOnError)
+	fs.BoolVar(&g.dryRun, "dry-run", false, "Only show what the command would do")
+	fs.StringVar(&g.config, "config", "", "Channels configuration of the send commands (send.yaml by default)")
+	fs.BoolVar(&g.json, "json", false, "Log as JSON")
+	fs.BoolVar(&g.verbose, "v", false, "Log the debug messages")
+	fs.Usage = func() { usage(fs) }
+	return fs
+}
+
+func usage(fs *flag.FlagSet) {
+	w := fs.Output()
+	fmt.Fprintf(w, "Usage: forro [flags] <command> [arguments]\n\nCommands:\n")
+	for _, c := range commands {
+		if !c.hidden {
+			fmt.Fprintf(w, "  %-16s %s\n", c.name, c.summary)
+		}
+	}
+	fmt.Fprintf(w, "\nFlags:\n")
+	fs.PrintDefaults()
+	fmt.Fprintf(w, "\nRun forro help <command> for the flags of a command.\n")
+}
+
+// findCommand returns the command of the arguments, and its own arguments.
+func findCommand(args []string) (command, []string, error) {
+	if len(args) == 0 {
+		return command{}, nil, errors.New("expected a command, see forro help")
+	}
+	for _, c := range commands {
+		words := strings.Fields(c.name)
+		if len(args) >= len(words) && slices.Equal(args[:len(words)], words) {
+			return c, args[len(words):], nil
+		}
+	}
+	if subcommands := subcommandsOf(args[0]); len(subcommands) > 0 {
+		return command{}, nil, fmt.Errorf("forro %s: expected a command: %s", args[0], strings.Join(subcommands, ", "))
+	}
+	return command{}, nil, fmt.Errorf("unknown command %q, see forro help", args[0])
+}
+
+// subcommandsOf returns the subcommands of the group, e.g. new, cancel… of
+// event.
+func subcommandsOf(group string) []string {
+	var subcommands []string
+	for _, c := range commands {
+		if g, sub, ok := strings.Cut(c.name, " "); ok && g == group {
+			subcommands = append(subcommands, sub)
+		}
+	}
+	return subcommands
+}
+
+// commandArgs returns the arguments of the package running the command.
+func commandArgs(g globals, c command, args []string) []string {
+	var out []string
+	if c.mode != "" {
+		out = append(out, c.mode)
+	}
+	switch {
+	case c.dryRun == dryRunFlag && g.dryRun:
+		out = append(out, "-dry-run")
+	case c.dryRun == sendFlag && !g.dryRun:
+		out = append(out, "-send")
+	}
+	if c.config && g.config != "" {
+		out = append(out, "-config", g.config)
+	}
+	out = append(out, args...)
+	return append(out, c.args...)
+}
+
+// usageName returns the forro command of the mode of the package, for the
+// usage messages of the commands.
+func usageName(pkg, mode string) string {
+	for _, c := range commands {
+		if c.pkg == pkg && c.mode == mode && !c.hidden {
+			return "forro " + strings.TrimSuffix(c.name, " "+strings.Join(c.args, " "))
+		}
+	}
+	return strings.TrimSpace("forro " + pkg + " " + mode)
+}
+
+func setupLog(g globals) {
+	level := slog.LevelInfo
+	if g.verbose {
+		level = slog.LevelDebug
+	}
+	if g.json {
+		// The log package of publish goes through it too
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+		return
+	}
+	slog.SetLogLoggerLevel(level)
+}
+
+func run(g globals, args []string, w io.Writer) error {
+	c, args, err := findCommand(args)
+	if err != nil {
+		return err
+	}
+
+	switch c.name {
+	case "help":
+		if len(args) == 0 {
+			fs := newFlagSet(&globals{})
+			fs.SetOutput(w)
+			fs.Usage()
+			return nil
+		}
+		if summary, ok := groupSummaries[args[0]]; ok && len(args) == 1 {
+			fmt.Fprintf(w, "forro %s: %s\n\nCommands:\n", args[0], summary)
+			for _, c := range commands {
+				if strings.HasPrefix(c.name, args[0]+" ") {
+					fmt.Fprintf(w, "  %-16s %s\n", c.name, c.summary)
+				}
+			}
+			return nil
+		}
+		c, rest, err := findCommand(args)
+		if err != nil {
+			return err
+		}
+		if len(rest) > 0 || c.pkg == "" {
+			fmt.Fprintf(w, "forro %s: %s\n", c.name, c.summary)
+			return nil
+		}
+		// The commands print their usage and exit
+		return run(g, append(args, "-h"), w)
+	case "completion":
+		if len(args) != 1 {
+			return errors.New("expected a shell: bash, zsh or fish")
+		}
+		return completion(w, args[0])
+	case "publish":
+		return publish.Run(args, w)
+	case "send":
+		return send.Run(args, w)
+	}
+
+	if g.dryRun && c.dryRun == noDryRunSupport {
+		return fmt.Errorf("forro %s has no dry run", c.name)
+	}
+	if c.pkg == "publish" {
+		return publish.Run(commandArgs(g, c, args), w)
+	}
+	return send.Run(commandArgs(g, c, args), w)
+}
+
+func main() {
+	var g globals
+	fs := newFlagSet(&g)
+	fs.Parse(os.Args[1:])
+	setupLog(g)
+
+	publish.UsageName = func(mode string) string { return usageName("publish", mode) }
+	send.UsageName = func(mode string) string { return usageName("send", mode) }
+	if exe, err := os.Executable(); err == nil {
+		// The scheduler runs the publish jobs with the same logs
+		publish.SelfCommand = append(append([]string{exe}, g.logFlags()...), "publish")
+	}
+
+	if err := run(g, fs.Args(), os.Stdout); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFindCommand(t *testing.T) {
+	tests := []struct {
+		args        []string
+		want        string
+		rest        []string
+		errContains string
+	}{
+		{args: []string{"event", "new", "-template", "bal"}, want: "event new", rest: []string{"-template", "bal"}},
+		{args: []string{"lint"}, want: "lint", rest: []string{}},
+		{args: []string{"config", "validate"}, want: "config validate", rest: []string{}},
+		{args: []string{"send", "remind"}, want: "send", rest: []string{"remind"}},
+		{args: []string{"event"}, errContains: "expected a command: new, cancel, wizard, import"},
+		{args: []string{"event", "delete"}, errContains: "expected a command"},
+		{args: []string{"deploy"}, errContains: `unknown command "deploy"`},
+		{args: nil, errContains: "expected a command"},
+	}
+	for _, tt := range tests {
+		c, rest, err := findCommand(tt.args)
+		if tt.errContains != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("findCommand(%q) error = %v, want %q", tt.args, err, tt.errContains)
+			}
+			continue
+		}
+		if err != nil || c.name != tt.want || !slices.Equal(rest, tt.rest) {
+			t.Errorf("findCommand(%q) = %q, %q, %v, want %q, %q", tt.args, c.name, rest, err, tt.want, tt.rest)
+		}
+	}
+}
+
+func TestCommandArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		g    globals
+		args []string
+		want []string
+	}{
+		{name: "event new", args: []string{"-template", "bal"}, want: []string{"-template", "bal"}},
+		{name: "event new", g: globals{dryRun: true}, args: []string{"-template", "bal"}, want: []string{"-dry-run", "-template", "bal"}},
+		{name: "event cancel", g: globals{dryRun: true}, args: []string{"241224-bal"}, want: []string{"cancel", "-dry-run", "241224-bal"}},
+		{name: "event import", args: []string{"agenda.ics"}, want: []string{"import-ics", "agenda.ics"}},
+		{name: "digest send", want: []string{"-send"}},
+		{name: "digest send", g: globals{dryRun: true, config: "test.yaml"}, want: []string{"-config", "test.yaml"}},
+		{name: "digest draft", want: nil},
+		{name: "remind", args: []string{"-lead", "1h"}, want: []string{"remind", "-send", "-lead", "1h"}},
+		{name: "weather", g: globals{dryRun: true}, want: []string{"weather-check"}},
+		{name: "config validate", g: globals{config: "test.yaml"}, args: []string{"-h"}, want: []string{"config", "-config", "test.yaml", "-h", "validate"}},
+		{name: "lint", g: globals{config: "test.yaml"}, want: []string{"lint"}},
+	}
+	for _, tt := range tests {
+		c, _, err := findCommand(strings.Fields(tt.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := commandArgs(tt.g, c, tt.args); !slices.Equal(got, tt.want) {
+			t.Errorf("%s %+v %q: got %q, want %q", tt.name, tt.g, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestUsageName(t *testing.T) {
+	tests := []struct {
+		pkg, mode, want string
+	}{
+		{"publish", "", "forro event new"},
+		{"publish", "import-ics", "forro event import"},
+		{"publish", "lint", "forro lint"},
+		{"send", "", "forro digest send"},
+		{"send", "weather-check", "forro weather"},
+		{"send", "config", "forro config"},
+		{"send", "other", "forro send other"},
+	}
+	for _, tt := range tests {
+		if got := usageName(tt.pkg, tt.mode); got != tt.want {
+			t.Errorf("usageName(%q, %q) = %q, want %q", tt.pkg, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	var buf bytes.Buffer
+	err := run(globals{dryRun: true}, []string{"calendar"}, &buf)
+	if err == nil || !strings.Contains(err.Error(), "no dry run") {
+		t.Errorf("calendar with -dry-run: got %v", err)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	tests := []struct {
+		shell string
+		want  []string
+	}{
+		{"bash", []string{"complete -F _forro forro", `event) COMPREPLY=($(compgen -W "new cancel wizard import" -- "$cur")) ;;`, "-dry-run -config -json -v"}},
+		{"zsh", []string{"compdef _forro forro", "'digest:Send or draft the digest of the week'", "'draft:Print the digest of the week without sending it'"}},
+		{"fish", []string{"-n '__fish_seen_subcommand_from event' -a cancel -d 'Mark an event page as cancelled'", "-o config -r -F"}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := completion(&buf, tt.shell); err != nil {
+			t.Fatalf("%s: %v", tt.shell, err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("%s completion without %q:\n%s", tt.shell, want, buf.String())
+			}
+		}
+	}
+
+	// The hidden commands aren't completed
+	names, _ := topCommands()
+	if slices.Contains(names, "publish") || slices.Contains(names, "send") {
+		t.Errorf("completion of the hidden commands: %q", names)
+	}
+
+	if err := completion(&bytes.Buffer{}, "powershell"); err == nil {
+		t.Error("Expected error but got none")
+	}
+}
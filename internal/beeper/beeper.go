@@ -0,0 +1,118 @@
+// Package beeper sends messages to chats through the Beeper Desktop API.
+package beeper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// DefaultBaseURL is where the Beeper Desktop API listens.
+const DefaultBaseURL = "http://localhost:23373"
+
+// Client sends messages with a Beeper access token.
+type Client struct {
+	BaseURL     string
+	AccessToken string
+	HTTPClient  *http.Client
+	// Retries is how many times a message is sent again after a network
+	// error or a server error (5xx).
+	Retries int
+	// Backoff is the wait before the first retry, doubled at each retry.
+	Backoff time.Duration
+}
+
+// NewClient returns a client for the local Beeper Desktop API.
+func NewClient(accessToken string) *Client {
+	return &Client{
+		BaseURL:     DefaultBaseURL,
+		AccessToken: accessToken,
+		HTTPClient:  http.DefaultClient,
+		Retries:     3,
+		Backoff:     time.Second,
+	}
+}
+
+// StatusError is the response of the API to a message it didn't accept.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status: %v: %s", e.StatusCode, e.Body)
+}
+
+// temporary tells whether sending the message again may work.
+func temporary(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+	return true
+}
+
+// SendMessage sends a text message to the chat, retrying on network and
+// server errors. A message may then be received twice if the API got it
+// but its response was lost.
+func (c *Client) SendMessage(chatID string, message string) error {
+	type Message struct {
+		Text string `json:"text"`
+	}
+
+	msg := Message{
+		Text: message,
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	backoff := c.Backoff
+	for attempt := 0; ; attempt++ {
+		err = c.send(chatID, body)
+		if err == nil || !temporary(err) || attempt >= c.Retries {
+			break
+		}
+		slog.Warn("retrying message", "chat_id", chatID, "attempt", attempt+1, "in", backoff, "error", err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	if err != nil && c.Retries > 0 && temporary(err) {
+		return fmt.Errorf("after %d retries: %w", c.Retries, err)
+	}
+	return err
+}
+
+// send posts the JSON message to the chat once.
+func (c *Client) send(chatID string, body []byte) error {
+	chatURL := fmt.Sprintf("%s/v1/chats/%s/messages", c.BaseURL, chatID)
+	req, err := http.NewRequest(http.MethodPost, chatURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.AccessToken))
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("read body: %w", err)
+		}
+		return &StatusError{StatusCode: resp.StatusCode, Body: string(b)}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package beeper
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendMessage(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		errContains string
+	}{
+		{name: "sent", status: http.StatusOK},
+		{name: "rejected", status: http.StatusUnauthorized, errContains: "unexpected status: 401"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath, gotAuth, gotText string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+				gotAuth = r.Header.Get("Authorization")
+				var msg struct {
+					Text string `json:"text"`
+				}
+				json.NewDecoder(r.Body).Decode(&msg)
+				gotText = msg.Text
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			c := NewClient("token")
+			c.BaseURL = server.URL
+
+			err := c.SendMessage("chat-1", "Bonjour")
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SendMessage() unexpected error: %v", err)
+			}
+			if gotPath != "/v1/chats/chat-1/messages" {
+				t.Errorf("path = %q", gotPath)
+			}
+			if gotAuth != "Bearer token" {
+				t.Errorf("Authorization = %q", gotAuth)
+			}
+			if gotText != "Bonjour" {
+				t.Errorf("text = %q", gotText)
+			}
+		})
+	}
+}
+
+func TestSendMessageRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantRequests int
+		errContains  string
+	}{
+		{name: "recovers", statuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK}, wantRequests: 3},
+		{name: "gives up", statuses: []int{500, 500, 500, 500, 500}, wantRequests: 4, errContains: "after 3 retries: unexpected status: 500"},
+		{name: "not retried", statuses: []int{http.StatusNotFound, http.StatusOK}, wantRequests: 1, errContains: "unexpected status: 404"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var msg struct {
+					Text string `json:"text"`
+				}
+				json.NewDecoder(r.Body).Decode(&msg)
+				if msg.Text != "Bonjour" {
+					t.Errorf("attempt %d: text = %q", requests+1, msg.Text)
+				}
+				w.WriteHeader(tt.statuses[requests])
+				requests++
+			}))
+			defer server.Close()
+
+			c := NewClient("token")
+			c.BaseURL = server.URL
+			c.Backoff = time.Millisecond
+
+			err := c.SendMessage("chat-1", "Bonjour")
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("error = %v, want error containing %q", err, tt.errContains)
+				}
+			} else if err != nil {
+				t.Errorf("SendMessage() unexpected error: %v", err)
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("%d requests, want %d", requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestSendMessageNetworkError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	c := NewClient("token")
+	c.BaseURL = server.URL
+	c.Retries = 2
+	c.Backoff = time.Millisecond
+
+	err := c.SendMessage("chat-1", "Bonjour")
+	if err == nil || !strings.Contains(err.Error(), "after 2 retries") {
+		t.Errorf("error = %v, want a network error after 2 retries", err)
+	}
+}
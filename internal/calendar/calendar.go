@@ -0,0 +1,189 @@
+// Package calendar builds the calendar feeds of the site, in iCalendar and
+// JSON, from the event pages.
+package calendar
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/event"
+	"github.com/dolanor/forrostrasbourg.fr/internal/ical"
+)
+
+const (
+	// BaseURL is where the site is published.
+	BaseURL = "https://forrostrasbourg.fr"
+	// Name of the calendar in the calendar apps.
+	Name   = "Forró Strasbourg"
+	prodID = "-//forro-strasbourg//event calendar//EN"
+	color  = "#e7ad74"
+)
+
+// UID identifies the event in the feed. It only depends on the page name,
+// so it stays the same when the event is edited.
+func UID(e event.Event) string {
+	return e.Slug() + "@forrostrasbourg.fr"
+}
+
+// URL is the page of the event on the site.
+func URL(e event.Event) string {
+	return BaseURL + "/evenements/" + e.Slug() + "/"
+}
+
+// location joins the place and the city, like the site does.
+func location(e event.Event) string {
+	switch {
+	case e.Place == "":
+		return e.City
+	case e.City == "":
+		return e.Place
+	}
+	return e.Place + ", " + e.City
+}
+
+// sorted returns the events by start date, then page name.
+func sorted(events []event.Event) []event.Event {
+	events = append([]event.Event(nil), events...)
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].StartDate.Equal(events[j].StartDate) {
+			return events[i].StartDate.Before(events[j].StartDate)
+		}
+		return events[i].Slug() < events[j].Slug()
+	})
+	return events
+}
+
+// icalEvent converts the event, with the same reminders as the feed of the
+// site.
+func icalEvent(e event.Event) ical.Event {
+	status := "CONFIRMED"
+	if e.Cancelled {
+		status = "CANCELLED"
+	}
+	return ical.Event{
+		UID:         UID(e),
+		Summary:     e.Title,
+		Description: e.Description,
+		Location:    location(e),
+		URL:         URL(e),
+		Status:      status,
+		Start:       e.StartDate,
+		End:         e.EndDate,
+		Alarms: []ical.Alarm{
+			{Description: "dernier rappel", Before: 2 * time.Hour},
+			{Description: "1 jour avant", Before: 24 * time.Hour},
+		},
+	}
+}
+
+// Build converts the events into a calendar, sorted by start date.
+func Build(events []event.Event) *ical.Calendar {
+	cal := &ical.Calendar{Name: Name}
+	for _, e := range sorted(events) {
+		cal.Events = append(cal.Events, icalEvent(e))
+	}
+	return cal
+}
+
+// WriteICS writes the iCalendar feed of the events, stamped with now.
+func WriteICS(w io.Writer, events []event.Event, now time.Time) error {
+	loc, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		return err
+	}
+	return Build(events).Encode(w, ical.Feed{
+		ProdID:          prodID,
+		Color:           color,
+		RefreshInterval: 4 * time.Hour,
+		Location:        loc,
+		Stamp:           now,
+	})
+}
+
+// jsonEvent is an event of the JSON feed.
+type jsonEvent struct {
+	UID         string    `json:"uid"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Place       string    `json:"place,omitempty"`
+	City        string    `json:"city,omitempty"`
+	URL         string    `json:"url"`
+	Status      string    `json:"status"`
+}
+
+// WriteJSON writes the events as a JSON array, with the same UIDs and
+// statuses as the iCalendar feed.
+func WriteJSON(w io.Writer, events []event.Event) error {
+	out := []jsonEvent{}
+	for _, e := range sorted(events) {
+		ie := icalEvent(e)
+		out = append(out, jsonEvent{
+			UID:         ie.UID,
+			Title:       ie.Summary,
+			Description: ie.Description,
+			Start:       ie.Start,
+			End:         ie.End,
+			Place:       e.Place,
+			City:        e.City,
+			URL:         ie.URL,
+			Status:      ie.Status,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// Verify checks a feed rendered by the site against the events: it must be
+// valid iCalendar and list every event with the same title, times and
+// status.
+func Verify(feed []byte, events []event.Event) error {
+	var errs []error
+	if err := ical.Validate(feed); err != nil {
+		errs = append(errs, err)
+	}
+
+	cal, err := ical.Parse(bytes.NewReader(feed), time.UTC)
+	if err != nil {
+		return errors.Join(append(errs, fmt.Errorf("failed to parse feed: %v", err))...)
+	}
+	rendered := map[string]ical.Event{}
+	for _, e := range cal.Events {
+		rendered[e.UID] = e
+	}
+
+	for _, want := range Build(events).Events {
+		got, ok := rendered[want.UID]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: missing from the feed", want.UID))
+			continue
+		}
+		delete(rendered, want.UID)
+
+		if got.Summary != want.Summary {
+			errs = append(errs, fmt.Errorf("%s: SUMMARY is %q, want %q", want.UID, got.Summary, want.Summary))
+		}
+		if !got.Start.Equal(want.Start) {
+			errs = append(errs, fmt.Errorf("%s: starts at %v, want %v", want.UID, got.Start, want.Start))
+		}
+		if !want.End.IsZero() && !got.End.Equal(want.End) {
+			errs = append(errs, fmt.Errorf("%s: ends at %v, want %v", want.UID, got.End, want.End))
+		}
+		if got.Status != want.Status {
+			errs = append(errs, fmt.Errorf("%s: STATUS is %q, want %q", want.UID, got.Status, want.Status))
+		}
+	}
+	for uid := range rendered {
+		errs = append(errs, fmt.Errorf("%s: in the feed but not an event", uid))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,185 @@
+package calendar
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/event"
+	"github.com/dolanor/forrostrasbourg.fr/internal/ical"
+)
+
+func testEvents(t *testing.T) []event.Event {
+	t.Helper()
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []event.Event{
+		{
+			Title:     "Festival",
+			StartDate: time.Date(2025, 1, 5, 14, 0, 0, 0, paris),
+			EndDate:   time.Date(2025, 1, 5, 23, 0, 0, 0, paris),
+			City:      "Colmar",
+			Cancelled: true,
+			Path:      "content/evenements/250105-festival.md",
+		},
+		{
+			Title:     "Bal de Noël",
+			StartDate: time.Date(2024, 12, 24, 18, 30, 0, 0, paris),
+			EndDate:   time.Date(2024, 12, 24, 23, 30, 0, 0, paris),
+			Place:     "La Kulture, 9 rue des Bateliers",
+			City:      "Strasbourg",
+			Path:      "content/evenements/241224-bal.md",
+		},
+	}
+}
+
+func TestWriteICS(t *testing.T) {
+	var out bytes.Buffer
+	err := WriteICS(&out, testEvents(t), time.Date(2024, 12, 1, 10, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("WriteICS: %v", err)
+	}
+
+	if err := ical.Validate(out.Bytes()); err != nil {
+		t.Errorf("feed is invalid: %v", err)
+	}
+
+	feed := out.String()
+	for _, want := range []string{
+		"X-WR-CALNAME:Forró Strasbourg\r\n",
+		"UID:241224-bal@forrostrasbourg.fr\r\n",
+		"DTSTAMP:20241201T100000Z\r\n",
+		"DTSTART;TZID=Europe/Paris:20241224T183000\r\n",
+		`LOCATION:La Kulture\, 9 rue des Bateliers\, Strasbourg` + "\r\n",
+		"URL;VALUE=URI:https://forrostrasbourg.fr/evenements/241224-bal/\r\n",
+		"TRIGGER;RELATED=START:-PT2H\r\n",
+		"TRIGGER;RELATED=START:-P1D\r\n",
+		"UID:250105-festival@forrostrasbourg.fr\r\n",
+		"LOCATION:Colmar\r\n",
+		"STATUS:CANCELLED\r\n",
+	} {
+		if !strings.Contains(feed, want) {
+			t.Errorf("feed doesn't contain %q:\n%s", want, feed)
+		}
+	}
+
+	// Sorted by start date
+	if strings.Index(feed, "UID:241224-bal") > strings.Index(feed, "UID:250105-festival") {
+		t.Errorf("events are not sorted by start date:\n%s", feed)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteJSON(&out, testEvents(t)); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+
+	var got []map[string]any
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out.String())
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d events, want 2", len(got))
+	}
+
+	want := map[string]any{
+		"uid":    "241224-bal@forrostrasbourg.fr",
+		"title":  "Bal de Noël",
+		"start":  "2024-12-24T18:30:00+01:00",
+		"end":    "2024-12-24T23:30:00+01:00",
+		"place":  "La Kulture, 9 rue des Bateliers",
+		"city":   "Strasbourg",
+		"url":    "https://forrostrasbourg.fr/evenements/241224-bal/",
+		"status": "CONFIRMED",
+	}
+	for k, v := range want {
+		if got[0][k] != v {
+			t.Errorf("%s = %v, want %v", k, got[0][k], v)
+		}
+	}
+	if got[1]["status"] != "CANCELLED" {
+		t.Errorf("status = %v, want CANCELLED", got[1]["status"])
+	}
+}
+
+func TestWriteJSONEmpty(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteJSON(&out, nil); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "[]" {
+		t.Errorf("WriteJSON = %s, want []", got)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	events := testEvents(t)
+
+	var out bytes.Buffer
+	if err := WriteICS(&out, events, time.Now()); err != nil {
+		t.Fatalf("WriteICS: %v", err)
+	}
+	feed := out.Bytes()
+
+	tests := []struct {
+		name    string
+		feed    []byte
+		events  []event.Event
+		wantErr []string
+	}{
+		{
+			name:   "matching",
+			feed:   feed,
+			events: events,
+		},
+		{
+			name:    "missing event",
+			feed:    feed,
+			events:  append(events, event.Event{Title: "Stage", StartDate: time.Now(), Path: "content/evenements/stage.md"}),
+			wantErr: []string{"stage@forrostrasbourg.fr: missing from the feed"},
+		},
+		{
+			name:    "removed event",
+			feed:    feed,
+			events:  events[1:],
+			wantErr: []string{"250105-festival@forrostrasbourg.fr: in the feed but not an event"},
+		},
+		{
+			name:    "different status and title",
+			feed:    bytes.Replace(bytes.Replace(feed, []byte("STATUS:CANCELLED"), []byte("STATUS:CONFIRMED"), 1), []byte("SUMMARY:Festival"), []byte("SUMMARY:Fest"), 1),
+			events:  events,
+			wantErr: []string{`STATUS is "CONFIRMED", want "CANCELLED"`, `SUMMARY is "Fest", want "Festival"`},
+		},
+		{
+			name:    "Hugo style line endings",
+			feed:    bytes.ReplaceAll(feed, []byte("\r\n"), []byte("\n")),
+			events:  events,
+			wantErr: []string{"not ended by CRLF"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.feed, tt.events)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Expected error but got none")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error doesn't tell %q:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package calendar
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/event"
+)
+
+// layoutPages are event pages with what the feed must escape and fold.
+var layoutPages = map[string]string{
+	"_index.md": "---\ntitle: Événements\n---\n",
+	"241224-bal.md": `---
+title: "🎄 Bal de Noël, forró pé de serra; avec les musiciens \\o/ de Strasbourg et d'ailleurs 🪗🥁"
+startDate: 2024-12-24T20:00:00+01:00
+endDate: 2024-12-25T01:00:00+01:00
+venue: kulture
+---
+`,
+	"250105-festival.md": `---
+title: Festival
+startDate: 2025-01-05T14:00:00+01:00
+endDate: 2025-01-05T23:00:00+01:00
+place: "Parc de l'Orangerie"
+city: Strasbourg
+cancelled: true
+---
+`,
+}
+
+// TestLayoutFeed renders the iCalendar layouts of the site with Hugo, and
+// checks the feed is valid and lists the events.
+func TestLayoutFeed(t *testing.T) {
+	hugo, err := exec.LookPath("hugo")
+	if err != nil {
+		t.Skip("hugo is not installed")
+	}
+
+	site := t.TempDir()
+	root := filepath.Join("..", "..")
+	if err := os.CopyFS(filepath.Join(site, "layouts"), os.DirFS(filepath.Join(root, "layouts"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.CopyFS(filepath.Join(site, "data"), os.DirFS(filepath.Join(root, "data"))); err != nil {
+		t.Fatal(err)
+	}
+	config := `baseURL: "https://forrostrasbourg.fr/"
+disableKinds: [home, taxonomy, term, sitemap, robotsTXT, 404, rss]
+outputs:
+  section: [calendar]
+  page: [calendar]
+`
+	if err := os.WriteFile(filepath.Join(site, "hugo.yaml"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(site, event.Dir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range layoutPages {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(hugo, "--source", site, "--destination", filepath.Join(site, "public"), "--quiet")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("hugo: %v\n%s", err, out)
+	}
+
+	venues, err := event.LoadVenues(filepath.Join(site, event.VenuesFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, skipped, err := event.LoadDir(dir, venues)
+	if err != nil || len(skipped) > 0 {
+		t.Fatal(err, skipped)
+	}
+
+	for _, feed := range []struct {
+		path   string
+		events []event.Event
+	}{
+		{"evenements/index.ics", events},
+		{"evenements/241224-bal/index.ics", events[:1]},
+	} {
+		b, err := os.ReadFile(filepath.Join(site, "public", feed.path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Verify(b, feed.events); err != nil {
+			t.Errorf("%s: %v\n%s", feed.path, err, b)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+// Package event reads and writes the event pages of the site, the markdown
+// files of content/evenements.
+package event
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Dir is where the event pages live, from the root of the site.
+const Dir = "content/evenements"
+
+// Event is the front matter of an event page, and its body.
+type Event struct {
+	Title       string `yaml:"title"`
+	Description string `yaml:"description"`
+	// Category is the kind of event, one of Categories.
+	Category string `yaml:"category"`
+	// Tags refine the category, e.g. initiation or plein-air.
+	Tags      []string  `yaml:"tags"`
+	StartDate time.Time `yaml:"startDate"`
+	EndDate   time.Time `yaml:"endDate"`
+	// Venue is the ID of the venue in the registry, giving the place and
+	// city when the page doesn't.
+	Venue string `yaml:"venue"`
+	Place string `yaml:"place"`
+	City  string `yaml:"city"`
+	Price string `yaml:"price"`
+	// Banner is the path of the image of the event on the site.
+	Banner string `yaml:"banner"`
+	// Cancelled events stay listed, marked as such.
+	Cancelled bool `yaml:"cancelled"`
+	// Outdoor events depend on the weather. The events of outdoor venues are
+	// outdoor too.
+	Outdoor bool `yaml:"outdoor"`
+	// UID identifies the event in the calendar it was imported from.
+	UID string `yaml:"uid"`
+	// SourceURL is the page of the event on the site it was imported from.
+	SourceURL string `yaml:"source_url"`
+
+	// Path of the page, relative to the loaded directory.
+	Path string `yaml:"-"`
+	// Body is the markdown after the front matter.
+	Body string `yaml:"-"`
+}
+
+// Categories are the kinds of events: the regular cours, pratiques and bals
+// of Strasbourg, the festivals and stages (workshops), and the events
+// ailleurs, away from Strasbourg.
+var Categories = []string{"cours", "pratique", "bal", "festival", "stage", "ailleurs"}
+
+// ValidCategory tells whether c is one of Categories. An event without a
+// category is valid too.
+func ValidCategory(c string) bool {
+	if c == "" {
+		return true
+	}
+	for _, known := range Categories {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Slug is the name of the page, e.g. 241224-bal, as used in its URL.
+func (e Event) Slug() string {
+	return strings.TrimSuffix(filepath.Base(e.Path), filepath.Ext(e.Path))
+}
+
+// Parse reads an event page.
+func Parse(content []byte) (Event, error) {
+	var e Event
+
+	text := strings.ReplaceAll(string(content), "\r\n", "\n")
+	if !strings.HasPrefix(text, "---\n") {
+		return e, errors.New("no front matter found")
+	}
+	frontMatter, body, ok := strings.Cut(text[len("---\n"):], "\n---")
+	if !ok {
+		return e, errors.New("unterminated front matter")
+	}
+
+	if err := yaml.Unmarshal([]byte(frontMatter), &e); err != nil {
+		return e, fmt.Errorf("failed to parse front matter: %v", err)
+	}
+	// Skip the rest of the closing --- line
+	if _, after, ok := strings.Cut(body, "\n"); ok {
+		e.Body = after
+	}
+	return e, nil
+}
+
+// Skipped is an event page that couldn't be read, and why.
+type Skipped struct {
+	Path string
+	Err  error
+}
+
+// LoadDir reads the event pages of dir and its subdirectories. Pages
+// without a start date, like the section index, aren't events and are
+// skipped. The venues of the events are resolved from venues, unless it is
+// nil. The pages that can't be read, or whose venue is unknown, are skipped
+// and returned with the reason, so one bad page doesn't keep the others from
+// being used.
+func LoadDir(dir string, venues Venues) ([]Event, []Skipped, error) {
+	var events []Event
+	var skipped []Skipped
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			skipped = append(skipped, Skipped{Path: path, Err: err})
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || filepath.Ext(path) != ".md" {
+			return nil
+		}
+
+		e, err := loadPage(path, venues)
+		if err != nil {
+			skipped = append(skipped, Skipped{Path: path, Err: err})
+			return nil
+		}
+		if e.StartDate.IsZero() {
+			return nil
+		}
+		e.Path, err = filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		events = append(events, e)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return events, skipped, nil
+}
+
+// loadPage reads the event page at path, its venue resolved from venues.
+func loadPage(path string, venues Venues) (Event, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Event{}, err
+	}
+	e, err := Parse(content)
+	if err != nil {
+		return Event{}, err
+	}
+	if venues != nil && !e.StartDate.IsZero() {
+		if err := venues.Resolve(&e); err != nil {
+			return Event{}, err
+		}
+	}
+	return e, nil
+}
+
+// Marshal writes the event page, with its front matter laid out like the
+// pages written by hand.
+func Marshal(e Event) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString("---\n")
+
+	fields := []struct {
+		key   string
+		value string
+	}{
+		{"title", e.Title},
+		{"description", e.Description},
+		{"category", e.Category},
+		{"startDate", formatTime(e.StartDate)},
+		{"endDate", formatTime(e.EndDate)},
+		{"venue", e.Venue},
+		{"place", e.Place},
+		{"city", e.City},
+		{"price", e.Price},
+		{"banner", e.Banner},
+		{"uid", e.UID},
+		{"source_url", e.SourceURL},
+	}
+	for _, f := range fields {
+		if f.value == "" {
+			continue
+		}
+		value, err := yaml.Marshal(f.value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write %s: %v", f.key, err)
+		}
+		fmt.Fprintf(&b, "%s: %s", f.key, value)
+	}
+	if len(e.Tags) > 0 {
+		// As a flow sequence, like the lists of the templates
+		tags := make([]string, len(e.Tags))
+		for i, tag := range e.Tags {
+			value, err := yaml.Marshal(tag)
+			if err != nil {
+				return nil, fmt.Errorf("failed to write tags: %v", err)
+			}
+			tags[i] = strings.TrimSuffix(string(value), "\n")
+		}
+		fmt.Fprintf(&b, "tags: [%s]\n", strings.Join(tags, ", "))
+	}
+
+	if e.Cancelled {
+		b.WriteString("cancelled: true\n")
+	}
+	if e.Outdoor {
+		b.WriteString("outdoor: true\n")
+	}
+	b.WriteString("---\n")
+	if e.Body != "" {
+		b.WriteString("\n")
+		b.WriteString(strings.TrimLeft(e.Body, "\n"))
+		if !strings.HasSuffix(e.Body, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return []byte(b.String()), nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
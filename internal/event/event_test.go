@@ -0,0 +1,159 @@
+package event
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarshalParse(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := Event{
+		Title:       "Bal Forró: avec initiation",
+		Description: "Chaque mardi on danse",
+		Category:    "bal",
+		Tags:        []string{"initiation", "live"},
+		StartDate:   time.Date(2024, 12, 24, 18, 30, 0, 0, paris),
+		EndDate:     time.Date(2024, 12, 24, 23, 30, 0, 0, paris),
+		Place:       "La Kulture, 9 rue des Bateliers",
+		City:        "Strasbourg",
+		UID:         "bal-20241224@lakulture.fr",
+		SourceURL:   "https://lakulture.fr/agenda/bal-forro",
+		Cancelled:   true,
+		Body:        "Chaque mardi on danse le forró !\n",
+	}
+
+	content, err := Marshal(e)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	want := `---
+title: 'Bal Forró: avec initiation'
+description: Chaque mardi on danse
+category: bal
+startDate: "2024-12-24T18:30:00+01:00"
+endDate: "2024-12-24T23:30:00+01:00"
+place: La Kulture, 9 rue des Bateliers
+city: Strasbourg
+uid: bal-20241224@lakulture.fr
+source_url: https://lakulture.fr/agenda/bal-forro
+tags: [initiation, live]
+cancelled: true
+---
+
+Chaque mardi on danse le forró !
+`
+	if string(content) != want {
+		t.Errorf("Marshal =\n%s\nwant\n%s", content, want)
+	}
+
+	got, err := Parse(content)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got.Title != e.Title || got.Place != e.Place || got.UID != e.UID || got.SourceURL != e.SourceURL || got.Cancelled != e.Cancelled || got.Category != e.Category || strings.Join(got.Tags, ",") != strings.Join(e.Tags, ",") || got.Body != "\n"+e.Body {
+		t.Errorf("Parse = %+v, want %+v", got, e)
+	}
+	if !got.StartDate.Equal(e.StartDate) || !got.EndDate.Equal(e.EndDate) {
+		t.Errorf("Parse dates = %v - %v, want %v - %v", got.StartDate, got.EndDate, e.StartDate, e.EndDate)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "no front matter", content: "# Bal\n"},
+		{name: "unterminated front matter", content: "---\ntitle: Bal\n"},
+		{name: "invalid yaml", content: "---\ntitle: [Bal\n---\n"},
+		{name: "invalid date", content: "---\nstartDate: mardi\n---\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.content)); err == nil {
+				t.Error("Expected error but got none")
+			}
+		})
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"_index.md":                 "---\ntitle: Les événements\n---\n",
+		"241224-bal.md":             "---\ntitle: Bal\nstartDate: \"2024-12-24T18:30:00+01:00\"\nvenue: kulture\n---\n",
+		"banners/banner.png":        "not an event",
+		"templates/bal.md.template": "---\ntitle: Bal\nstartDate: \"{{.Date}}T18:30:00+01:00\"\n---\n",
+		"241231-broken.md":          "---\ntitle: [Bal\n---\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	venues := Venues{"kulture": {Name: "La Kulture", Address: "9 rue des Bateliers", City: "Strasbourg"}}
+	events, skipped, err := LoadDir(dir, venues)
+	if err != nil {
+		t.Fatalf("LoadDir: %v", err)
+	}
+	// The broken page doesn't keep the others from being read
+	if len(skipped) != 1 || skipped[0].Path != filepath.Join(dir, "241231-broken.md") || skipped[0].Err == nil {
+		t.Errorf("skipped = %+v, want the broken page", skipped)
+	}
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1: %+v", len(events), events)
+	}
+	if events[0].Path != "241224-bal.md" || events[0].Slug() != "241224-bal" {
+		t.Errorf("Path = %q, Slug = %q", events[0].Path, events[0].Slug())
+	}
+	if events[0].Place != "La Kulture, 9 rue des Bateliers" || events[0].City != "Strasbourg" {
+		t.Errorf("venue resolved as %q, %q", events[0].Place, events[0].City)
+	}
+
+	// Without the venues, the pages are read as written
+	events, _, err = LoadDir(dir, nil)
+	if err != nil {
+		t.Fatalf("LoadDir: %v", err)
+	}
+	if events[0].Place != "" {
+		t.Errorf("venue resolved without venues: %q", events[0].Place)
+	}
+
+	events, skipped, err = LoadDir(dir, Venues{})
+	if err != nil || len(events) != 0 || len(skipped) != 2 || !strings.Contains(skipped[0].Err.Error()+skipped[1].Err.Error(), `unknown venue "kulture"`) {
+		t.Errorf("expected the page of an unknown venue skipped, got %+v, %+v, %v", events, skipped, err)
+	}
+
+	if _, _, err := LoadDir(filepath.Join(dir, "missing"), nil); err == nil {
+		t.Error("Expected error but got none")
+	}
+}
+
+func TestLoadRepositoryEvents(t *testing.T) {
+	// The pages written by hand must all be readable
+	venues, err := LoadVenues(filepath.Join("..", "..", VenuesFile))
+	if err != nil {
+		t.Fatalf("LoadVenues: %v", err)
+	}
+	_, skipped, err := LoadDir(filepath.Join("..", "..", Dir), venues)
+	if err != nil {
+		t.Errorf("LoadDir: %v", err)
+	}
+	for _, s := range skipped {
+		t.Errorf("%s: %v", s.Path, s.Err)
+	}
+}
@@ -0,0 +1,205 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// SchemaEvent is the schema.org DanceEvent describing an event page to the
+// search engines, embedded in the page as JSON-LD.
+type SchemaEvent struct {
+	Context             string        `json:"@context"`
+	Type                string        `json:"@type"`
+	Name                string        `json:"name"`
+	Description         string        `json:"description,omitempty"`
+	StartDate           string        `json:"startDate"`
+	EndDate             string        `json:"endDate,omitempty"`
+	EventStatus         string        `json:"eventStatus"`
+	EventAttendanceMode string        `json:"eventAttendanceMode"`
+	Location            *SchemaPlace  `json:"location,omitempty"`
+	Image               []string      `json:"image,omitempty"`
+	Offers              *SchemaOffer  `json:"offers,omitempty"`
+	Organizer           *SchemaEntity `json:"organizer,omitempty"`
+	URL                 string        `json:"url"`
+}
+
+// SchemaPlace is where the event takes place.
+type SchemaPlace struct {
+	Type    string        `json:"@type"`
+	Name    string        `json:"name"`
+	Address SchemaAddress `json:"address"`
+}
+
+// SchemaAddress is the postal address of a place.
+type SchemaAddress struct {
+	Type            string `json:"@type"`
+	StreetAddress   string `json:"streetAddress,omitempty"`
+	AddressLocality string `json:"addressLocality,omitempty"`
+}
+
+// SchemaOffer is the price of the event. Price is only set when it could be
+// read from the price of the page, which is free text.
+type SchemaOffer struct {
+	Type          string `json:"@type"`
+	Price         string `json:"price,omitempty"`
+	PriceCurrency string `json:"priceCurrency,omitempty"`
+	Description   string `json:"description,omitempty"`
+	URL           string `json:"url"`
+}
+
+// SchemaEntity is the organization behind the event.
+type SchemaEntity struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+const (
+	organizerName = "Forró Strasbourg"
+
+	eventScheduled = "https://schema.org/EventScheduled"
+	eventCancelled = "https://schema.org/EventCancelled"
+)
+
+// SchemaOrg describes the event for the search engines. baseURL is the root
+// of the site, e.g. https://forrostrasbourg.fr, the page and the banner are
+// linked from it.
+func (e Event) SchemaOrg(baseURL string) SchemaEvent {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	pageURL := baseURL + "/evenements/" + e.Slug() + "/"
+
+	s := SchemaEvent{
+		Context:             "https://schema.org",
+		Type:                "DanceEvent",
+		Name:                e.Title,
+		Description:         e.Description,
+		StartDate:           formatTime(e.StartDate),
+		EndDate:             formatTime(e.EndDate),
+		EventStatus:         eventScheduled,
+		EventAttendanceMode: "https://schema.org/OfflineEventAttendanceMode",
+		Organizer:           &SchemaEntity{Type: "Organization", Name: organizerName, URL: baseURL + "/"},
+		URL:                 pageURL,
+	}
+	if e.Cancelled {
+		s.EventStatus = eventCancelled
+	}
+
+	if e.Place != "" || e.City != "" {
+		name, street := splitPlace(e.Place)
+		if name == "" {
+			name = e.City
+		}
+		s.Location = &SchemaPlace{
+			Type: "Place",
+			Name: name,
+			Address: SchemaAddress{
+				Type:            "PostalAddress",
+				StreetAddress:   street,
+				AddressLocality: e.City,
+			},
+		}
+	}
+
+	if e.Banner != "" {
+		image := e.Banner
+		if !strings.HasPrefix(image, "http://") && !strings.HasPrefix(image, "https://") {
+			image = baseURL + "/" + strings.TrimPrefix(image, "/")
+		}
+		s.Image = []string{image}
+	}
+
+	if e.Price != "" {
+		s.Offers = &SchemaOffer{Type: "Offer", Description: e.Price, URL: pageURL}
+		if price, ok := parsePrice(e.Price); ok {
+			s.Offers.Price = price
+			s.Offers.PriceCurrency = "EUR"
+		}
+	}
+
+	return s
+}
+
+// Validate checks the properties the search engines require to show the
+// event: its name, start date and location.
+func (s SchemaEvent) Validate() error {
+	var errs []error
+	if s.Name == "" {
+		errs = append(errs, errors.New("missing name (title)"))
+	}
+	if s.StartDate == "" {
+		errs = append(errs, errors.New("missing startDate"))
+	} else if _, err := time.Parse(time.RFC3339, s.StartDate); err != nil {
+		errs = append(errs, errors.New("startDate is not an ISO 8601 date-time"))
+	}
+	if s.EndDate != "" {
+		start, _ := time.Parse(time.RFC3339, s.StartDate)
+		end, err := time.Parse(time.RFC3339, s.EndDate)
+		switch {
+		case err != nil:
+			errs = append(errs, errors.New("endDate is not an ISO 8601 date-time"))
+		case end.Before(start):
+			errs = append(errs, errors.New("endDate before startDate"))
+		}
+	}
+	if s.Location == nil || s.Location.Name == "" {
+		errs = append(errs, errors.New("missing location (place or city)"))
+	}
+	if s.Offers != nil && s.Offers.Price != "" && s.Offers.PriceCurrency == "" {
+		errs = append(errs, errors.New("offer price without currency"))
+	}
+	return errors.Join(errs...)
+}
+
+// JSON writes the JSON-LD document, indented.
+func (s SchemaEvent) JSON() ([]byte, error) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// splitPlace splits a place like "La Kulture, 9 rue des bateliers" into the
+// name of the place and its street. A place that is only an address, like
+// "36 quai des bateliers", is named after it.
+func splitPlace(place string) (name, street string) {
+	name, street, _ = strings.Cut(place, ",")
+	name, street = strings.TrimSpace(name), strings.TrimSpace(street)
+	if street == "" && startsWithDigit(name) {
+		street = name
+	}
+	return name, street
+}
+
+func startsWithDigit(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
+
+var priceRe = regexp.MustCompile(`^(\d+(?:[.,]\d+)?)\s*€`)
+
+// parsePrice reads the amount in euros at the start of a price like
+// "5€ (8€ sur place)" or "gratuit (ou 2€)".
+func parsePrice(price string) (string, bool) {
+	price = strings.TrimSpace(price)
+	if strings.HasPrefix(strings.ToLower(price), "gratuit") {
+		return "0", true
+	}
+	m := priceRe.FindStringSubmatch(price)
+	if m == nil {
+		return "", false
+	}
+	return strings.Replace(m[1], ",", ".", 1), true
+}
+
+// JSONLDDir is where the JSON-LD of the event pages is written, from the
+// root of the site, as Hugo data files the event pages embed.
+const JSONLDDir = "data/jsonld"
+
+// JSONLDPath is the data file of the JSON-LD of the event.
+func (e Event) JSONLDPath() string {
+	return filepath.Join(JSONLDDir, e.Slug()+".json")
+}
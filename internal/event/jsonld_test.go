@@ -0,0 +1,197 @@
+package event
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSchemaOrg(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := Event{
+		Title:       "Bal Forró à la Kulture",
+		Description: "Chaque mardi on danse",
+		StartDate:   time.Date(2024, 12, 24, 18, 30, 0, 0, paris),
+		EndDate:     time.Date(2024, 12, 24, 23, 30, 0, 0, paris),
+		Place:       "La Kulture, 9 rue des bateliers",
+		City:        "Strasbourg",
+		Price:       "gratuit (consommation sur place boisson et repas)",
+		Banner:      "/evenements/banners/kulture-forro.jpeg",
+		Path:        "241224-bal-kulture.md",
+	}
+
+	ld := e.SchemaOrg("https://forrostrasbourg.fr/")
+	if err := ld.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+
+	data, err := ld.JSON()
+	if err != nil {
+		t.Fatalf("JSON: %v", err)
+	}
+	want := `{
+  "@context": "https://schema.org",
+  "@type": "DanceEvent",
+  "name": "Bal Forró à la Kulture",
+  "description": "Chaque mardi on danse",
+  "startDate": "2024-12-24T18:30:00+01:00",
+  "endDate": "2024-12-24T23:30:00+01:00",
+  "eventStatus": "https://schema.org/EventScheduled",
+  "eventAttendanceMode": "https://schema.org/OfflineEventAttendanceMode",
+  "location": {
+    "@type": "Place",
+    "name": "La Kulture",
+    "address": {
+      "@type": "PostalAddress",
+      "streetAddress": "9 rue des bateliers",
+      "addressLocality": "Strasbourg"
+    }
+  },
+  "image": [
+    "https://forrostrasbourg.fr/evenements/banners/kulture-forro.jpeg"
+  ],
+  "offers": {
+    "@type": "Offer",
+    "price": "0",
+    "priceCurrency": "EUR",
+    "description": "gratuit (consommation sur place boisson et repas)",
+    "url": "https://forrostrasbourg.fr/evenements/241224-bal-kulture/"
+  },
+  "organizer": {
+    "@type": "Organization",
+    "name": "Forró Strasbourg",
+    "url": "https://forrostrasbourg.fr/"
+  },
+  "url": "https://forrostrasbourg.fr/evenements/241224-bal-kulture/"
+}
+`
+	if string(data) != want {
+		t.Errorf("JSON =\n%s\nwant\n%s", data, want)
+	}
+	if got := e.JSONLDPath(); got != "data/jsonld/241224-bal-kulture.json" {
+		t.Errorf("JSONLDPath = %q", got)
+	}
+}
+
+func TestSchemaOrgCancelled(t *testing.T) {
+	e := Event{Title: "Bal", StartDate: time.Now(), City: "Strasbourg", Cancelled: true, Path: "bal.md"}
+
+	var got map[string]any
+	data, err := e.SchemaOrg("https://forrostrasbourg.fr").JSON()
+	if err != nil {
+		t.Fatalf("JSON: %v", err)
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got["eventStatus"] != "https://schema.org/EventCancelled" {
+		t.Errorf("eventStatus = %v", got["eventStatus"])
+	}
+	// Optional properties are left out
+	for _, key := range []string{"endDate", "image", "offers", "description"} {
+		if _, ok := got[key]; ok {
+			t.Errorf("unexpected %s: %v", key, got[key])
+		}
+	}
+	location := got["location"].(map[string]any)
+	if location["name"] != "Strasbourg" {
+		t.Errorf("location name = %v, want the city", location["name"])
+	}
+}
+
+func TestSchemaEventValidate(t *testing.T) {
+	start := time.Date(2024, 12, 24, 18, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		event   Event
+		wantErr []string
+	}{
+		{
+			name:  "valid",
+			event: Event{Title: "Bal", StartDate: start, Place: "Parc du Heyritz"},
+		},
+		{
+			name:    "missing everything",
+			event:   Event{},
+			wantErr: []string{"missing name", "missing startDate", "missing location"},
+		},
+		{
+			name:    "end before start",
+			event:   Event{Title: "Bal", StartDate: start, EndDate: start.Add(-time.Hour), City: "Strasbourg"},
+			wantErr: []string{"endDate before startDate"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.event.SchemaOrg("https://forrostrasbourg.fr").Validate()
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Expected error but got none")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error doesn't tell %q: %v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		price  string
+		want   string
+		wantOK bool
+	}{
+		{price: "gratuit", want: "0", wantOK: true},
+		{price: "Gratuit (ou 2€)", want: "0", wantOK: true},
+		{price: "0€", want: "0", wantOK: true},
+		{price: "5€ (8€ sur place)", want: "5", wantOK: true},
+		{price: "200€ (l'année), cours d'essai gratuit", want: "200", wantOK: true},
+		{price: "7,50 €", want: "7.50", wantOK: true},
+		{price: "prix libre"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.price, func(t *testing.T) {
+			got, ok := parsePrice(tt.price)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("parsePrice(%q) = %q, %v, want %q, %v", tt.price, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSplitPlace(t *testing.T) {
+	tests := []struct {
+		place      string
+		wantName   string
+		wantStreet string
+	}{
+		{place: "Pachamama's, 1 passage d'Osthouse", wantName: "Pachamama's", wantStreet: "1 passage d'Osthouse"},
+		{place: "36 quai des bateliers", wantName: "36 quai des bateliers", wantStreet: "36 quai des bateliers"},
+		{place: "Parc du Heyritz", wantName: "Parc du Heyritz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.place, func(t *testing.T) {
+			name, street := splitPlace(tt.place)
+			if name != tt.wantName || street != tt.wantStreet {
+				t.Errorf("splitPlace(%q) = %q, %q, want %q, %q", tt.place, name, street, tt.wantName, tt.wantStreet)
+			}
+		})
+	}
+}
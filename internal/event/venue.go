@@ -0,0 +1,128 @@
+package event
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/staticmap"
+)
+
+// VenuesFile is the registry of the venues, from the root of the site.
+const VenuesFile = "data/venues.yaml"
+
+// Venue is a place where events happen, referenced by its ID in the venue
+// field of the event pages.
+type Venue struct {
+	ID            string  `yaml:"-"`
+	Name          string  `yaml:"name"`
+	Address       string  `yaml:"address"`
+	PostalCode    string  `yaml:"postal_code"`
+	City          string  `yaml:"city"`
+	Latitude      float64 `yaml:"latitude"`
+	Longitude     float64 `yaml:"longitude"`
+	Accessibility string  `yaml:"accessibility"`
+	Website       string  `yaml:"website"`
+	// Outdoor venues depend on the weather, see the weather command of
+	// forro.
+	Outdoor bool `yaml:"outdoor"`
+}
+
+// Place is the place of the events held at the venue, as written in the
+// pages: its name and street address.
+func (v Venue) Place() string {
+	switch {
+	case v.Name == "":
+		return v.Address
+	case v.Address == "":
+		return v.Name
+	}
+	return v.Name + ", " + v.Address
+}
+
+// FullAddress is the canonical address of the venue, e.g. "La Kulture,
+// 9 rue des Bateliers, 67000 Strasbourg".
+func (v Venue) FullAddress() string {
+	city := strings.TrimSpace(v.PostalCode + " " + v.City)
+	if place := v.Place(); place != "" {
+		return place + ", " + city
+	}
+	return city
+}
+
+// HasCoordinates tells whether the venue can be shown on a map.
+func (v Venue) HasCoordinates() bool {
+	return v.Latitude != 0 || v.Longitude != 0
+}
+
+// OSMURL is the link to the venue on openstreetmap.org, if it has
+// coordinates.
+func (v Venue) OSMURL() string {
+	if !v.HasCoordinates() {
+		return ""
+	}
+	return staticmap.OSMURL(v.Latitude, v.Longitude, 17)
+}
+
+// Venues is the registry of the venues by ID.
+type Venues map[string]Venue
+
+// LoadVenues reads the venues registry. A missing file is an empty registry.
+func LoadVenues(path string) (Venues, error) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Venues{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var venues Venues
+	if err := yaml.Unmarshal(content, &venues); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if venues == nil {
+		venues = Venues{}
+	}
+	for id, v := range venues {
+		v.ID = id
+		venues[id] = v
+	}
+	return venues, nil
+}
+
+// IDs returns the IDs of the venues, sorted.
+func (vs Venues) IDs() []string {
+	ids := make([]string, 0, len(vs))
+	for id := range vs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Resolve fills the place and city of the event from its venue, unless the
+// page sets them, and marks the events of outdoor venues as outdoor. An
+// unknown venue is an error.
+func (vs Venues) Resolve(e *Event) error {
+	if e.Venue == "" {
+		return nil
+	}
+	v, ok := vs[e.Venue]
+	if !ok {
+		return fmt.Errorf("unknown venue %q", e.Venue)
+	}
+	if e.Place == "" {
+		e.Place = v.Place()
+	}
+	if e.City == "" {
+		e.City = v.City
+	}
+	if v.Outdoor {
+		e.Outdoor = true
+	}
+	return nil
+}
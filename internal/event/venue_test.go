@@ -0,0 +1,122 @@
+package event
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadVenues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "venues.yaml")
+	content := `kulture:
+  name: La Kulture
+  address: 9 rue des Bateliers
+  postal_code: "67000"
+  city: Strasbourg
+  latitude: 48.5806
+  longitude: 7.7527
+  accessibility: Sous-sol, accessible par un escalier
+quai:
+  address: 36 quai des Bateliers
+  city: Strasbourg
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	venues, err := LoadVenues(path)
+	if err != nil {
+		t.Fatalf("LoadVenues: %v", err)
+	}
+	if ids := venues.IDs(); len(ids) != 2 || ids[0] != "kulture" || ids[1] != "quai" {
+		t.Fatalf("IDs = %v", ids)
+	}
+
+	kulture := venues["kulture"]
+	if kulture.ID != "kulture" || kulture.PostalCode != "67000" || !kulture.HasCoordinates() || kulture.Accessibility == "" {
+		t.Errorf("kulture = %+v", kulture)
+	}
+	if got := kulture.Place(); got != "La Kulture, 9 rue des Bateliers" {
+		t.Errorf("Place = %q", got)
+	}
+	if got := kulture.FullAddress(); got != "La Kulture, 9 rue des Bateliers, 67000 Strasbourg" {
+		t.Errorf("FullAddress = %q", got)
+	}
+
+	if got := kulture.OSMURL(); got != "https://www.openstreetmap.org/?mlat=48.58060&mlon=7.75270#map=17/48.58060/7.75270" {
+		t.Errorf("OSMURL = %q", got)
+	}
+
+	quai := venues["quai"]
+	if quai.HasCoordinates() || quai.OSMURL() != "" {
+		t.Errorf("quai has coordinates: %+v", quai)
+	}
+	if got := quai.FullAddress(); got != "36 quai des Bateliers, Strasbourg" {
+		t.Errorf("FullAddress = %q", got)
+	}
+}
+
+func TestLoadVenuesMissing(t *testing.T) {
+	venues, err := LoadVenues(filepath.Join(t.TempDir(), "venues.yaml"))
+	if err != nil {
+		t.Fatalf("LoadVenues: %v", err)
+	}
+	if len(venues) != 0 {
+		t.Errorf("venues = %v, want none", venues)
+	}
+}
+
+func TestLoadVenuesInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "venues.yaml")
+	if err := os.WriteFile(path, []byte("- not a map"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadVenues(path); err == nil {
+		t.Error("Expected error but got none")
+	}
+}
+
+func TestVenuesResolve(t *testing.T) {
+	venues := Venues{
+		"heyritz": {Name: "Parc du Heyritz", City: "Strasbourg", Outdoor: true},
+		"kulture": {Name: "La Kulture", City: "Strasbourg"},
+	}
+
+	tests := []struct {
+		name        string
+		event       Event
+		wantPlace   string
+		wantCity    string
+		wantOutdoor bool
+		wantErr     bool
+	}{
+		{name: "venue", event: Event{Venue: "kulture"}, wantPlace: "La Kulture", wantCity: "Strasbourg"},
+		{name: "outdoor venue", event: Event{Venue: "heyritz"}, wantPlace: "Parc du Heyritz", wantCity: "Strasbourg", wantOutdoor: true},
+		{name: "page overrides the place", event: Event{Venue: "heyritz", Place: "Près du kiosque"}, wantPlace: "Près du kiosque", wantCity: "Strasbourg", wantOutdoor: true},
+		{name: "outdoor page", event: Event{Venue: "kulture", Outdoor: true}, wantPlace: "La Kulture", wantCity: "Strasbourg", wantOutdoor: true},
+		{name: "no venue", event: Event{Place: "Chez Jo", City: "Kehl"}, wantPlace: "Chez Jo", wantCity: "Kehl"},
+		{name: "unknown venue", event: Event{Venue: "nowhere"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := tt.event
+			err := venues.Resolve(&e)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if e.Place != tt.wantPlace || e.City != tt.wantCity {
+				t.Errorf("resolved as %q, %q, want %q, %q", e.Place, e.City, tt.wantPlace, tt.wantCity)
+			}
+			if e.Outdoor != tt.wantOutdoor {
+				t.Errorf("Outdoor = %v, want %v", e.Outdoor, tt.wantOutdoor)
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLineOctets is the longest content line allowed, folding excluded.
+const maxLineOctets = 75
+
+// Alarm reminds of an event some time before it starts.
+type Alarm struct {
+	Description string
+	Before      time.Duration
+}
+
+// Feed is what Encode needs beyond the events.
+type Feed struct {
+	ProdID string
+	// Color of the calendar in Apple's calendar app, e.g. #e7ad74.
+	Color string
+	// RefreshInterval tells the clients how often to fetch the feed.
+	RefreshInterval time.Duration
+	// Location the times are written in, with its VTIMEZONE. UTC if nil.
+	Location *time.Location
+	// Stamp is the DTSTAMP of the events, when the feed was generated.
+	Stamp time.Time
+}
+
+// Encode writes the calendar as iCalendar: CRLF line endings, lines folded
+// at 75 octets, escaped text values, and a VTIMEZONE for the times given in
+// feed.Location.
+func (c *Calendar) Encode(w io.Writer, feed Feed) error {
+	loc := feed.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	e := &encoder{w: bufio.NewWriter(w)}
+	e.line("BEGIN", "VCALENDAR")
+	e.line("VERSION", "2.0")
+	e.line("PRODID", feed.ProdID)
+	e.line("CALSCALE", "GREGORIAN")
+	e.line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		e.line("X-WR-CALNAME", escape(c.Name))
+	}
+	if loc != time.UTC {
+		e.line("X-WR-TIMEZONE", loc.String())
+	}
+	if feed.Color != "" {
+		e.line("X-APPLE-CALENDAR-COLOR", feed.Color)
+	}
+	if feed.RefreshInterval > 0 {
+		e.line("REFRESH-INTERVAL;VALUE=DURATION", formatDuration(feed.RefreshInterval))
+		e.line("X-PUBLISHED-TTL", formatDuration(feed.RefreshInterval))
+	}
+
+	if loc != time.UTC && len(c.Events) > 0 {
+		if err := e.timezone(loc, c.Events[0].Start.In(loc).Year()); err != nil {
+			return err
+		}
+	}
+
+	for _, event := range c.Events {
+		if event.UID == "" {
+			return fmt.Errorf("event %q has no UID", event.Summary)
+		}
+
+		e.line("BEGIN", "VEVENT")
+		e.line("UID", event.UID)
+		e.line("DTSTAMP", feed.Stamp.UTC().Format("20060102T150405Z"))
+		e.line("SUMMARY", escape(event.Summary))
+		if event.Description != "" {
+			e.line("DESCRIPTION", escape(event.Description))
+		}
+		e.time("DTSTART", event.Start, event.AllDay, loc)
+		if !event.End.IsZero() {
+			e.time("DTEND", event.End, event.AllDay, loc)
+		}
+		if event.Location != "" {
+			e.line("LOCATION", escape(event.Location))
+		}
+		if event.URL != "" {
+			e.line("URL;VALUE=URI", event.URL)
+		}
+		status := event.Status
+		if status == "" {
+			status = "CONFIRMED"
+		}
+		e.line("STATUS", status)
+		for _, alarm := range event.Alarms {
+			e.line("BEGIN", "VALARM")
+			e.line("ACTION", "DISPLAY")
+			e.line("DESCRIPTION", escape(alarm.Description))
+			e.line("TRIGGER;RELATED=START", formatDuration(-alarm.Before))
+			e.line("END", "VALARM")
+		}
+		e.line("END", "VEVENT")
+	}
+
+	e.line("END", "VCALENDAR")
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+// line writes a content line, folded.
+func (e *encoder) line(name, value string) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = e.w.WriteString(fold(name + ":" + value))
+}
+
+func (e *encoder) time(name string, t time.Time, allDay bool, loc *time.Location) {
+	switch {
+	case allDay:
+		e.line(name+";VALUE=DATE", t.In(loc).Format("20060102"))
+	case loc == time.UTC:
+		e.line(name, t.UTC().Format("20060102T150405Z"))
+	default:
+		e.line(name+";TZID="+loc.String(), t.In(loc).Format("20060102T150405"))
+	}
+}
+
+// timezone writes the VTIMEZONE of loc, with the daylight saving rules
+// observed in year.
+func (e *encoder) timezone(loc *time.Location, year int) error {
+	e.line("BEGIN", "VTIMEZONE")
+	e.line("TZID", loc.String())
+
+	changes := offsetChanges(loc, year)
+	if len(changes) == 0 {
+		// No daylight saving time
+		name, offset := time.Date(year, time.January, 1, 0, 0, 0, 0, loc).Zone()
+		e.line("BEGIN", "STANDARD")
+		e.line("DTSTART", "19700101T000000")
+		e.line("TZOFFSETFROM", formatOffset(offset))
+		e.line("TZOFFSETTO", formatOffset(offset))
+		e.line("TZNAME", name)
+		e.line("END", "STANDARD")
+	}
+	for _, c := range changes {
+		rule, err := yearlyRule(c.at)
+		if err != nil {
+			return fmt.Errorf("time zone %s: %v", loc, err)
+		}
+		kind := "STANDARD"
+		if c.to > c.from {
+			kind = "DAYLIGHT"
+		}
+		// The local time of the change, before it happens
+		local := c.at.In(time.FixedZone("", c.from))
+		e.line("BEGIN", kind)
+		e.line("DTSTART", "1970"+local.Format("0102T150405"))
+		e.line("TZOFFSETFROM", formatOffset(c.from))
+		e.line("TZOFFSETTO", formatOffset(c.to))
+		e.line("TZNAME", c.name)
+		e.line("RRULE", rule)
+		e.line("END", kind)
+	}
+
+	e.line("END", "VTIMEZONE")
+	return e.err
+}
+
+// offsetChange is a change of UTC offset, e.g. to summer time.
+type offsetChange struct {
+	at       time.Time
+	from, to int // Seconds east of UTC
+	name     string
+}
+
+// offsetChanges finds when loc changes its UTC offset during year.
+func offsetChanges(loc *time.Location, year int) []offsetChange {
+	var changes []offsetChange
+	day := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	_, offset := day.In(loc).Zone()
+	for day.Year() == year {
+		next := day.Add(24 * time.Hour)
+		if _, nextOffset := next.In(loc).Zone(); nextOffset != offset {
+			// Narrow it down to the minute
+			lo, hi := day.Unix()/60, next.Unix()/60
+			for hi-lo > 1 {
+				mid := (lo + hi) / 2
+				if _, o := time.Unix(mid*60, 0).In(loc).Zone(); o == offset {
+					lo = mid
+				} else {
+					hi = mid
+				}
+			}
+			at := time.Unix(hi*60, 0)
+			name, _ := at.In(loc).Zone()
+			changes = append(changes, offsetChange{at: at, from: offset, to: nextOffset, name: name})
+			offset = nextOffset
+		}
+		day = next
+	}
+	return changes
+}
+
+// yearlyRule describes the day of the change as a yearly recurrence: the
+// n-th or last weekday of its month.
+func yearlyRule(at time.Time) (string, error) {
+	at = at.UTC()
+	weekday := strings.ToUpper(at.Weekday().String()[:2])
+	daysInMonth := time.Date(at.Year(), at.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+
+	n := (at.Day()-1)/7 + 1
+	if at.Day()+7 > daysInMonth {
+		n = -1
+	}
+	if n > 4 {
+		return "", fmt.Errorf("unsupported offset change on %s", at.Format(time.DateOnly))
+	}
+	return fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;BYDAY=%d%s", at.Month(), n, weekday), nil
+}
+
+func formatOffset(seconds int) string {
+	sign := '+'
+	if seconds < 0 {
+		sign = '-'
+		seconds = -seconds
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, seconds/3600, seconds%3600/60)
+}
+
+// formatDuration writes a DURATION value, e.g. -PT2H or P1D.
+func formatDuration(d time.Duration) string {
+	var b strings.Builder
+	if d < 0 {
+		b.WriteByte('-')
+		d = -d
+	}
+	b.WriteByte('P')
+	if days := d / (24 * time.Hour); days > 0 && d%(24*time.Hour) == 0 {
+		fmt.Fprintf(&b, "%dD", days)
+		return b.String()
+	}
+
+	b.WriteByte('T')
+	if h := d / time.Hour; h > 0 {
+		fmt.Fprintf(&b, "%dH", h)
+	}
+	if m := d % time.Hour / time.Minute; m > 0 {
+		fmt.Fprintf(&b, "%dM", m)
+	}
+	if s := d % time.Minute / time.Second; s > 0 || d < time.Minute {
+		fmt.Fprintf(&b, "%dS", s)
+	}
+	return b.String()
+}
+
+// escape encodes a TEXT value.
+func escape(value string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(value)
+}
+
+// fold cuts the content line in lines of at most 75 octets, without
+// splitting UTF-8 characters, and ends each with CRLF.
+func fold(line string) string {
+	var b strings.Builder
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// The leading space counts
+		limit = maxLineOctets - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+	return b.String()
+}
@@ -0,0 +1,221 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncode(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cal := &Calendar{
+		Name: "Forró Strasbourg",
+		Events: []Event{
+			{
+				UID:         "241224-bal@forrostrasbourg.fr",
+				Summary:     "Bal de Noël, avec initiation; entrée libre",
+				Description: "Chaque mardi on danse le forró, une danse de couple brésilienne !\nVenez nombreux·ses",
+				Location:    "La Kulture, 9 rue des Bateliers, Strasbourg",
+				URL:         "https://forrostrasbourg.fr/evenements/241224-bal/",
+				Start:       time.Date(2024, 12, 24, 18, 30, 0, 0, paris),
+				End:         time.Date(2024, 12, 24, 23, 30, 0, 0, paris),
+				Alarms:      []Alarm{{Description: "dernier rappel", Before: 2 * time.Hour}},
+			},
+			{
+				UID:     "250105-festival@forrostrasbourg.fr",
+				Summary: "Festival",
+				Status:  "CANCELLED",
+				Start:   time.Date(2025, 1, 5, 0, 0, 0, 0, paris),
+				End:     time.Date(2025, 1, 6, 0, 0, 0, 0, paris),
+				AllDay:  true,
+			},
+		},
+	}
+
+	var out bytes.Buffer
+	err = cal.Encode(&out, Feed{
+		ProdID:          "-//forro-strasbourg//event calendar//EN",
+		Color:           "#e7ad74",
+		RefreshInterval: 4 * time.Hour,
+		Location:        paris,
+		Stamp:           time.Date(2024, 12, 1, 10, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	want := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//forro-strasbourg//event calendar//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:Forró Strasbourg",
+		"X-WR-TIMEZONE:Europe/Paris",
+		"X-APPLE-CALENDAR-COLOR:#e7ad74",
+		"REFRESH-INTERVAL;VALUE=DURATION:PT4H",
+		"X-PUBLISHED-TTL:PT4H",
+		"BEGIN:VTIMEZONE",
+		"TZID:Europe/Paris",
+		"BEGIN:DAYLIGHT",
+		"DTSTART:19700331T020000",
+		"TZOFFSETFROM:+0100",
+		"TZOFFSETTO:+0200",
+		"TZNAME:CEST",
+		"RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU",
+		"END:DAYLIGHT",
+		"BEGIN:STANDARD",
+		"DTSTART:19701027T030000",
+		"TZOFFSETFROM:+0200",
+		"TZOFFSETTO:+0100",
+		"TZNAME:CET",
+		"RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU",
+		"END:STANDARD",
+		"END:VTIMEZONE",
+		"BEGIN:VEVENT",
+		"UID:241224-bal@forrostrasbourg.fr",
+		"DTSTAMP:20241201T100000Z",
+		`SUMMARY:Bal de Noël\, avec initiation\; entrée libre`,
+		`DESCRIPTION:Chaque mardi on danse le forró\, une danse de couple brésilie`,
+		` nne !\nVenez nombreux·ses`,
+		"DTSTART;TZID=Europe/Paris:20241224T183000",
+		"DTEND;TZID=Europe/Paris:20241224T233000",
+		`LOCATION:La Kulture\, 9 rue des Bateliers\, Strasbourg`,
+		"URL;VALUE=URI:https://forrostrasbourg.fr/evenements/241224-bal/",
+		"STATUS:CONFIRMED",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"DESCRIPTION:dernier rappel",
+		"TRIGGER;RELATED=START:-PT2H",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:250105-festival@forrostrasbourg.fr",
+		"DTSTAMP:20241201T100000Z",
+		"SUMMARY:Festival",
+		"DTSTART;VALUE=DATE:20250105",
+		"DTEND;VALUE=DATE:20250106",
+		"STATUS:CANCELLED",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+	if out.String() != want {
+		t.Errorf("Encode =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	if err := Validate(out.Bytes()); err != nil {
+		t.Errorf("Encode output is invalid: %v", err)
+	}
+
+	// What is written reads back the same
+	parsed, err := Parse(&out, paris)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(parsed.Events) != 2 {
+		t.Fatalf("parsed %d events, want 2", len(parsed.Events))
+	}
+	for i, got := range parsed.Events {
+		want := cal.Events[i]
+		if got.UID != want.UID || got.Summary != want.Summary || got.Description != want.Description || got.Location != want.Location ||
+			!got.Start.Equal(want.Start) || !got.End.Equal(want.End) || got.AllDay != want.AllDay {
+			t.Errorf("event %d read back as %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestEncodeWithoutUID(t *testing.T) {
+	cal := &Calendar{Events: []Event{{Summary: "Bal", Start: time.Now()}}}
+	if err := cal.Encode(&bytes.Buffer{}, Feed{}); err == nil {
+		t.Error("Expected error but got none")
+	}
+}
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{name: "short", line: "SUMMARY:Bal", want: "SUMMARY:Bal\r\n"},
+		{name: "75 octets", line: strings.Repeat("a", 75), want: strings.Repeat("a", 75) + "\r\n"},
+		{name: "76 octets", line: strings.Repeat("a", 76), want: strings.Repeat("a", 75) + "\r\n a\r\n"},
+		{
+			name: "several folds",
+			line: strings.Repeat("a", 75+74+1),
+			want: strings.Repeat("a", 75) + "\r\n " + strings.Repeat("a", 74) + "\r\n a\r\n",
+		},
+		{
+			// The 75th octet is in the middle of "é"
+			name: "multi-byte character",
+			line: strings.Repeat("a", 74) + "éb",
+			want: strings.Repeat("a", 74) + "\r\n éb\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fold(tt.line); got != tt.want {
+				t.Errorf("fold = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscape(t *testing.T) {
+	got := escape("a\\b;c,d\ne\r\nf")
+	want := `a\\b\;c\,d\ne\nf`
+	if got != want {
+		t.Errorf("escape = %q, want %q", got, want)
+	}
+	if back := unescape(got); back != "a\\b;c,d\ne\nf" {
+		t.Errorf("unescape(escape) = %q", back)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{d: 4 * time.Hour, want: "PT4H"},
+		{d: -2 * time.Hour, want: "-PT2H"},
+		{d: -24 * time.Hour, want: "-P1D"},
+		{d: 90 * time.Minute, want: "PT1H30M"},
+		{d: 25 * time.Hour, want: "PT25H"},
+		{d: 0, want: "PT0S"},
+	}
+
+	for _, tt := range tests {
+		if got := formatDuration(tt.d); got != tt.want {
+			t.Errorf("formatDuration(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+		if back, err := parseDuration(tt.want); err != nil || back != tt.d {
+			t.Errorf("parseDuration(%q) = %v, %v, want %v", tt.want, back, err, tt.d)
+		}
+	}
+}
+
+func TestTimezoneWithoutDaylightSaving(t *testing.T) {
+	cal := &Calendar{Events: []Event{{UID: "a", Summary: "Bal", Start: time.Date(2024, 12, 24, 18, 30, 0, 0, time.UTC)}}}
+
+	var out bytes.Buffer
+	err := cal.Encode(&out, Feed{ProdID: "-//test//EN", Location: time.FixedZone("UTC+3", 3*3600)})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	for _, want := range []string{"BEGIN:STANDARD\r\n", "TZOFFSETTO:+0300\r\n", "DTSTART;TZID=UTC+3:20241224T213000\r\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output doesn't contain %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "RRULE") {
+		t.Errorf("output has a daylight saving rule:\n%s", out.String())
+	}
+}
@@ -0,0 +1,290 @@
+// Package ical reads and writes the events of iCalendar (RFC 5545) files:
+// the feeds published by the venues and our own.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is a VEVENT.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	URL         string
+	Status      string // TENTATIVE, CONFIRMED or CANCELLED, empty if unset
+	Start       time.Time
+	End         time.Time
+	// AllDay is set for events given as dates, without a time.
+	AllDay bool
+	// Alarms are only written, not parsed.
+	Alarms []Alarm
+}
+
+// Calendar is the content of an iCalendar file.
+type Calendar struct {
+	Name   string // X-WR-CALNAME
+	Events []Event
+}
+
+// property is a content line: NAME;PARAM=value:VALUE.
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Parse reads the events of an iCalendar stream. Floating times, and times in
+// an unknown time zone, are read in loc.
+func Parse(r io.Reader, loc *time.Location) (*Calendar, error) {
+	lines, numbers, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	cal := &Calendar{}
+	var stack []string
+	var current []property
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		prop, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", numbers[i], err)
+		}
+
+		switch prop.name {
+		case "BEGIN":
+			stack = append(stack, strings.ToUpper(prop.value))
+			if strings.EqualFold(prop.value, "VEVENT") {
+				current = nil
+			}
+			continue
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1] != strings.ToUpper(prop.value) {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", numbers[i], prop.value)
+			}
+			stack = stack[:len(stack)-1]
+			if strings.EqualFold(prop.value, "VEVENT") {
+				event, err := newEvent(current, loc)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %v", numbers[i], err)
+				}
+				cal.Events = append(cal.Events, event)
+			}
+			continue
+		}
+
+		switch {
+		case len(stack) == 1 && stack[0] == "VCALENDAR" && prop.name == "X-WR-CALNAME":
+			cal.Name = unescape(prop.value)
+		case len(stack) > 0 && stack[len(stack)-1] == "VEVENT":
+			current = append(current, prop)
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("unterminated %s", stack[len(stack)-1])
+	}
+
+	return cal, nil
+}
+
+// unfold joins the lines folded on several physical lines. It also returns
+// the physical line number each line starts at, for error messages.
+func unfold(r io.Reader) ([]string, []int, error) {
+	var lines []string
+	var numbers []int
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+		numbers = append(numbers, n)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read calendar: %v", err)
+	}
+	return lines, numbers, nil
+}
+
+// parseLine parses a content line, the parameter values may be quoted.
+func parseLine(line string) (property, error) {
+	prop := property{params: map[string]string{}}
+
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return prop, fmt.Errorf("invalid content line %q", line)
+	}
+	prop.name = strings.ToUpper(line[:i])
+
+	for line[i] == ';' {
+		rest := line[i+1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			return prop, fmt.Errorf("invalid parameter in %q", line)
+		}
+		name := strings.ToUpper(rest[:eq])
+		rest = rest[eq+1:]
+		i += 1 + eq + 1
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return prop, fmt.Errorf("unterminated quoted parameter in %q", line)
+			}
+			value = rest[1 : end+1]
+			i += end + 2
+		} else {
+			end := strings.IndexAny(rest, ";:")
+			if end < 0 {
+				return prop, fmt.Errorf("no value in %q", line)
+			}
+			value = rest[:end]
+			i += end
+		}
+		prop.params[name] = value
+
+		if i >= len(line) {
+			return prop, fmt.Errorf("no value in %q", line)
+		}
+	}
+
+	if line[i] != ':' {
+		return prop, fmt.Errorf("invalid content line %q", line)
+	}
+	prop.value = line[i+1:]
+	return prop, nil
+}
+
+func newEvent(props []property, loc *time.Location) (Event, error) {
+	var event Event
+	var duration string
+	for _, p := range props {
+		var err error
+		switch p.name {
+		case "UID":
+			event.UID = p.value
+		case "SUMMARY":
+			event.Summary = unescape(p.value)
+		case "DESCRIPTION":
+			event.Description = unescape(p.value)
+		case "LOCATION":
+			event.Location = unescape(p.value)
+		case "URL":
+			event.URL = p.value
+		case "STATUS":
+			event.Status = strings.ToUpper(p.value)
+		case "DTSTART":
+			event.Start, event.AllDay, err = parseTime(p, loc)
+		case "DTEND":
+			event.End, _, err = parseTime(p, loc)
+		case "DURATION":
+			duration = p.value
+		}
+		if err != nil {
+			return event, fmt.Errorf("%s: %v", p.name, err)
+		}
+	}
+
+	if event.Start.IsZero() {
+		return event, fmt.Errorf("event %q has no DTSTART", event.Summary)
+	}
+	if event.End.IsZero() {
+		switch {
+		case duration != "":
+			d, err := parseDuration(duration)
+			if err != nil {
+				return event, fmt.Errorf("DURATION: %v", err)
+			}
+			event.End = event.Start.Add(d)
+		case event.AllDay:
+			event.End = event.Start.AddDate(0, 0, 1)
+		default:
+			event.End = event.Start
+		}
+	}
+	return event, nil
+}
+
+// parseTime parses a DATE or DATE-TIME value, in UTC, in its TZID or
+// floating.
+func parseTime(p property, loc *time.Location) (time.Time, bool, error) {
+	if tzid, ok := p.params["TZID"]; ok {
+		if l, err := time.LoadLocation(strings.Trim(tzid, "/")); err == nil {
+			loc = l
+		}
+	}
+
+	value := p.value
+	switch {
+	case p.params["VALUE"] == "DATE" || len(value) == len("20060102"):
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	case strings.HasSuffix(value, "Z"):
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	default:
+		t, err := time.ParseInLocation("20060102T150405", value, loc)
+		return t, false, err
+	}
+}
+
+var durationRe = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseDuration parses a DURATION value, e.g. PT2H30M or P1D.
+func parseDuration(value string) (time.Duration, error) {
+	m := durationRe.FindStringSubmatch(value)
+	if m == nil || strings.HasSuffix(value, "P") || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+2] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+2])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		d += time.Duration(n) * unit
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
+
+// unescape decodes a TEXT value.
+func unescape(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i+1 == len(value) {
+			b.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			// \\ \; \,
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,156 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const kultureCalendar = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//La Kulture//Agenda//FR\r\n" +
+	"X-WR-CALNAME:Agenda de La Kulture\r\n" +
+	"BEGIN:VTIMEZONE\r\n" +
+	"TZID:Europe/Paris\r\n" +
+	"BEGIN:DAYLIGHT\r\n" +
+	"DTSTART:19700329T020000\r\n" +
+	"TZOFFSETFROM:+0100\r\n" +
+	"TZOFFSETTO:+0200\r\n" +
+	"END:DAYLIGHT\r\n" +
+	"END:VTIMEZONE\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:bal-20241224@lakulture.fr\r\n" +
+	"DTSTART;TZID=Europe/Paris:20241224T183000\r\n" +
+	"DTEND;TZID=Europe/Paris:20241224T233000\r\n" +
+	"SUMMARY:Bal Forró\\, avec initiation\r\n" +
+	"DESCRIPTION:Chaque mardi on danse le forró !\\n\\nEntrée libre\\; consomma\r\n" +
+	" tion sur place.\r\n" +
+	"LOCATION;LANGUAGE=fr:La Kulture\\, 9 rue des Bateliers\\, 67000 Strasbourg\r\n" +
+	"URL;VALUE=URI:https://lakulture.fr/agenda/bal-forro\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:concert-20241228@lakulture.fr\r\n" +
+	"DTSTART:20241228T190000Z\r\n" +
+	"DURATION:PT2H30M\r\n" +
+	"SUMMARY:Concert\r\n" +
+	"STATUS:cancelled\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:festival@lakulture.fr\r\n" +
+	"DTSTART;VALUE=DATE:20250105\r\n" +
+	"SUMMARY:Festival\r\n" +
+	"X-CUSTOM;X-PARAM=\"a:b;c\":ignored\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParse(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cal, err := Parse(strings.NewReader(kultureCalendar), paris)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if cal.Name != "Agenda de La Kulture" {
+		t.Errorf("Name = %q", cal.Name)
+	}
+	if len(cal.Events) != 3 {
+		t.Fatalf("got %d events, want 3", len(cal.Events))
+	}
+
+	bal := cal.Events[0]
+	want := Event{
+		UID:         "bal-20241224@lakulture.fr",
+		Summary:     "Bal Forró, avec initiation",
+		Description: "Chaque mardi on danse le forró !\n\nEntrée libre; consommation sur place.",
+		Location:    "La Kulture, 9 rue des Bateliers, 67000 Strasbourg",
+		URL:         "https://lakulture.fr/agenda/bal-forro",
+		Start:       time.Date(2024, 12, 24, 18, 30, 0, 0, paris),
+		End:         time.Date(2024, 12, 24, 23, 30, 0, 0, paris),
+	}
+	if bal.UID != want.UID || bal.Summary != want.Summary || bal.Description != want.Description ||
+		bal.Location != want.Location || bal.URL != want.URL || bal.AllDay {
+		t.Errorf("event = %+v, want %+v", bal, want)
+	}
+	if !bal.Start.Equal(want.Start) || !bal.End.Equal(want.End) {
+		t.Errorf("event from %v to %v, want from %v to %v", bal.Start, bal.End, want.Start, want.End)
+	}
+
+	concert := cal.Events[1]
+	if concert.Status != "CANCELLED" {
+		t.Errorf("Status = %q, want CANCELLED", concert.Status)
+	}
+	wantStart := time.Date(2024, 12, 28, 19, 0, 0, 0, time.UTC)
+	if !concert.Start.Equal(wantStart) || !concert.End.Equal(wantStart.Add(150*time.Minute)) {
+		t.Errorf("concert from %v to %v", concert.Start, concert.End)
+	}
+
+	festival := cal.Events[2]
+	if !festival.AllDay {
+		t.Error("festival should be all day")
+	}
+	if !festival.Start.Equal(time.Date(2025, 1, 5, 0, 0, 0, 0, paris)) || !festival.End.Equal(time.Date(2025, 1, 6, 0, 0, 0, 0, paris)) {
+		t.Errorf("festival from %v to %v", festival.Start, festival.End)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		calendar string
+	}{
+		{name: "unterminated calendar", calendar: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20241224T183000\nEND:VEVENT\n"},
+		{name: "mismatched end", calendar: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nEND:VCALENDAR\n"},
+		{name: "no start", calendar: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:Bal\nEND:VEVENT\nEND:VCALENDAR\n"},
+		{name: "invalid start", calendar: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:tomorrow\nEND:VEVENT\nEND:VCALENDAR\n"},
+		{name: "invalid duration", calendar: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20241224T183000\nDURATION:PT\nEND:VEVENT\nEND:VCALENDAR\n"},
+		{name: "invalid line", calendar: "BEGIN:VCALENDAR\nnot a property\nEND:VCALENDAR\n"},
+		{name: "unterminated quoted parameter", calendar: "BEGIN:VCALENDAR\nX-A;P=\"b:c\nEND:VCALENDAR\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(strings.NewReader(tt.calendar), time.UTC); err == nil {
+				t.Error("Expected error but got none")
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value       string
+		want        time.Duration
+		expectError bool
+	}{
+		{value: "PT2H30M", want: 150 * time.Minute},
+		{value: "P1D", want: 24 * time.Hour},
+		{value: "P1W", want: 7 * 24 * time.Hour},
+		{value: "P1DT1S", want: 24*time.Hour + time.Second},
+		{value: "-PT15M", want: -15 * time.Minute},
+		{value: "P", expectError: true},
+		{value: "PT", expectError: true},
+		{value: "2H", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseDuration(tt.value)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("parseDuration(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
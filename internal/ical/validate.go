@@ -0,0 +1,185 @@
+package ical
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Validate checks that data is an iCalendar feed calendar apps will read:
+// the RFC 5545 line format, the required properties of the calendar and its
+// events, unique UIDs and defined time zones. It returns all the problems
+// found, joined.
+func Validate(data []byte) error {
+	var errs []error
+	report := func(line int, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...)))
+	}
+
+	physical := bytes.Split(data, []byte("\n"))
+	if len(physical[len(physical)-1]) == 0 {
+		physical = physical[:len(physical)-1]
+	}
+	for i, line := range physical {
+		if !bytes.HasSuffix(line, []byte("\r")) {
+			report(i+1, "not ended by CRLF")
+		}
+		if n := len(bytes.TrimSuffix(line, []byte("\r"))); n > maxLineOctets {
+			report(i+1, "%d octets long, lines must be folded at %d", n, maxLineOctets)
+		}
+	}
+
+	lines, numbers, err := unfold(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	// Properties of the component being read, per nesting level
+	type component struct {
+		name  string
+		line  int
+		props map[string][]property
+	}
+	var stack []component
+	uids := map[string]int{}
+	timezones := map[string]bool{}
+	var tzRefs []struct {
+		tzid string
+		line int
+	}
+	calendars := 0
+
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		prop, err := parseLine(line)
+		if err != nil {
+			report(numbers[i], "%v", err)
+			continue
+		}
+
+		switch prop.name {
+		case "BEGIN":
+			stack = append(stack, component{name: strings.ToUpper(prop.value), line: numbers[i], props: map[string][]property{}})
+			continue
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].name != strings.ToUpper(prop.value) {
+				report(numbers[i], "unexpected END:%s", prop.value)
+				continue
+			}
+			c := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			required := map[string][]string{
+				"VCALENDAR": {"VERSION", "PRODID"},
+				"VEVENT":    {"UID", "DTSTAMP", "DTSTART"},
+				"VTIMEZONE": {"TZID"},
+				"VALARM":    {"ACTION", "TRIGGER"},
+			}[c.name]
+			for _, name := range required {
+				if len(c.props[name]) == 0 {
+					report(c.line, "%s without %s", c.name, name)
+				}
+			}
+
+			switch c.name {
+			case "VCALENDAR":
+				calendars++
+				if v := c.props["VERSION"]; len(v) > 0 && v[0].value != "2.0" {
+					report(c.line, "VERSION is %q, expected 2.0", v[0].value)
+				}
+			case "VTIMEZONE":
+				if tzid := c.props["TZID"]; len(tzid) > 0 {
+					timezones[tzid[0].value] = true
+				}
+			case "VEVENT":
+				validateEvent(c.line, c.props, uids, report)
+			}
+			continue
+		}
+
+		if len(stack) == 0 {
+			report(numbers[i], "%s outside of VCALENDAR", prop.name)
+			continue
+		}
+		c := stack[len(stack)-1]
+		c.props[prop.name] = append(c.props[prop.name], prop)
+		if tzid, ok := prop.params["TZID"]; ok {
+			tzRefs = append(tzRefs, struct {
+				tzid string
+				line int
+			}{tzid, numbers[i]})
+		}
+	}
+
+	for _, c := range stack {
+		report(c.line, "unterminated %s", c.name)
+	}
+	if calendars == 0 {
+		errs = append(errs, errors.New("no VCALENDAR"))
+	}
+	for _, ref := range tzRefs {
+		if !timezones[ref.tzid] {
+			report(ref.line, "TZID %s has no VTIMEZONE", ref.tzid)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateEvent(line int, props map[string][]property, uids map[string]int, report func(int, string, ...any)) {
+	for name, values := range props {
+		switch name {
+		case "UID", "DTSTAMP", "DTSTART", "DTEND", "SUMMARY", "DESCRIPTION", "LOCATION", "URL", "STATUS", "DURATION":
+			if len(values) > 1 {
+				report(line, "VEVENT with %d %s", len(values), name)
+			}
+		}
+	}
+
+	if uid := props["UID"]; len(uid) > 0 {
+		if first, ok := uids[uid[0].value]; ok {
+			report(line, "UID %s already used by the VEVENT line %d", uid[0].value, first)
+		} else {
+			uids[uid[0].value] = line
+		}
+	}
+
+	if stamp := props["DTSTAMP"]; len(stamp) > 0 {
+		if _, err := time.Parse("20060102T150405Z", stamp[0].value); err != nil {
+			report(line, "DTSTAMP %q is not a UTC date-time", stamp[0].value)
+		}
+	}
+
+	if status := props["STATUS"]; len(status) > 0 {
+		switch status[0].value {
+		case "TENTATIVE", "CONFIRMED", "CANCELLED":
+		default:
+			report(line, "invalid STATUS %q", status[0].value)
+		}
+	}
+
+	if len(props["DTEND"]) > 0 && len(props["DURATION"]) > 0 {
+		report(line, "VEVENT with both DTEND and DURATION")
+	}
+
+	var start, end time.Time
+	if p := props["DTSTART"]; len(p) > 0 {
+		var err error
+		if start, _, err = parseTime(p[0], time.UTC); err != nil {
+			report(line, "invalid DTSTART: %v", err)
+		}
+	}
+	if p := props["DTEND"]; len(p) > 0 {
+		var err error
+		if end, _, err = parseTime(p[0], time.UTC); err != nil {
+			report(line, "invalid DTEND: %v", err)
+		}
+	}
+	if !start.IsZero() && !end.IsZero() && end.Before(start) {
+		report(line, "DTEND before DTSTART")
+	}
+}
@@ -0,0 +1,100 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	crlf := func(lines ...string) []byte {
+		return []byte(strings.Join(lines, "\r\n") + "\r\n")
+	}
+	event := func(uid string, props ...string) []string {
+		lines := []string{"BEGIN:VEVENT", "UID:" + uid, "DTSTAMP:20241201T100000Z", "DTSTART:20241224T173000Z"}
+		lines = append(lines, props...)
+		return append(lines, "END:VEVENT")
+	}
+	calendar := func(lines ...[]string) []byte {
+		all := []string{"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//test//EN"}
+		for _, l := range lines {
+			all = append(all, l...)
+		}
+		return crlf(append(all, "END:VCALENDAR")...)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr []string
+	}{
+		{
+			name: "valid",
+			data: calendar(event("a", "DTEND:20241224T223000Z", "STATUS:CANCELLED"), event("b")),
+		},
+		{
+			name:    "LF line endings",
+			data:    []byte(strings.ReplaceAll(string(calendar(event("a"))), "\r\n", "\n")),
+			wantErr: []string{"line 1: not ended by CRLF"},
+		},
+		{
+			name:    "line too long",
+			data:    calendar(event("a", "SUMMARY:"+strings.Repeat("a", 70))),
+			wantErr: []string{"line 8: 78 octets long"},
+		},
+		{
+			name:    "missing properties",
+			data:    crlf("BEGIN:VCALENDAR", "BEGIN:VEVENT", "SUMMARY:Bal", "END:VEVENT", "END:VCALENDAR"),
+			wantErr: []string{"VCALENDAR without VERSION", "VCALENDAR without PRODID", "VEVENT without UID", "VEVENT without DTSTAMP", "VEVENT without DTSTART"},
+		},
+		{
+			name:    "duplicate UID",
+			data:    calendar(event("a"), event("a")),
+			wantErr: []string{"line 9: UID a already used by the VEVENT line 4"},
+		},
+		{
+			name:    "local DTSTAMP",
+			data:    calendar([]string{"BEGIN:VEVENT", "UID:a", "DTSTAMP:20241201T100000", "DTSTART:20241224T173000Z", "END:VEVENT"}),
+			wantErr: []string{`DTSTAMP "20241201T100000" is not a UTC date-time`},
+		},
+		{
+			name:    "undefined time zone",
+			data:    calendar(event("a", "DTEND;TZID=Europe/Paris:20241224T233000")),
+			wantErr: []string{"TZID Europe/Paris has no VTIMEZONE"},
+		},
+		{
+			name:    "end before start",
+			data:    calendar(event("a", "DTEND:20241223T233000Z")),
+			wantErr: []string{"DTEND before DTSTART"},
+		},
+		{
+			name:    "invalid status",
+			data:    calendar(event("a", "STATUS:MAYBE")),
+			wantErr: []string{`invalid STATUS "MAYBE"`},
+		},
+		{
+			name:    "unterminated",
+			data:    crlf("BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//test//EN"),
+			wantErr: []string{"unterminated VCALENDAR", "no VCALENDAR"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.data)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Expected error but got none")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error doesn't tell %q:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package publish
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/calendar"
+)
+
+// runCalendarCommand implements the "calendar" command: it writes the
+// calendar feed of the events, or checks the one rendered by Hugo.
+func runCalendarCommand(args []string, w io.Writer) error {
+	fs := newFlagSet("calendar", "")
+	dir := fs.String("dir", eventsDir, "Directory of the event pages")
+	format := fs.String("format", "ics", "Feed format: 'ics' or 'json'")
+	output := fs.String("o", "", "File to write the feed to (defaults to the standard output)")
+	strict := fs.Bool("strict", false, "Fail instead of skipping the event pages that can't be read")
+	verify := fs.String("verify", "", "Instead of writing the feed, check this iCalendar feed rendered by Hugo (e.g. public/evenements/index.ics) against the events")
+	fs.Parse(args)
+
+	venues, err := loadVenues()
+	if err != nil {
+		return err
+	}
+	events, err := loadEvents(*dir, venues, *strict)
+	if err != nil {
+		return err
+	}
+
+	if *verify != "" {
+		feed, err := os.ReadFile(*verify)
+		if err != nil {
+			return err
+		}
+		if err := calendar.Verify(feed, events); err != nil {
+			return fmt.Errorf("%s doesn't match the events:\n%v", *verify, err)
+		}
+		fmt.Fprintf(w, "%s is valid and lists the %d events\n", *verify, len(events))
+		return nil
+	}
+
+	var feed bytes.Buffer
+	switch *format {
+	case "ics":
+		err = calendar.WriteICS(&feed, events, time.Now())
+	case "json":
+		err = calendar.WriteJSON(&feed, events)
+	default:
+		err = fmt.Errorf("unknown format %q, expected 'ics' or 'json'", *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = w.Write(feed.Bytes())
+		return err
+	}
+	return os.WriteFile(*output, feed.Bytes(), 0o644)
+}
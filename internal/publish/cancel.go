@@ -0,0 +1,87 @@
+package publish
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/osfs"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/event"
+)
+
+var cancelledRe = regexp.MustCompile(`(?m)^cancelled:.*$`)
+
+// cancelEvent marks the event page as cancelled: the event stays listed, as
+// cancelled, on the site and in the calendar feeds. The rest of the page is
+// kept as written.
+func cancelEvent(content []byte) ([]byte, error) {
+	e, err := event.Parse(content)
+	if err != nil {
+		return nil, err
+	}
+	if e.Cancelled {
+		return nil, fmt.Errorf("%q is already cancelled", e.Title)
+	}
+
+	// Replace a "cancelled: false" of the front matter
+	frontMatter, body, _ := strings.Cut(string(content)[len("---\n"):], "\n---")
+	if cancelledRe.MatchString(frontMatter) {
+		frontMatter = cancelledRe.ReplaceAllString(frontMatter, "cancelled: true")
+		return []byte("---\n" + frontMatter + "\n---" + body), nil
+	}
+	return appendFrontMatter(content, "cancelled: true\n")
+}
+
+// eventPagePath finds the event page of the slug (e.g. 241224-bal) or path.
+func eventPagePath(dir, page string) (string, error) {
+	for _, path := range []string{page, filepath.Join(dir, page), filepath.Join(dir, page+".md")} {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no event page %s in %s", page, dir)
+}
+
+// runCancelCommand implements the "cancel" command: it marks an event page as
+// cancelled, e.g. after a no-go of the weather check.
+func runCancelCommand(args []string, w io.Writer) error {
+	fs := newFlagSet("cancel", "<event page or slug>")
+	dir := fs.String("dir", eventsDir, "Directory of the event pages")
+	dryRun := fs.Bool("dry-run", false, "Only show the change to the event page")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one event page, got %d", fs.NArg())
+	}
+	path, err := eventPagePath(*dir, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	cancelled, err := cancelEvent(content)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	diff, _, err := eventDiff(osfs.New(""), path, cancelled)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(w, diff)
+	if *dryRun {
+		return nil
+	}
+	if err := os.WriteFile(path, cancelled, 0o644); err != nil {
+		return fmt.Errorf("failed to write the event: %v", err)
+	}
+	fmt.Fprintf(w, "Cancelled %s, commit and push it to update the site\n", path)
+	return nil
+}
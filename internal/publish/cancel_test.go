@@ -0,0 +1,83 @@
+package publish
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCancelEvent(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		want        string
+		errContains string
+	}{
+		{
+			name:    "append",
+			content: "---\ntitle: Bal\nstartDate: 2024-12-24T20:00:00+01:00\n---\n\nBody\n",
+			want:    "---\ntitle: Bal\nstartDate: 2024-12-24T20:00:00+01:00\ncancelled: true\n---\n\nBody\n",
+		},
+		{
+			name:    "replace",
+			content: "---\ntitle: Bal\nstartDate: 2024-12-24T20:00:00+01:00\ncancelled: false\nplace: Kulture\n---\n\nBody\n",
+			want:    "---\ntitle: Bal\nstartDate: 2024-12-24T20:00:00+01:00\ncancelled: true\nplace: Kulture\n---\n\nBody\n",
+		},
+		{
+			name:        "already cancelled",
+			content:     "---\ntitle: Bal\nstartDate: 2024-12-24T20:00:00+01:00\ncancelled: true\n---\n",
+			errContains: "already cancelled",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cancelEvent([]byte(tt.content))
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("error = %v, want %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunCancelCommand(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "241224-bal.md")
+	content := "---\ntitle: Bal\nstartDate: 2024-12-24T20:00:00+01:00\n---\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := runCancelCommand([]string{"-dir", dir, "-dry-run", "241224-bal"}, &buf); err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if !strings.Contains(buf.String(), "+cancelled: true") {
+		t.Errorf("dry run without the diff:\n%s", buf.String())
+	}
+	if b, _ := os.ReadFile(path); string(b) != content {
+		t.Errorf("dry run changed the page:\n%s", b)
+	}
+
+	buf.Reset()
+	if err := runCancelCommand([]string{"-dir", dir, "241224-bal"}, &buf); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if b, _ := os.ReadFile(path); !strings.Contains(string(b), "cancelled: true") {
+		t.Errorf("page not cancelled:\n%s", b)
+	}
+
+	if err := runCancelCommand([]string{"-dir", dir, "241225-bal"}, &buf); err == nil {
+		t.Error("Expected error but got none")
+	}
+}
@@ -0,0 +1,237 @@
+package publish
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/event"
+)
+
+const defaultCatalogPath = "content/evenements/templates/catalog.yaml"
+
+// TemplateInfo holds the metadata of an event template from the catalog.
+type TemplateInfo struct {
+	Name          string   `yaml:"name"`
+	File          string   `yaml:"file"`
+	Description   string   `yaml:"description"`
+	Weekday       string   `yaml:"weekday"`
+	Time          string   `yaml:"time"`
+	Recurrence    string   `yaml:"recurrence"`
+	FacebookPages []string `yaml:"facebook_pages"`
+	Chats         []string `yaml:"chats"`
+	// Category and Tags are the defaults of the events, for the templates
+	// that don't tell.
+	Category string   `yaml:"category"`
+	Tags     []string `yaml:"tags"`
+}
+
+// templateCatalog is the list of known templates, as read from catalog.yaml.
+type templateCatalog struct {
+	Templates []TemplateInfo `yaml:"templates"`
+}
+
+var timeOfDayRe = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+var recurrences = []string{"", "weekly", "biweekly", "monthly"}
+
+// loadTemplateCatalog reads and validates the catalog at path. Template files
+// are resolved relative to the catalog directory. A missing catalog is not an
+// error: it yields an empty catalog so raw template paths keep working.
+func loadTemplateCatalog(path string) (templateCatalog, error) {
+	var catalog templateCatalog
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return catalog, nil
+	}
+	if err != nil {
+		return catalog, fmt.Errorf("failed to read template catalog: %v", err)
+	}
+
+	if err := yaml.Unmarshal(b, &catalog); err != nil {
+		return catalog, fmt.Errorf("failed to parse template catalog %s: %v", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	seen := map[string]bool{}
+	for i, t := range catalog.Templates {
+		if t.Name == "" {
+			return catalog, fmt.Errorf("template catalog %s: entry %d has no name", path, i+1)
+		}
+		if seen[t.Name] {
+			return catalog, fmt.Errorf("template catalog %s: duplicate template %q", path, t.Name)
+		}
+		seen[t.Name] = true
+
+		if t.File == "" {
+			return catalog, fmt.Errorf("template catalog %s: template %q has no file", path, t.Name)
+		}
+		if t.Weekday != "" {
+			if _, err := parseWeekday(t.Weekday); err != nil {
+				return catalog, fmt.Errorf("template catalog %s: template %q: %v", path, t.Name, err)
+			}
+		}
+		if t.Time != "" && !timeOfDayRe.MatchString(t.Time) {
+			return catalog, fmt.Errorf("template catalog %s: template %q: invalid time %q, expected HH:MM", path, t.Name, t.Time)
+		}
+		if !event.ValidCategory(t.Category) {
+			return catalog, fmt.Errorf("template catalog %s: template %q: unknown category %q, expected one of %s", path, t.Name, t.Category, strings.Join(event.Categories, ", "))
+		}
+		if !contains(recurrences, t.Recurrence) {
+			return catalog, fmt.Errorf("template catalog %s: template %q: unknown recurrence %q", path, t.Name, t.Recurrence)
+		}
+
+		catalog.Templates[i].File = filepath.Join(dir, t.File)
+	}
+
+	return catalog, nil
+}
+
+// lookup returns the catalog template with the given name.
+func (c templateCatalog) lookup(name string) (TemplateInfo, bool) {
+	for _, t := range c.Templates {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return TemplateInfo{}, false
+}
+
+// resolveTemplate finds a template by catalog name, falling back to a path
+// on disk for templates that aren't in the catalog.
+func (c templateCatalog) resolveTemplate(nameOrPath string) (TemplateInfo, error) {
+	if t, ok := c.lookup(nameOrPath); ok {
+		return t, nil
+	}
+
+	// A path to a catalog template still gets its metadata.
+	for _, t := range c.Templates {
+		if sameFile(t.File, nameOrPath) {
+			return t, nil
+		}
+	}
+
+	if _, err := os.Stat(nameOrPath); err != nil {
+		return TemplateInfo{}, fmt.Errorf("template file does not exist: %s", nameOrPath)
+	}
+
+	// A template outside of the catalog only knows its file.
+	return TemplateInfo{File: nameOrPath}, nil
+}
+
+// slug returns the name used in the event filename and URL.
+func (t TemplateInfo) slug() string {
+	if t.Name != "" {
+		return t.Name
+	}
+
+	baseName := filepath.Base(t.File)
+	baseName = strings.TrimSuffix(baseName, ".template")
+	baseName = strings.TrimSuffix(baseName, ".md")
+	return baseName
+}
+
+func sameFile(a, b string) bool {
+	sa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	sb, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(sa, sb)
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// parseWeekday parses an english or french weekday name.
+func parseWeekday(s string) (time.Weekday, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		date := time.Date(2024, 12, 22+int(d), 0, 0, 0, 0, time.UTC) // 2024-12-22 is a sunday
+		if s == getWeekdayName(date, "en") || s == getWeekdayName(date, "fr") {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday %q", s)
+}
+
+// nextWeekday returns the first date on or after from falling on weekday.
+func nextWeekday(from time.Time, weekday time.Weekday) time.Time {
+	days := (int(weekday) - int(from.Weekday()) + 7) % 7
+	year, month, day := from.Date()
+	return time.Date(year, month, day+days, 0, 0, 0, 0, time.UTC)
+}
+
+// runTemplatesCommand implements the "templates list" and "templates show
+// <name>" commands.
+func runTemplatesCommand(args []string, w io.Writer) error {
+	fs := newFlagSet("templates", "list|show <name>")
+	catalogPath := fs.String("catalog", defaultCatalogPath, "Path to the template catalog")
+	fs.Parse(args)
+
+	catalog, err := loadTemplateCatalog(*catalogPath)
+	if err != nil {
+		return err
+	}
+
+	switch fs.Arg(0) {
+	case "list", "":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tCATEGORY\tWEEKDAY\tTIME\tRECURRENCE\tDESCRIPTION")
+		for _, t := range catalog.Templates {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", t.Name, orDash(t.Category), orDash(t.Weekday), orDash(t.Time), orDash(t.Recurrence), t.Description)
+		}
+		return tw.Flush()
+
+	case "show":
+		name := fs.Arg(1)
+		if name == "" {
+			return errors.New("templates show: missing template name")
+		}
+		t, ok := catalog.lookup(name)
+		if !ok {
+			return fmt.Errorf("templates show: unknown template %q", name)
+		}
+
+		fmt.Fprintf(w, "Name:           %s\n", t.Name)
+		fmt.Fprintf(w, "Description:    %s\n", t.Description)
+		fmt.Fprintf(w, "File:           %s\n", t.File)
+		fmt.Fprintf(w, "Weekday:        %s\n", orDash(t.Weekday))
+		fmt.Fprintf(w, "Time:           %s\n", orDash(t.Time))
+		fmt.Fprintf(w, "Recurrence:     %s\n", orDash(t.Recurrence))
+		fmt.Fprintf(w, "Category:       %s\n", orDash(t.Category))
+		fmt.Fprintf(w, "Tags:           %s\n", orDash(strings.Join(t.Tags, ", ")))
+		fmt.Fprintf(w, "Facebook pages: %s\n", orDash(strings.Join(t.FacebookPages, ", ")))
+		fmt.Fprintf(w, "Chats:          %s\n", orDash(strings.Join(t.Chats, ", ")))
+		return nil
+
+	default:
+		fs.Usage()
+		return fmt.Errorf("templates: unknown command %q", fs.Arg(0))
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
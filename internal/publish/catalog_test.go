@@ -0,0 +1,252 @@
+package publish
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadTemplateCatalog(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantNames   []string
+		errContains string
+	}{
+		{
+			name: "valid catalog",
+			content: `templates:
+  - name: bal-social-bar
+    file: bal-social-bar.template
+    weekday: mardi
+    time: "19:00"
+    recurrence: weekly
+    facebook_pages: [forro-stras]
+    category: bal
+    tags: [initiation]
+  - name: okivu
+    file: okivu.md.template
+`,
+			wantNames: []string{"bal-social-bar", "okivu"},
+		},
+		{
+			name: "duplicate name",
+			content: `templates:
+  - name: okivu
+    file: okivu.md.template
+  - name: okivu
+    file: okivu2.md.template
+`,
+			errContains: "duplicate template",
+		},
+		{
+			name: "missing file",
+			content: `templates:
+  - name: okivu
+`,
+			errContains: "has no file",
+		},
+		{
+			name: "invalid weekday",
+			content: `templates:
+  - name: okivu
+    file: okivu.md.template
+    weekday: someday
+`,
+			errContains: "unknown weekday",
+		},
+		{
+			name: "invalid time",
+			content: `templates:
+  - name: okivu
+    file: okivu.md.template
+    time: 7pm
+`,
+			errContains: "invalid time",
+		},
+		{
+			name: "invalid recurrence",
+			content: `templates:
+  - name: okivu
+    file: okivu.md.template
+    recurrence: yearly
+`,
+			errContains: "unknown recurrence",
+		},
+		{
+			name: "invalid category",
+			content: `templates:
+  - name: okivu
+    file: okivu.md.template
+    category: soirée
+`,
+			errContains: `unknown category "soirée"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			catalogPath := filepath.Join(tmpDir, "catalog.yaml")
+			if err := os.WriteFile(catalogPath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to create catalog: %v", err)
+			}
+
+			catalog, err := loadTemplateCatalog(catalogPath)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadTemplateCatalog() unexpected error: %v", err)
+			}
+
+			if len(catalog.Templates) != len(tt.wantNames) {
+				t.Fatalf("got %d templates, want %d", len(catalog.Templates), len(tt.wantNames))
+			}
+			for i, name := range tt.wantNames {
+				got := catalog.Templates[i]
+				if got.Name != name {
+					t.Errorf("template %d name = %q, want %q", i, got.Name, name)
+				}
+				if filepath.Dir(got.File) != tmpDir {
+					t.Errorf("template %q file = %q, want it relative to %q", got.Name, got.File, tmpDir)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadTemplateCatalogMissing(t *testing.T) {
+	catalog, err := loadTemplateCatalog(filepath.Join(t.TempDir(), "catalog.yaml"))
+	if err != nil {
+		t.Fatalf("loadTemplateCatalog() unexpected error: %v", err)
+	}
+	if len(catalog.Templates) != 0 {
+		t.Errorf("got %d templates, want none", len(catalog.Templates))
+	}
+}
+
+func TestResolveTemplate(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"bal-social-bar.template", "other.md.template"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte("---\n---\n"), 0644); err != nil {
+			t.Fatalf("Failed to create template: %v", err)
+		}
+	}
+	catalog := templateCatalog{Templates: []TemplateInfo{
+		{Name: "social-bar", File: filepath.Join(tmpDir, "bal-social-bar.template"), Time: "19:00"},
+	}}
+
+	tests := []struct {
+		name     string
+		input    string
+		wantSlug string
+		wantTime string
+		wantErr  bool
+	}{
+		{name: "by name", input: "social-bar", wantSlug: "social-bar", wantTime: "19:00"},
+		{name: "by path of a catalog template", input: filepath.Join(tmpDir, "bal-social-bar.template"), wantSlug: "social-bar", wantTime: "19:00"},
+		{name: "by path outside of the catalog", input: filepath.Join(tmpDir, "other.md.template"), wantSlug: "other"},
+		{name: "unknown", input: "unknown", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := catalog.resolveTemplate(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("resolveTemplate() expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveTemplate() unexpected error: %v", err)
+			}
+			if info.slug() != tt.wantSlug {
+				t.Errorf("slug = %q, want %q", info.slug(), tt.wantSlug)
+			}
+			if info.Time != tt.wantTime {
+				t.Errorf("Time = %q, want %q", info.Time, tt.wantTime)
+			}
+		})
+	}
+}
+
+func TestNextWeekday(t *testing.T) {
+	monday := time.Date(2024, 12, 23, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		weekday  time.Weekday
+		expected time.Time
+	}{
+		{name: "same day", weekday: time.Monday, expected: time.Date(2024, 12, 23, 0, 0, 0, 0, time.UTC)},
+		{name: "later this week", weekday: time.Tuesday, expected: time.Date(2024, 12, 24, 0, 0, 0, 0, time.UTC)},
+		{name: "next week", weekday: time.Sunday, expected: time.Date(2024, 12, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nextWeekday(monday, tt.weekday)
+			if !got.Equal(tt.expected) {
+				t.Errorf("nextWeekday() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseWeekday(t *testing.T) {
+	for input, want := range map[string]time.Weekday{"monday": time.Monday, "Mardi": time.Tuesday, "dimanche": time.Sunday} {
+		got, err := parseWeekday(input)
+		if err != nil {
+			t.Errorf("parseWeekday(%q) unexpected error: %v", input, err)
+		} else if got != want {
+			t.Errorf("parseWeekday(%q) = %v, want %v", input, got, want)
+		}
+	}
+	if _, err := parseWeekday("someday"); err == nil {
+		t.Error("parseWeekday() expected error but got none")
+	}
+}
+
+func TestRepositoryCatalog(t *testing.T) {
+	catalog, err := loadTemplateCatalog(filepath.Join("..", "..", defaultCatalogPath))
+	if err != nil {
+		t.Fatalf("loadTemplateCatalog() unexpected error: %v", err)
+	}
+	for _, info := range catalog.Templates {
+		if _, err := os.Stat(info.File); err != nil {
+			t.Errorf("template %q: %v", info.Name, err)
+		}
+	}
+}
+
+func TestRunTemplatesCommand(t *testing.T) {
+	catalogPath := filepath.Join("..", "..", defaultCatalogPath)
+
+	var out bytes.Buffer
+	if err := runTemplatesCommand([]string{"-catalog", catalogPath, "list"}, &out); err != nil {
+		t.Fatalf("templates list unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "bal-social-bar") {
+		t.Errorf("templates list output doesn't contain bal-social-bar:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := runTemplatesCommand([]string{"-catalog", catalogPath, "show", "bal-kulture"}, &out); err != nil {
+		t.Fatalf("templates show unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "tuesday") {
+		t.Errorf("templates show output doesn't contain the weekday:\n%s", out.String())
+	}
+
+	if err := runTemplatesCommand([]string{"-catalog", catalogPath, "show", "unknown"}, &out); err == nil {
+		t.Error("templates show expected error for an unknown template")
+	}
+}
@@ -0,0 +1,61 @@
+package publish
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// UsageName returns the name of a command in the usage messages, e.g.
+// "publish lint", the empty name being the publishing of an event. The forro
+// CLI replaces it by its own command names.
+var UsageName = func(name string) string {
+	return strings.TrimSpace("publish " + name)
+}
+
+// SelfCommand is the command line running Run, re-executed by the scheduler
+// for the publish jobs. It is this very program if empty.
+var SelfCommand []string
+
+// newFlagSet returns the flags of the command, with the usage message
+// telling its arguments.
+func newFlagSet(name, arguments string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		usage := strings.TrimSpace(UsageName(name) + " [flags] " + arguments)
+		fmt.Fprintf(fs.Output(), "Usage: %s\n\nFlags:\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// Run runs the command of args[0], or publishes the event of a template if
+// args[0] isn't a command.
+func Run(args []string, w io.Writer) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "templates":
+			return runTemplatesCommand(args[1:], w)
+		case "serve":
+			return runServeCommand(args[1:])
+		case "wizard":
+			return runWizardCommand(args[1:])
+		case "import-ics":
+			return runImportICSCommand(args[1:], w)
+		case "calendar":
+			return runCalendarCommand(args[1:], w)
+		case "jsonld":
+			return runJSONLDCommand(args[1:], w)
+		case "map":
+			return runMapCommand(args[1:], w)
+		case "lint":
+			return runLintCommand(args[1:], w)
+		case "scheduler":
+			return runSchedulerCommand(args[1:], w)
+		case "cancel":
+			return runCancelCommand(args[1:], w)
+		}
+	}
+	return runEventCommand(args)
+}
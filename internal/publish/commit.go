@@ -0,0 +1,120 @@
+package publish
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// defaultCommitMessage is the subject of the publish commits, unless
+// configured otherwise.
+const defaultCommitMessage = "Add event for {{.Date}} based on template {{.TemplateFile}}"
+
+// commitSettings configures the commit publishing an event.
+type commitSettings struct {
+	// Message is a text/template for the commit message, given a
+	// commitMessageData. Defaults to defaultCommitMessage.
+	Message string
+	// Author overrides the commit author, as "Name <email>", e.g. for bots.
+	// The committer stays whoever runs the publisher.
+	Author string
+	// Targets lists where the event is published, e.g. "site" or
+	// "facebook:forro-stras". Defaults to the site only.
+	Targets []string
+}
+
+// commitMessageData is given to the commit message template.
+type commitMessageData struct {
+	Date         string // YYYY-MM-DD
+	Title        string // Event title
+	Slug         string // e.g. 241224-bal
+	URL          string // Event page
+	Template     string // Catalog name of the template, or its file name
+	TemplateFile string // File name of the template
+}
+
+var authorRe = regexp.MustCompile(`^[^<>]+ <[^<>]*>$`)
+
+// validate checks the settings before anything gets written.
+func (c commitSettings) validate() error {
+	if c.Author != "" && !authorRe.MatchString(c.Author) {
+		return fmt.Errorf("invalid commit author %q, expected 'Name <email>'", c.Author)
+	}
+	if _, err := c.template(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c commitSettings) template() (*template.Template, error) {
+	msg := c.Message
+	if msg == "" {
+		msg = defaultCommitMessage
+	}
+	tmpl, err := template.New("commit").Option("missingkey=error").Parse(msg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid commit message template: %v", err)
+	}
+	return tmpl, nil
+}
+
+// subject renders the commit message template. It is also used as pull
+// request title.
+func (c commitSettings) subject(data commitMessageData) (string, error) {
+	tmpl, err := c.template()
+	if err != nil {
+		return "", err
+	}
+
+	var msg bytes.Buffer
+	if err := tmpl.Execute(&msg, data); err != nil {
+		return "", fmt.Errorf("error executing commit message template: %v", err)
+	}
+	return strings.TrimSpace(msg.String()), nil
+}
+
+// trailers returns the git trailers identifying the published event, to
+// find it in the history with e.g.
+// git log --format='%h %(trailers:key=Event-Slug,valueonly)'.
+func (c commitSettings) trailers(data commitMessageData) string {
+	targets := c.Targets
+	if len(targets) == 0 {
+		targets = []string{"site"}
+	}
+	return fmt.Sprintf("Event-Slug: %s\nTemplate: %s\nTargets: %s", data.Slug, data.Template, strings.Join(targets, ", "))
+}
+
+// args returns the arguments of the git commit command.
+func (c commitSettings) args(data commitMessageData) ([]string, error) {
+	subject, err := c.subject(data)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"commit", "-m", subject, "-m", c.trailers(data)}
+	if c.Author != "" {
+		args = append(args, "--author="+c.Author)
+	}
+	return args, nil
+}
+
+// newCommitMessageData gathers what the commit message can use about the
+// event.
+func newCommitMessageData(tmplInfo TemplateInfo, dateStr, outputPath, eventURL string, fmData FrontMatterData) commitMessageData {
+	templateFile := filepath.Base(tmplInfo.File)
+	name := tmplInfo.Name
+	if name == "" {
+		name = templateFile
+	}
+	return commitMessageData{
+		Date:         dateStr,
+		Title:        fmData.Title,
+		Slug:         strings.TrimSuffix(filepath.Base(outputPath), filepath.Ext(outputPath)),
+		URL:          eventURL,
+		Template:     name,
+		TemplateFile: templateFile,
+	}
+}
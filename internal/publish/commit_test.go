@@ -0,0 +1,99 @@
+package publish
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCommitSettingsArgs(t *testing.T) {
+	data := newCommitMessageData(
+		TemplateInfo{Name: "pachamamas", File: "content/evenements/templates/pachamamas.md.template"},
+		"2024-12-24",
+		"content/evenements/241224-pachamamas.md",
+		"https://forrostrasbourg.fr/evenements/241224-pachamamas/",
+		FrontMatterData{Title: "Bal aux Pachamamas"},
+	)
+
+	tests := []struct {
+		name        string
+		settings    commitSettings
+		want        []string
+		expectError bool
+	}{
+		{
+			name:     "defaults",
+			settings: commitSettings{},
+			want: []string{
+				"commit",
+				"-m", "Add event for 2024-12-24 based on template pachamamas.md.template",
+				"-m", "Event-Slug: 241224-pachamamas\nTemplate: pachamamas\nTargets: site",
+			},
+		},
+		{
+			name: "custom message, author and targets",
+			settings: commitSettings{
+				Message: "Publish {{.Title}} ({{.Slug}})",
+				Author:  "Forró bot <bot@forrostrasbourg.fr>",
+				Targets: []string{"site", "facebook:forro-stras"},
+			},
+			want: []string{
+				"commit",
+				"-m", "Publish Bal aux Pachamamas (241224-pachamamas)",
+				"-m", "Event-Slug: 241224-pachamamas\nTemplate: pachamamas\nTargets: site, facebook:forro-stras",
+				"--author=Forró bot <bot@forrostrasbourg.fr>",
+			},
+		},
+		{
+			name:        "unknown field",
+			settings:    commitSettings{Message: "Publish {{.Place}}"},
+			expectError: true,
+		},
+		{
+			name:        "invalid template",
+			settings:    commitSettings{Message: "Publish {{.Title"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.settings.args(data)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("args = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommitSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		settings    commitSettings
+		expectError bool
+	}{
+		{name: "defaults", settings: commitSettings{}},
+		{name: "author", settings: commitSettings{Author: "Bot <bot@example.com>"}},
+		{name: "author without email", settings: commitSettings{Author: "Bot"}, expectError: true},
+		{name: "invalid template", settings: commitSettings{Message: "{{.Date"}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.settings.validate()
+			if tt.expectError && err == nil {
+				t.Error("Expected error but got none")
+			} else if !tt.expectError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
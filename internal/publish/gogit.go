@@ -0,0 +1,458 @@
+package publish
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+// goGitBackend implements the git commands used by the publisher in process
+// with go-git, so publishing works without a git binary. The commands run at
+// the root of the worktree.
+type goGitBackend struct {
+	storer storage.Storer
+	// files is the worktree.
+	files billy.Filesystem
+}
+
+// newGoGitBackend returns the backend of the repository of the working
+// directory, which must be the root of the worktree.
+func newGoGitBackend() goGitBackend {
+	files := osfs.New(".")
+	dotGit := osfs.New(git.GitDirName)
+	return goGitBackend{
+		storer: filesystem.NewStorage(dotGit, cache.NewObjectLRUDefault()),
+		files:  files,
+	}
+}
+
+// open returns the repository.
+func (b goGitBackend) open() (*git.Repository, error) {
+	return git.Open(b.storer, b.files)
+}
+
+// worktree opens the repository and returns its worktree.
+func (b goGitBackend) worktree() (*git.Repository, *git.Worktree, error) {
+	repo, err := b.open()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open git repository: %v", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open git worktree: %v", err)
+	}
+	return repo, wt, nil
+}
+
+// relPath converts a path relative to dir, itself relative to the root of
+// the worktree, into a slash separated path relative to the root of the
+// worktree, as go-git expects.
+func relPath(dir, path string) (string, error) {
+	rel := filepath.Join(dir, path)
+	if filepath.IsAbs(path) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside of the repository", path)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// runCommand is a gitCommandRunner supporting the subset of git commands the
+// publisher uses.
+func (b goGitBackend) runCommand(dir string, args ...string) (string, error) {
+	if len(args) == 0 {
+		return "", errors.New("no git command given")
+	}
+
+	var err error
+	var output string
+	switch args[0] {
+	case "init":
+		_, err = git.Init(b.storer, b.files)
+	case "add":
+		err = b.add(dir, args[1:])
+	case "commit":
+		output, err = b.commit(dir, args[1:])
+	case "fetch":
+		err = b.fetch(dir, args[1:])
+	case "push":
+		err = b.push(dir, args[1:])
+	case "checkout":
+		err = b.checkout(dir, args[1:])
+	case "branch":
+		err = b.branch(dir, args[1:])
+	case "rev-parse":
+		output, err = b.revParse(dir, args[1:])
+	case "log":
+		output, err = b.log(dir, args[1:])
+	case "show":
+		output, err = b.show(dir, args[1:])
+	default:
+		err = errors.New("command not supported by the go backend")
+	}
+	if err != nil {
+		return output, fmt.Errorf("failed to run git command '%v': %v", args, err)
+	}
+	return output, nil
+}
+
+func (b goGitBackend) add(dir string, paths []string) error {
+	_, wt, err := b.worktree()
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		rel, err := relPath(dir, p)
+		if err != nil {
+			return err
+		}
+		if _, err := wt.Add(rel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commit supports "commit -m <message>... [--author=<Name <email>>]".
+func (b goGitBackend) commit(dir string, args []string) (string, error) {
+	var paragraphs []string
+	var author string
+	for i := 0; i < len(args); i++ {
+		if a, ok := strings.CutPrefix(args[i], "--author="); ok {
+			author = a
+			continue
+		}
+		if args[i] != "-m" || i+1 == len(args) {
+			return "", fmt.Errorf("unsupported commit option %q", args[i])
+		}
+		i++
+		paragraphs = append(paragraphs, args[i])
+	}
+	if len(paragraphs) == 0 {
+		return "", errors.New("no commit message")
+	}
+
+	repo, wt, err := b.worktree()
+	if err != nil {
+		return "", err
+	}
+
+	opts := &git.CommitOptions{}
+	if author != "" {
+		opts.Author, err = parseAuthor(author)
+		if err != nil {
+			return "", err
+		}
+		// Like git, the committer is still whoever runs the command
+		opts.Committer, err = configCommitter(repo)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	hash, err := wt.Commit(strings.Join(paragraphs, "\n\n"), opts)
+	if err != nil {
+		return "", err
+	}
+	return hash.String() + "\n", nil
+}
+
+// parseAuthor parses an author in "Name <email>" format.
+func parseAuthor(author string) (*object.Signature, error) {
+	name, email, ok := strings.Cut(author, "<")
+	if !ok || !strings.HasSuffix(email, ">") || strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("invalid author %q, expected 'Name <email>'", author)
+	}
+	return &object.Signature{
+		Name:  strings.TrimSpace(name),
+		Email: strings.TrimSuffix(email, ">"),
+		When:  time.Now(),
+	}, nil
+}
+
+// configCommitter returns the committer configured for the repository,
+// globally or locally.
+func configCommitter(repo *git.Repository) (*object.Signature, error) {
+	cfg, err := repo.ConfigScoped(config.SystemScope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git config: %v", err)
+	}
+
+	name, email := cfg.User.Name, cfg.User.Email
+	if cfg.Committer.Name != "" {
+		name, email = cfg.Committer.Name, cfg.Committer.Email
+	}
+	if name == "" {
+		return nil, errors.New("no committer configured, set user.name and user.email")
+	}
+	return &object.Signature{Name: name, Email: email, When: time.Now()}, nil
+}
+
+// fetch supports "fetch <remote> <branch>": the branch of the remote is
+// fetched into its remote-tracking branch.
+func (b goGitBackend) fetch(dir string, args []string) error {
+	if len(args) != 2 || strings.HasPrefix(args[0], "-") || strings.HasPrefix(args[1], "-") {
+		return fmt.Errorf("unsupported fetch arguments %v", args)
+	}
+	remote, branch := args[0], args[1]
+
+	repo, err := b.open()
+	if err != nil {
+		return fmt.Errorf("failed to open git repository: %v", err)
+	}
+	err = repo.Fetch(&git.FetchOptions{
+		RemoteName: remote,
+		RefSpecs: []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s",
+			plumbing.NewBranchReferenceName(branch), plumbing.NewRemoteReferenceName(remote, branch)))},
+	})
+	if errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil
+	}
+	return err
+}
+
+// push supports "push [-u] [remote [branch...]]" and "push <remote>
+// --delete <branch>...".
+func (b goGitBackend) push(dir string, args []string) error {
+	opts := &git.PushOptions{}
+	var positional []string
+	remove := false
+	for _, arg := range args {
+		switch arg {
+		case "-u", "--set-upstream":
+			// Upstream tracking only matters to later git commands,
+			// there is nothing to do in process.
+		case "-d", "--delete":
+			remove = true
+		default:
+			positional = append(positional, arg)
+		}
+	}
+	if len(positional) > 0 {
+		opts.RemoteName = positional[0]
+		for _, branch := range positional[1:] {
+			ref := plumbing.NewBranchReferenceName(branch)
+			if remove {
+				opts.RefSpecs = append(opts.RefSpecs, config.RefSpec(":"+ref))
+				continue
+			}
+			opts.RefSpecs = append(opts.RefSpecs, config.RefSpec(fmt.Sprintf("%s:%s", ref, ref)))
+		}
+	}
+	if remove && len(opts.RefSpecs) == 0 {
+		return errors.New("no branch to delete given")
+	}
+
+	repo, err := b.open()
+	if err != nil {
+		return fmt.Errorf("failed to open git repository: %v", err)
+	}
+	err = repo.Push(opts)
+	if errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil
+	}
+	return err
+}
+
+// checkout supports "checkout <branch>" and "checkout -b <branch>
+// [<start-point>]".
+func (b goGitBackend) checkout(dir string, args []string) error {
+	opts := &git.CheckoutOptions{}
+	var startPoint string
+	if len(args) >= 2 && args[0] == "-b" {
+		opts.Create = true
+		// Like git, carry the local changes over to the new branch
+		opts.Keep = true
+		args = args[1:]
+		if len(args) == 2 {
+			// Keep would also keep the files of the current branch
+			opts.Keep = false
+			startPoint = args[1]
+			args = args[:1]
+		}
+	}
+	if len(args) != 1 || strings.HasPrefix(args[0], "-") || strings.HasPrefix(startPoint, "-") {
+		return fmt.Errorf("unsupported checkout arguments %v", args)
+	}
+	opts.Branch = plumbing.NewBranchReferenceName(args[0])
+
+	repo, wt, err := b.worktree()
+	if err != nil {
+		return err
+	}
+	if startPoint != "" {
+		hash, err := repo.ResolveRevision(plumbing.Revision(startPoint))
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %v", startPoint, err)
+		}
+		opts.Hash = *hash
+	}
+	return wt.Checkout(opts)
+}
+
+// branch supports "branch -D <branch>".
+func (b goGitBackend) branch(dir string, args []string) error {
+	if len(args) != 2 || args[0] != "-D" {
+		return fmt.Errorf("unsupported branch arguments %v", args)
+	}
+
+	repo, err := b.open()
+	if err != nil {
+		return fmt.Errorf("failed to open git repository: %v", err)
+	}
+	return repo.Storer.RemoveReference(plumbing.NewBranchReferenceName(args[1]))
+}
+
+// revParse supports "rev-parse --abbrev-ref HEAD": the current branch.
+func (b goGitBackend) revParse(dir string, args []string) (string, error) {
+	if len(args) != 2 || args[0] != "--abbrev-ref" || args[1] != "HEAD" {
+		return "", fmt.Errorf("unsupported rev-parse arguments %v", args)
+	}
+
+	repo, err := b.open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open git repository: %v", err)
+	}
+	head, err := repo.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return "", err
+	}
+	if head.Type() != plumbing.SymbolicReference {
+		// Detached HEAD
+		return "HEAD\n", nil
+	}
+	return head.Target().Short() + "\n", nil
+}
+
+// log supports "log --diff-filter=A --format=%H -- path": the hashes of the
+// commits adding the file, newest first.
+func (b goGitBackend) log(dir string, args []string) (string, error) {
+	if len(args) != 4 || args[0] != "--diff-filter=A" || args[1] != "--format=%H" || args[2] != "--" {
+		return "", fmt.Errorf("unsupported log options %v", args)
+	}
+
+	path, err := relPath(dir, args[3])
+	if err != nil {
+		return "", err
+	}
+	repo, err := b.open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open git repository: %v", err)
+	}
+
+	commits, err := repo.Log(&git.LogOptions{FileName: &path})
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		// No commit yet
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var hashes []string
+	err = commits.ForEach(func(c *object.Commit) error {
+		added := true
+		err := c.Parents().ForEach(func(parent *object.Commit) error {
+			if _, err := parent.File(path); err == nil {
+				added = false
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if added {
+			hashes = append(hashes, c.Hash.String())
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(hashes) == 0 {
+		return "", nil
+	}
+	return strings.Join(hashes, "\n") + "\n", nil
+}
+
+// show supports "show <revision>:<path>".
+func (b goGitBackend) show(dir string, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("unsupported show arguments %v", args)
+	}
+	rev, path, ok := strings.Cut(args[0], ":")
+	if !ok {
+		return "", fmt.Errorf("unsupported show argument %q, expected <revision>:<path>", args[0])
+	}
+
+	repo, err := b.open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open git repository: %v", err)
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return "", err
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return "", err
+	}
+	file, err := commit.File(filepath.ToSlash(path))
+	if err != nil {
+		return "", err
+	}
+	return file.Contents()
+}
+
+// checkChanges is a gitChangeChecker: it reports whether the file has staged
+// changes compared to HEAD.
+func (b goGitBackend) checkChanges(dir, filePath string) (bool, error) {
+	_, wt, err := b.worktree()
+	if err != nil {
+		return false, err
+	}
+	path, err := relPath(dir, filePath)
+	if err != nil {
+		return false, err
+	}
+
+	status, err := wt.Status()
+	if err != nil {
+		return false, fmt.Errorf("error getting git status: %v", err)
+	}
+	fileStatus, ok := status[path]
+	if !ok {
+		return false, nil
+	}
+	return fileStatus.Staging != git.Unmodified && fileStatus.Staging != git.Untracked, nil
+}
+
+// repository returns the repository of the backend: its worktree and git
+// commands.
+func (b goGitBackend) repository() gitRepository {
+	return gitRepository{files: b.files, run: b.runCommand, checkChanges: b.checkChanges}
+}
+
+// gitBackend returns the repository of the working directory for the backend
+// name.
+func gitBackend(name string) (gitRepository, error) {
+	switch name {
+	case "", "exec":
+		return osRepository(runGitCommand, runGitCheckChanges), nil
+	case "go":
+		return newGoGitBackend().repository(), nil
+	default:
+		return gitRepository{}, fmt.Errorf("unknown git backend %q, expected 'exec' or 'go'", name)
+	}
+}
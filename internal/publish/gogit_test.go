@@ -0,0 +1,215 @@
+package publish
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// initGoGitRepo creates a repository in memory with a committer identity,
+// without relying on the git binary.
+func initGoGitRepo(t *testing.T) goGitBackend {
+	t.Helper()
+
+	b := goGitBackend{storer: memory.NewStorage(), files: memfs.New()}
+	if _, err := b.runCommand(repoDir, "init"); err != nil {
+		t.Fatalf("Failed to init repository: %v", err)
+	}
+	repo, err := b.open()
+	if err != nil {
+		t.Fatalf("Failed to init repository: %v", err)
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		t.Fatalf("Failed to read repository config: %v", err)
+	}
+	cfg.User.Name = "Test"
+	cfg.User.Email = "test@example.com"
+	if err := repo.SetConfig(cfg); err != nil {
+		t.Fatalf("Failed to write repository config: %v", err)
+	}
+	return b
+}
+
+func TestGoGitBackend(t *testing.T) {
+	b := initGoGitRepo(t)
+	dir := repoDir
+	path := filepath.Join("content", "event.md")
+
+	write := func(content string) {
+		t.Helper()
+		if err := util.WriteFile(b.files, path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkChanges := func(want bool) {
+		t.Helper()
+		got, err := b.checkChanges(dir, path)
+		if err != nil {
+			t.Fatalf("checkChanges: %v", err)
+		}
+		if got != want {
+			t.Errorf("checkChanges = %v, want %v", got, want)
+		}
+	}
+
+	// Nothing committed yet
+	out, err := b.runCommand(dir, "log", "--diff-filter=A", "--format=%H", "--", path)
+	if err != nil || out != "" {
+		t.Errorf("log on empty repository = %q, %v; want no output", out, err)
+	}
+
+	write("first\n")
+	checkChanges(false)
+	if _, err := b.runCommand(dir, "add", path); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	checkChanges(true)
+	first, err := b.runCommand(dir, "commit", "-m", "Add event")
+	if err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	checkChanges(false)
+
+	write("second\n")
+	if _, err := b.runCommand(dir, "add", path); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	checkChanges(true)
+	if _, err := b.runCommand(dir, "commit", "-m", "Update event", "-m", "With a body"); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	// Only the commit adding the file is listed
+	out, err = b.runCommand(dir, "log", "--diff-filter=A", "--format=%H", "--", path)
+	if err != nil {
+		t.Fatalf("log: %v", err)
+	}
+	if out != first {
+		t.Errorf("log = %q, want %q", out, first)
+	}
+
+	out, err = b.runCommand(dir, "show", strings.TrimSpace(first)+":"+filepath.ToSlash(path))
+	if err != nil {
+		t.Fatalf("show: %v", err)
+	}
+	if out != "first\n" {
+		t.Errorf("show = %q, want %q", out, "first\n")
+	}
+
+	repo, err := git.Open(b.storer, b.files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit.Message != "Update event\n\nWith a body" {
+		t.Errorf("commit message = %q", commit.Message)
+	}
+}
+
+func TestGoGitBackendErrors(t *testing.T) {
+	b := initGoGitRepo(t)
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "no command", args: nil},
+		{name: "unsupported command", args: []string{"rebase"}},
+		{name: "unsupported commit option", args: []string{"commit", "--amend"}},
+		{name: "commit without message", args: []string{"commit"}},
+		{name: "invalid author", args: []string{"commit", "-m", "Add event", "--author=bot"}},
+		{name: "add outside of the repository", args: []string{"add", filepath.Join("..", "elsewhere.md")}},
+		{name: "add an absolute path", args: []string{"add", filepath.Join(string(filepath.Separator), "elsewhere.md")}},
+		{name: "unsupported log options", args: []string{"log", "--oneline"}},
+		{name: "show without path", args: []string{"show", "HEAD"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := b.runCommand(repoDir, tt.args...); err == nil {
+				t.Errorf("runCommand(%v) succeeded, want an error", tt.args)
+			}
+		})
+	}
+}
+
+func TestGoGitBackendPublishEventMarkdown(t *testing.T) {
+	b := initGoGitRepo(t)
+	// The template is read from the disk, the event only goes to the
+	// worktree in memory
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	templatePath := filepath.Join(dir, "bal.md.template")
+	templateContent := `---
+title: Bal
+---
+Bal le {{ .LongDate }}
+`
+	if err := os.WriteFile(templatePath, []byte(templateContent), 0644); err != nil {
+		t.Fatalf("Failed to create template file: %v", err)
+	}
+
+	date := time.Date(2024, 12, 24, 0, 0, 0, 0, time.UTC)
+	commit := commitSettings{Author: "Forró bot <bot@example.com>"}
+	outputPath, _, _, _, _, err := publishEventMarkdown(TemplateInfo{File: templatePath}, date, date.Format("2006-01-02"), "fr", nil, existingRefuse, commit, false, b.repository())
+	if err != nil {
+		t.Fatalf("publishEventMarkdown: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, outputPath)); !os.IsNotExist(err) {
+		t.Errorf("%s written on the disk, want it in the worktree only: %v", outputPath, err)
+	}
+
+	repo, err := git.Open(b.storer, b.files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatalf("No commit was made: %v", err)
+	}
+	c, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantMessage := "Add event for 2024-12-24 based on template bal.md.template\n\nEvent-Slug: 241224-bal\nTemplate: bal.md.template\nTargets: site"
+	if c.Message != wantMessage {
+		t.Errorf("commit message = %q, want %q", c.Message, wantMessage)
+	}
+	if c.Author.Name != "Forró bot" || c.Author.Email != "bot@example.com" {
+		t.Errorf("author = %v, want the bot", c.Author)
+	}
+	if c.Committer.Name != "Test" {
+		t.Errorf("committer = %v, want the configured user", c.Committer)
+	}
+	if _, err := c.File(filepath.ToSlash(outputPath)); err != nil {
+		t.Errorf("%s not in the commit: %v", outputPath, err)
+	}
+}
+
+func TestGitBackend(t *testing.T) {
+	for _, name := range []string{"", "exec", "go"} {
+		if _, err := gitBackend(name); err != nil {
+			t.Errorf("gitBackend(%q): %v", name, err)
+		}
+	}
+	if _, err := gitBackend("svn"); err == nil {
+		t.Error("gitBackend(\"svn\") succeeded, want an error")
+	}
+}
@@ -0,0 +1,320 @@
+package publish
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/event"
+	"github.com/dolanor/forrostrasbourg.fr/internal/ical"
+)
+
+// openCalendar opens a local .ics file or fetches it if source is an URL.
+func openCalendar(source string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.Open(source)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch calendar: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to fetch calendar: %s returned status %d", source, resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+var postalCodeRe = regexp.MustCompile(`^\d{4,5}\s+`)
+
+// splitLocation cuts an iCalendar location, e.g. "La Kulture, 9 rue des
+// Bateliers, 67000 Strasbourg", into the place with its address and the
+// city. Without a comma, the whole location is the place.
+func splitLocation(location string) (place, city string) {
+	location = strings.TrimSpace(strings.ReplaceAll(location, "\n", ", "))
+	i := strings.LastIndex(location, ",")
+	if i < 0 {
+		return location, ""
+	}
+
+	city = strings.TrimSpace(location[i+1:])
+	city = postalCodeRe.ReplaceAllString(city, "")
+	// A trailing country isn't the city
+	if strings.EqualFold(city, "France") || strings.EqualFold(city, "Deutschland") || strings.EqualFold(city, "Germany") {
+		return splitLocation(location[:i])
+	}
+	return strings.TrimSpace(location[:i]), city
+}
+
+var accents = strings.NewReplacer(
+	"à", "a", "â", "a", "ä", "a", "á", "a", "ã", "a",
+	"ç", "c",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "î", "i", "ï", "i",
+	"ó", "o", "ô", "o", "ö", "o", "õ", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ß", "ss",
+)
+
+// slugify turns a title into a file name part: lowercase ASCII words
+// separated by dashes.
+func slugify(title string) string {
+	title = accents.Replace(strings.ToLower(title))
+
+	var b strings.Builder
+	dash := false
+	for _, r := range title {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+
+	slug := b.String()
+	if len(slug) > 40 {
+		cut := slug[:40]
+		// Don't keep half a word
+		if slug[40] != '-' {
+			if i := strings.LastIndex(cut, "-"); i > 20 {
+				cut = cut[:i]
+			}
+		}
+		slug = strings.TrimRight(cut, "-")
+	}
+	if slug == "" {
+		slug = "evenement"
+	}
+	return slug
+}
+
+// icsEvent maps a calendar event onto an event page.
+func icsEvent(e ical.Event, defaultCity string) event.Event {
+	place, city := splitLocation(e.Location)
+	if city == "" {
+		city = defaultCity
+	}
+
+	description := strings.TrimSpace(e.Description)
+	summary := e.Summary
+	if first, _, _ := strings.Cut(description, "\n"); first != "" && len(first) <= 160 {
+		summary = first
+	}
+
+	body := description
+	if e.URL != "" {
+		body = strings.TrimSpace(body + "\n\nPlus d'informations : " + e.URL)
+	}
+
+	return event.Event{
+		Title:       e.Summary,
+		Description: summary,
+		StartDate:   e.Start,
+		EndDate:     e.End,
+		Place:       place,
+		City:        city,
+		UID:         e.UID,
+		SourceURL:   e.URL,
+		Path:        filepath.Join(eventsDir, fmt.Sprintf("%s-%s.md", e.Start.Format("060102"), slugify(e.Summary))),
+		Body:        body,
+	}
+}
+
+// icsImport turns the events of a calendar into new event pages.
+type icsImport struct {
+	dir         string         // Events directory
+	filter      *regexp.Regexp // Only the events whose summary matches, if set
+	since       time.Time      // Ignore the events ended before
+	loc         *time.Location // Where the days of the events are
+	defaultCity string
+	dryRun      bool
+	// strict fails instead of skipping the event pages that can't be read.
+	strict bool
+	out    io.Writer
+}
+
+// run writes the pages of the calendar events that aren't on the site yet,
+// and returns their paths.
+func (imp icsImport) run(cal *ical.Calendar) ([]string, error) {
+	// The venues give the places of the events typed by hand
+	venues, err := loadVenues()
+	if err != nil {
+		return nil, err
+	}
+	existing, err := loadEvents(imp.dir, venues, imp.strict)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	// Events imported before have the calendar UID, events typed by hand
+	// are found by their day and their place or title: their time may not
+	// be the one of the feed.
+	byUID := map[string]string{}
+	byDay := map[string][]event.Event{}
+	day := func(t time.Time) string {
+		return t.In(imp.loc).Format(time.DateOnly)
+	}
+	for _, e := range existing {
+		if e.UID != "" {
+			byUID[e.UID] = e.Path
+			continue
+		}
+		byDay[day(e.StartDate)] = append(byDay[day(e.StartDate)], e)
+	}
+
+	var written []string
+	for _, ce := range cal.Events {
+		switch {
+		case ce.Status == "CANCELLED":
+			continue
+		case imp.filter != nil && !imp.filter.MatchString(ce.Summary):
+			continue
+		case ce.End.Before(imp.since):
+			continue
+		}
+
+		if path, ok := byUID[ce.UID]; ok && ce.UID != "" {
+			fmt.Fprintf(imp.out, "skip %q on %s: already imported in %s\n", ce.Summary, ce.Start.Format("2006-01-02"), path)
+			continue
+		}
+
+		e := icsEvent(ce, imp.defaultCity)
+		if typed, ok := sameDayEvent(byDay[day(ce.Start)], e); ok {
+			fmt.Fprintf(imp.out, "skip %q on %s: %s is the same event\n", ce.Summary, ce.Start.Format("2006-01-02"), typed.Path)
+			continue
+		}
+
+		e.Path = filepath.Join(imp.dir, filepath.Base(e.Path))
+		if _, err := os.Stat(e.Path); err == nil {
+			fmt.Fprintf(imp.out, "skip %q on %s: %s already exists\n", ce.Summary, ce.Start.Format("2006-01-02"), e.Path)
+			continue
+		}
+
+		content, err := event.Marshal(e)
+		if err != nil {
+			return written, err
+		}
+		if imp.dryRun {
+			fmt.Fprintf(imp.out, "[Dry Run] would create %s with:\n%s\n", e.Path, content)
+		} else {
+			if err := os.MkdirAll(imp.dir, 0o755); err != nil {
+				return written, fmt.Errorf("failed to create output directory: %v", err)
+			}
+			if err := os.WriteFile(e.Path, content, 0o644); err != nil {
+				return written, fmt.Errorf("failed to create output file: %v", err)
+			}
+			fmt.Fprintf(imp.out, "created %s\n", e.Path)
+		}
+
+		// Don't import twice the same event listed twice in the feed
+		if e.UID != "" {
+			byUID[e.UID] = e.Path
+		} else {
+			byDay[day(e.StartDate)] = append(byDay[day(e.StartDate)], e)
+		}
+		written = append(written, e.Path)
+	}
+	return written, nil
+}
+
+// sameDayEvent returns the event of the day that is the imported one: at
+// the same place, or with a title containing the other, like "Pratique" and
+// "Pratique de forró".
+func sameDayEvent(events []event.Event, imported event.Event) (event.Event, bool) {
+	place := placeName(imported.Place)
+	title := "-" + slugify(imported.Title) + "-"
+	for _, e := range events {
+		if place != "" && placeName(e.Place) == place {
+			return e, true
+		}
+		other := "-" + slugify(e.Title) + "-"
+		if title != "--" && other != "--" && (strings.Contains(title, other) || strings.Contains(other, title)) {
+			return e, true
+		}
+	}
+	return event.Event{}, false
+}
+
+// placeName is the name of the place, before its address, e.g. la-kulture
+// for "La Kulture, 9 rue des Bateliers".
+func placeName(place string) string {
+	name, _, _ := strings.Cut(place, ",")
+	return slugify(name)
+}
+
+// runImportICSCommand implements the "import-ics" command.
+func runImportICSCommand(args []string, w io.Writer) error {
+	fs := newFlagSet("import-ics", "<file.ics or URL>")
+	filter := fs.String("filter", "", "Only import the events whose title matches this regular expression, e.g. '(?i)forr[oó]'")
+	since := fs.String("since", "", "Only import the events ending after this date in YYYY-MM-DD format (defaults to today)")
+	city := fs.String("city", "Strasbourg", "City of the events whose location doesn't tell")
+	dir := fs.String("dir", eventsDir, "Directory of the event pages")
+	dryRun := fs.Bool("dry-run", false, "If true, only show the event pages that would be created")
+	strict := fs.Bool("strict", false, "Fail instead of skipping the event pages that can't be read")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one calendar file or URL, got %d", fs.NArg())
+	}
+
+	loc, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		return err
+	}
+
+	imp := icsImport{
+		dir:         *dir,
+		since:       time.Now().Truncate(24 * time.Hour),
+		loc:         loc,
+		defaultCity: *city,
+		dryRun:      *dryRun,
+		strict:      *strict,
+		out:         w,
+	}
+	if *filter != "" {
+		imp.filter, err = regexp.Compile(*filter)
+		if err != nil {
+			return fmt.Errorf("invalid filter: %v", err)
+		}
+	}
+	if *since != "" {
+		imp.since, err = time.ParseInLocation("2006-01-02", *since, loc)
+		if err != nil {
+			return fmt.Errorf("invalid date %q, expected YYYY-MM-DD: %v", *since, err)
+		}
+	}
+
+	r, err := openCalendar(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	cal, err := ical.Parse(r, loc)
+	if err != nil {
+		return fmt.Errorf("failed to parse calendar: %v", err)
+	}
+
+	written, err := imp.run(cal)
+	if err != nil {
+		return err
+	}
+	log.Printf("%d event(s) imported out of %d in the calendar", len(written), len(cal.Events))
+	return nil
+}
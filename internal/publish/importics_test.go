@@ -0,0 +1,173 @@
+package publish
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/event"
+	"github.com/dolanor/forrostrasbourg.fr/internal/ical"
+)
+
+func TestSplitLocation(t *testing.T) {
+	tests := []struct {
+		location  string
+		wantPlace string
+		wantCity  string
+	}{
+		{location: "La Kulture, 9 rue des Bateliers, 67000 Strasbourg", wantPlace: "La Kulture, 9 rue des Bateliers", wantCity: "Strasbourg"},
+		{location: "Cita, Hauptstraße 1, 77694 Kehl, Deutschland", wantPlace: "Cita, Hauptstraße 1", wantCity: "Kehl"},
+		{location: "Parc du Heyritz", wantPlace: "Parc du Heyritz"},
+		{location: "La Kulture\n67000 Strasbourg", wantPlace: "La Kulture", wantCity: "Strasbourg"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.location, func(t *testing.T) {
+			place, city := splitLocation(tt.location)
+			if place != tt.wantPlace || city != tt.wantCity {
+				t.Errorf("splitLocation(%q) = %q, %q, want %q, %q", tt.location, place, city, tt.wantPlace, tt.wantCity)
+			}
+		})
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{title: "Bal Forró à la Kulture !", want: "bal-forro-a-la-kulture"},
+		{title: "🎵 Forró & Forró 💃", want: "forro-forro"},
+		{title: "🎵", want: "evenement"},
+		{title: "Un très très long titre de soirée forró avec initiation et concert", want: "un-tres-tres-long-titre-de-soiree-forro"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			if got := slugify(tt.title); got != tt.want {
+				t.Errorf("slugify(%q) = %q, want %q", tt.title, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestICSImport(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	// Already on the site: one imported before, and typed by hand: one at
+	// another time than in the feed, one at the place of the feed event
+	// with another title, and another event of the day
+	existing := map[string]string{
+		"241223-cours.md":     "---\ntitle: Cours de forró à la Meinau\nstartDate: \"2024-12-23T19:00:00+01:00\"\nplace: CSC de la Meinau, 1 rue de Bourgogne\ncity: Strasbourg\n---\n",
+		"241226-soiree.md":    "---\ntitle: Soirée de Noël\nstartDate: \"2024-12-26T20:00:00+01:00\"\nplace: La Kulture, 9 rue des Bateliers\ncity: Strasbourg\n---\n",
+		"241217-bal-forro.md": "---\ntitle: Bal\nstartDate: \"2024-12-17T18:30:00+01:00\"\nuid: bal-20241217@lakulture.fr\n---\n",
+		"241219-pratique.md":  "---\ntitle: Pratique\nstartDate: \"2024-12-19T19:00:00+01:00\"\n---\n",
+		"241231-reveillon.md": "---\ntitle: Réveillon\nstartDate: \"2024-12-31T20:00:00+01:00\"\n---\n",
+	}
+	for name, content := range existing {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 12, day, hour, minute, 0, 0, paris)
+	}
+	cal := &ical.Calendar{Events: []ical.Event{
+		{UID: "bal-20241210@lakulture.fr", Summary: "Bal Forró", Start: at(10, 18, 30), End: at(10, 23, 30)},
+		{UID: "bal-20241217@lakulture.fr", Summary: "Bal Forró", Start: at(17, 18, 30), End: at(17, 23, 30)},
+		{UID: "pratique-20241223@cita.de", Summary: "Pratique forró", Location: "Cita, Hauptstraße 1, 77694 Kehl", Start: at(23, 20, 0), End: at(23, 23, 0)},
+		{UID: "pratique-20241219@lakulture.fr", Summary: "Pratique de forró", Start: at(19, 19, 30), End: at(19, 22, 0)},
+		{UID: "bal-20241224@lakulture.fr", Summary: "Bal Forró", Description: "Bal de Noël\nAvec initiation", Location: "La Kulture, 9 rue des Bateliers, 67000 Strasbourg", URL: "https://lakulture.fr/agenda/bal", Start: at(24, 18, 30), End: at(24, 23, 30)},
+		{UID: "bal-20241226@lakulture.fr", Summary: "Bal Forró", Location: "La Kulture, 9 rue des Bateliers, 67000 Strasbourg", Start: at(26, 21, 0), End: at(26, 23, 30)},
+		{UID: "concert-20241226@lakulture.fr", Summary: "Concert de jazz", Start: at(26, 20, 0), End: at(26, 22, 0)},
+		{UID: "bal-20241227@lakulture.fr", Summary: "Bal Forró", Status: "CANCELLED", Start: at(27, 18, 30), End: at(27, 23, 30)},
+		{UID: "reveillon@lakulture.fr", Summary: "Réveillon forró", Start: at(31, 20, 0), End: at(31, 23, 59)},
+		// Listed twice in the feed
+		{UID: "bal-20241224@lakulture.fr", Summary: "Bal Forró", Start: at(24, 18, 30), End: at(24, 23, 30)},
+	}}
+
+	var out bytes.Buffer
+	imp := icsImport{
+		dir:         dir,
+		filter:      regexp.MustCompile(`(?i)forr[oó]`),
+		since:       at(15, 0, 0),
+		loc:         paris,
+		defaultCity: "Strasbourg",
+		out:         &out,
+	}
+	written, err := imp.run(cal)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	// The pratique in Kehl is another event than the cours of the day
+	wantPath := filepath.Join(dir, "241224-bal-forro.md")
+	if len(written) != 2 || written[0] != filepath.Join(dir, "241223-pratique-forro.md") || written[1] != wantPath {
+		t.Fatalf("written = %v, want the pratique of the 23rd and %s\n%s", written, wantPath, out.String())
+	}
+
+	content, err := os.ReadFile(wantPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := event.Parse(content)
+	if err != nil {
+		t.Fatalf("invalid event page: %v\n%s", err, content)
+	}
+	if e.Title != "Bal Forró" || e.Description != "Bal de Noël" || e.Place != "La Kulture, 9 rue des Bateliers" || e.City != "Strasbourg" ||
+		e.UID != "bal-20241224@lakulture.fr" || e.SourceURL != "https://lakulture.fr/agenda/bal" {
+		t.Errorf("event = %+v", e)
+	}
+	if !e.StartDate.Equal(at(24, 18, 30)) || !e.EndDate.Equal(at(24, 23, 30)) {
+		t.Errorf("event from %v to %v", e.StartDate, e.EndDate)
+	}
+	if !strings.Contains(e.Body, "Avec initiation") || !strings.Contains(e.Body, "https://lakulture.fr/agenda/bal") {
+		t.Errorf("body = %q", e.Body)
+	}
+
+	for _, want := range []string{"already imported in 241217-bal-forro.md", "241219-pratique.md is the same event", "241226-soiree.md is the same event", "241231-reveillon.md is the same event"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output doesn't tell %q:\n%s", want, out.String())
+		}
+	}
+
+	// Importing again creates nothing
+	written, err = imp.run(cal)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if len(written) != 0 {
+		t.Errorf("second import wrote %v", written)
+	}
+}
+
+func TestICSImportDryRun(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "evenements")
+	start := time.Date(2024, 12, 24, 18, 30, 0, 0, time.UTC)
+	cal := &ical.Calendar{Events: []ical.Event{{UID: "bal", Summary: "Bal", Start: start, End: start.Add(time.Hour)}}}
+
+	var out bytes.Buffer
+	imp := icsImport{dir: dir, loc: time.UTC, dryRun: true, out: &out}
+	written, err := imp.run(cal)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if len(written) != 1 {
+		t.Errorf("written = %v, want one event", written)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("dry run created %s", dir)
+	}
+	if !strings.Contains(out.String(), "[Dry Run] would create") {
+		t.Errorf("output = %q", out.String())
+	}
+}
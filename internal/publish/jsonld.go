@@ -0,0 +1,110 @@
+package publish
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/util"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/calendar"
+	"github.com/dolanor/forrostrasbourg.fr/internal/event"
+)
+
+// eventJSONLD returns the path and content of the JSON-LD data file of the
+// event page written at outputPath, held at one of venues. Pages without a
+// start date aren't events and get none.
+func eventJSONLD(outputPath string, content []byte, venues event.Venues) (string, []byte, error) {
+	e, err := event.Parse(content)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read %s: %v", outputPath, err)
+	}
+	if e.StartDate.IsZero() {
+		return "", nil, nil
+	}
+	if err := venues.Resolve(&e); err != nil {
+		return "", nil, err
+	}
+	e.Path = outputPath
+
+	return schemaOrgFile(e)
+}
+
+func schemaOrgFile(e event.Event) (string, []byte, error) {
+	ld := e.SchemaOrg(calendar.BaseURL)
+	if err := ld.Validate(); err != nil {
+		return "", nil, fmt.Errorf("invalid structured data: %v", err)
+	}
+	data, err := ld.JSON()
+	if err != nil {
+		return "", nil, err
+	}
+	return e.JSONLDPath(), data, nil
+}
+
+// writeFile writes data at path in files, creating its directory.
+func writeFile(files billy.Filesystem, path string, data []byte) error {
+	if err := files.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	return util.WriteFile(files, path, data, 0o644)
+}
+
+// runJSONLDCommand implements the "jsonld" command: it writes the JSON-LD
+// data files of all the event pages, e.g. after editing pages by hand, or
+// checks they are up to date.
+func runJSONLDCommand(args []string, w io.Writer) error {
+	fs := newFlagSet("jsonld", "")
+	dir := fs.String("dir", eventsDir, "Directory of the event pages")
+	check := fs.Bool("check", false, "Only check the data files are up to date, without writing them")
+	strict := fs.Bool("strict", false, "Fail instead of skipping the event pages that can't be read")
+	fs.Parse(args)
+
+	venues, err := loadVenues()
+	if err != nil {
+		return err
+	}
+	events, err := loadEvents(*dir, venues, *strict)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	written, stale := 0, 0
+	for _, e := range events {
+		path, data, err := schemaOrgFile(e)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", e.Path, err))
+			continue
+		}
+		existing, err := os.ReadFile(path)
+		if err == nil && bytes.Equal(existing, data) {
+			continue
+		}
+
+		if *check {
+			stale++
+			fmt.Fprintf(w, "%s is out of date\n", path)
+			continue
+		}
+		if err := writeFile(osfs.New(""), path, data); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		written++
+		fmt.Fprintf(w, "Wrote %s\n", path)
+	}
+
+	if *check && stale > 0 {
+		errs = append(errs, fmt.Errorf("%d data files out of date, run the jsonld command", stale))
+	}
+	if !*check {
+		fmt.Fprintf(w, "%d of %d data files written\n", written, len(events))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,133 @@
+package publish
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPublishEventMarkdownJSONLD(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	templatePath := filepath.Join(tmpDir, "bal.md.template")
+	template := `---
+title: Bal
+startDate: "{{.Date}}T20:00:00+01:00"
+place: La Kulture, 9 rue des bateliers
+city: Strasbourg
+price: 5€
+---
+`
+	if err := os.WriteFile(templatePath, []byte(template), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var added []string
+	runner := func(dir string, args ...string) (string, error) {
+		if args[0] == "add" {
+			added = append(added, args[1:]...)
+		}
+		return "", nil
+	}
+	checker := func(dir, filePath string) (bool, error) { return true, nil }
+
+	date := time.Date(2024, 12, 24, 0, 0, 0, 0, time.UTC)
+	_, _, _, _, _, err := publishEventMarkdown(TemplateInfo{File: templatePath}, date, "2024-12-24", "fr", nil, existingRefuse, commitSettings{}, false, osRepository(runner, checker))
+	if err != nil {
+		t.Fatalf("publishEventMarkdown: %v", err)
+	}
+
+	jsonldPath := filepath.Join("data", "jsonld", "241224-bal.json")
+	data, err := os.ReadFile(jsonldPath)
+	if err != nil {
+		t.Fatalf("structured data not written: %v", err)
+	}
+	for _, want := range []string{`"@type": "DanceEvent"`, `"startDate": "2024-12-24T20:00:00+01:00"`, `"price": "5"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("structured data doesn't contain %s:\n%s", want, data)
+		}
+	}
+
+	// Committed along with the page
+	wantAdded := []string{filepath.Join(eventsDir, "241224-bal.md"), jsonldPath}
+	if strings.Join(added, " ") != strings.Join(wantAdded, " ") {
+		t.Errorf("git add %v, want %v", added, wantAdded)
+	}
+}
+
+func TestPublishEventMarkdownInvalidJSONLD(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	// No place nor city: search engines wouldn't show it
+	templatePath := filepath.Join(tmpDir, "bal.md.template")
+	template := "---\ntitle: Bal\nstartDate: \"{{.Date}}T20:00:00+01:00\"\n---\n"
+	if err := os.WriteFile(templatePath, []byte(template), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := func(dir string, args ...string) (string, error) { return "", nil }
+	checker := func(dir, filePath string) (bool, error) { return true, nil }
+
+	date := time.Date(2024, 12, 24, 0, 0, 0, 0, time.UTC)
+	_, _, _, _, _, err := publishEventMarkdown(TemplateInfo{File: templatePath}, date, "2024-12-24", "fr", nil, existingRefuse, commitSettings{}, false, osRepository(runner, checker))
+	if err == nil || !strings.Contains(err.Error(), "missing location") {
+		t.Fatalf("expected a missing location error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(eventsDir, "241224-bal.md")); !os.IsNotExist(err) {
+		t.Errorf("event page written despite the error")
+	}
+}
+
+func TestRunJSONLDCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	pages := map[string]string{
+		"_index.md":   "---\ntitle: Événements\n---\n",
+		"241224-a.md": "---\ntitle: A\nstartDate: 2024-12-24T20:00:00+01:00\ncity: Strasbourg\n---\n",
+		"241231-b.md": "---\ntitle: B\nstartDate: 2024-12-31T20:00:00+01:00\ncity: Strasbourg\ncancelled: true\n---\n",
+		// Skipped, without keeping the others from being written
+		"241225-c.md": "---\ntitle: [C\n---\n",
+	}
+	if err := os.MkdirAll(eventsDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range pages {
+		if err := os.WriteFile(filepath.Join(eventsDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := runJSONLDCommand([]string{"-check"}, &out); err == nil {
+		t.Error("check passed without the data files")
+	}
+
+	out.Reset()
+	if err := runJSONLDCommand(nil, &out); err != nil {
+		t.Fatalf("runJSONLDCommand: %v", err)
+	}
+	if !strings.Contains(out.String(), "2 of 2 data files written") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+	data, err := os.ReadFile(filepath.Join("data", "jsonld", "241231-b.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "EventCancelled") {
+		t.Errorf("cancelled event not marked as such:\n%s", data)
+	}
+
+	out.Reset()
+	if err := runJSONLDCommand([]string{"-check"}, &out); err != nil {
+		t.Errorf("check failed after writing the data files: %v\n%s", err, out.String())
+	}
+	if err := runJSONLDCommand([]string{"-check", "-strict"}, &out); err == nil || !strings.Contains(err.Error(), "1 event pages skipped") {
+		t.Errorf("check with -strict: expected the skipped pages error, got %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package publish
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/event"
+	"github.com/dolanor/forrostrasbourg.fr/internal/staticmap"
+)
+
+const (
+	// tilesDir is where the OpenStreetMap tiles are cached, see the map
+	// command.
+	tilesDir = "maptiles"
+
+	mapZoom   = 16
+	mapWidth  = 600
+	mapHeight = 300
+
+	tilesUserAgent = "forrostrasbourg.fr-publisher (+https://forrostrasbourg.fr)"
+)
+
+// mapPath is where the map of the event page at pagePath goes: next to it,
+// published at /evenements/<event>-map.png.
+func mapPath(pagePath string) string {
+	return strings.TrimSuffix(pagePath, filepath.Ext(pagePath)) + "-map.png"
+}
+
+// venueMap is the map of the venue, if it has coordinates.
+func venueMap(v event.Venue) (staticmap.Map, bool) {
+	return staticmap.Map{
+		Latitude:  v.Latitude,
+		Longitude: v.Longitude,
+		Zoom:      mapZoom,
+		Width:     mapWidth,
+		Height:    mapHeight,
+	}, v.HasCoordinates()
+}
+
+// eventMap renders the map of the event page written at outputPath from the
+// cached tiles. Events without a venue with coordinates get none.
+func eventMap(outputPath string, content []byte, venues event.Venues) (string, []byte, error) {
+	e, err := event.Parse(content)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read %s: %v", outputPath, err)
+	}
+	m, ok := venueMap(venues[e.Venue])
+	if e.Venue == "" || !ok {
+		return "", nil, nil
+	}
+
+	var buf bytes.Buffer
+	if err := m.WritePNG(&buf, tilesDir); err != nil {
+		return "", nil, fmt.Errorf("failed to render the map of venue %q: %v, run the map command with -fetch", e.Venue, err)
+	}
+	return mapPath(outputPath), buf.Bytes(), nil
+}
+
+// runMapCommand implements the "map" command: it renders the maps of the
+// upcoming events missing one, downloading the missing tiles first with
+// -fetch.
+func runMapCommand(args []string, w io.Writer) error {
+	fs := newFlagSet("map", "")
+	dir := fs.String("dir", eventsDir, "Directory of the event pages")
+	tiles := fs.String("tiles", tilesDir, "Directory of the cached map tiles")
+	since := fs.String("since", time.Now().Format(time.DateOnly), "Only the events starting from this date (YYYY-MM-DD)")
+	fetch := fs.Bool("fetch", false, "Download the missing tiles from the OpenStreetMap tile server")
+	tileURL := fs.String("tile-url", staticmap.DefaultTileURL, "Tile server to download the tiles from")
+	force := fs.Bool("force", false, "Render the maps again even if they exist")
+	strict := fs.Bool("strict", false, "Fail instead of skipping the event pages that can't be read")
+	fs.Parse(args)
+
+	from, err := time.ParseInLocation(time.DateOnly, *since, time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid -since date %q: %v", *since, err)
+	}
+
+	venues, err := loadVenues()
+	if err != nil {
+		return err
+	}
+	events, err := loadEvents(*dir, venues, *strict)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	rendered := 0
+	for _, e := range events {
+		if e.StartDate.Before(from) || e.Venue == "" {
+			continue
+		}
+		m, ok := venueMap(venues[e.Venue])
+		if !ok {
+			fmt.Fprintf(w, "%s: venue %q has no coordinates\n", e.Path, e.Venue)
+			continue
+		}
+		path := mapPath(filepath.Join(*dir, e.Path))
+		if _, err := os.Stat(path); err == nil && !*force {
+			continue
+		}
+
+		if *fetch {
+			n, err := m.Fetch(http.DefaultClient, *tileURL, tilesUserAgent, *tiles)
+			if n > 0 {
+				fmt.Fprintf(w, "Downloaded %d tiles for venue %q\n", n, e.Venue)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", e.Path, err))
+				continue
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := m.WritePNG(&buf, *tiles); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", e.Path, err))
+			continue
+		}
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		rendered++
+		fmt.Fprintf(w, "Wrote %s\n", path)
+	}
+
+	fmt.Fprintf(w, "%d maps rendered\n", rendered)
+	return errors.Join(errs...)
+}
@@ -0,0 +1,177 @@
+package publish
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/event"
+)
+
+// tileData is a blank map tile.
+func tileData(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 256, 256))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// writeTestTiles caches the tiles of the map of the venue in the current
+// directory.
+func writeTestTiles(t *testing.T, venue event.Venue) {
+	t.Helper()
+	m, _ := venueMap(venue)
+	for _, tile := range m.Tiles() {
+		path := filepath.Join(tilesDir, tile.Path())
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, tileData(t), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMapPath(t *testing.T) {
+	got := mapPath(filepath.Join(eventsDir, "241224-bal.md"))
+	if want := filepath.Join(eventsDir, "241224-bal-map.png"); got != want {
+		t.Errorf("mapPath = %q, want %q", got, want)
+	}
+}
+
+func TestPublishEventMarkdownMap(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+	writeTestVenues(t)
+
+	templatePath := filepath.Join(tmpDir, "bal.md.template")
+	template := "---\ntitle: Bal\nstartDate: \"{{.Date}}T20:00:00+01:00\"\nvenue: kulture\n---\n"
+	if err := os.WriteFile(templatePath, []byte(template), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var added []string
+	runner := func(dir string, args ...string) (string, error) {
+		if args[0] == "add" {
+			added = append(added, args[1:]...)
+		}
+		return "", nil
+	}
+	checker := func(dir, filePath string) (bool, error) { return true, nil }
+	date := time.Date(2024, 12, 24, 0, 0, 0, 0, time.UTC)
+	mapFile := filepath.Join(eventsDir, "241224-bal-map.png")
+
+	// Without the tiles, the event is published without a map
+	_, _, _, _, _, err := publishEventMarkdown(TemplateInfo{File: templatePath}, date, "2024-12-24", "fr", nil, existingOverwrite, commitSettings{}, false, osRepository(runner, checker))
+	if err != nil {
+		t.Fatalf("publishEventMarkdown: %v", err)
+	}
+	if _, err := os.Stat(mapFile); !os.IsNotExist(err) {
+		t.Errorf("map written without the tiles")
+	}
+
+	venues, err := loadVenues()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestTiles(t, venues["kulture"])
+	added = nil
+
+	_, _, _, _, _, err = publishEventMarkdown(TemplateInfo{File: templatePath}, date, "2024-12-24", "fr", nil, existingOverwrite, commitSettings{}, false, osRepository(runner, checker))
+	if err != nil {
+		t.Fatalf("publishEventMarkdown: %v", err)
+	}
+	f, err := os.Open(mapFile)
+	if err != nil {
+		t.Fatalf("map not written: %v", err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("map is not a PNG image: %v", err)
+	}
+	if img.Bounds().Dx() != mapWidth || img.Bounds().Dy() != mapHeight {
+		t.Errorf("map is %v", img.Bounds())
+	}
+	if !strings.Contains(strings.Join(added, " "), mapFile) {
+		t.Errorf("map not committed: git add %v", added)
+	}
+}
+
+func TestRunMapCommand(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeTestVenues(t)
+
+	pages := map[string]string{
+		"241224-bal.md":   "---\ntitle: Bal\nstartDate: 2024-12-24T20:00:00+01:00\nvenue: kulture\n---\n",
+		"241231-quai.md":  "---\ntitle: Bal\nstartDate: 2024-12-31T20:00:00+01:00\nvenue: quai\n---\n",
+		"241201-passe.md": "---\ntitle: Bal\nstartDate: 2024-12-01T20:00:00+01:00\nvenue: kulture\n---\n",
+	}
+	if err := os.MkdirAll(eventsDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range pages {
+		if err := os.WriteFile(filepath.Join(eventsDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if !strings.HasPrefix(r.Header.Get("User-Agent"), "forrostrasbourg.fr") {
+			http.Error(w, "identify yourself", http.StatusForbidden)
+			return
+		}
+		w.Write(tileData(t))
+	}))
+	defer server.Close()
+
+	// Without the tiles, nothing is downloaded and it fails
+	var out bytes.Buffer
+	if err := runMapCommand([]string{"-since", "2024-12-10"}, &out); err == nil || !strings.Contains(err.Error(), "missing tiles") {
+		t.Errorf("expected a missing tiles error, got %v", err)
+	}
+	if requests != 0 {
+		t.Errorf("downloaded tiles without -fetch")
+	}
+
+	out.Reset()
+	if err := runMapCommand([]string{"-since", "2024-12-10", "-fetch", "-tile-url", server.URL + "/{z}/{x}/{y}.png"}, &out); err != nil {
+		t.Fatalf("runMapCommand: %v\n%s", err, out.String())
+	}
+	if requests == 0 {
+		t.Errorf("no tile downloaded")
+	}
+	if _, err := os.Stat(filepath.Join(eventsDir, "241224-bal-map.png")); err != nil {
+		t.Errorf("map not written: %v", err)
+	}
+	// Past events and venues without coordinates get no map
+	for _, name := range []string{"241201-passe-map.png", "241231-quai-map.png"} {
+		if _, err := os.Stat(filepath.Join(eventsDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s written", name)
+		}
+	}
+	if !strings.Contains(out.String(), `241231-quai.md: venue "quai" has no coordinates`) {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+
+	// The tiles are cached
+	requests = 0
+	out.Reset()
+	if err := runMapCommand([]string{"-since", "2024-12-10", "-fetch", "-force", "-tile-url", server.URL + "/{z}/{x}/{y}.png"}, &out); err != nil {
+		t.Fatalf("runMapCommand: %v", err)
+	}
+	if requests != 0 {
+		t.Errorf("downloaded %d cached tiles", requests)
+	}
+}
@@ -0,0 +1,218 @@
+package publish
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// existingEventMode tells what to do when the event file already exists with
+// a different content.
+type existingEventMode int
+
+const (
+	// existingRefuse keeps the existing file and fails.
+	existingRefuse existingEventMode = iota
+	// existingMerge re-renders the template but keeps what was edited by
+	// hand.
+	existingMerge
+	// existingOverwrite replaces the existing file with the rendered one.
+	existingOverwrite
+)
+
+// section is a part of an event file that can be merged on its own: a front
+// matter field or a body section under a heading.
+type section struct {
+	key     string
+	content string
+}
+
+var (
+	frontMatterKeyRe = regexp.MustCompile(`^[^\s#-][^:]*:`)
+	headingRe        = regexp.MustCompile(`^#{1,6}\s`)
+)
+
+// splitEvent splits the content of an event file into its front matter and
+// its body.
+func splitEvent(content string) (frontMatter, body string, err error) {
+	if !strings.HasPrefix(content, "---\n") {
+		return "", "", errors.New("no front matter found")
+	}
+
+	rest := content[len("---\n"):]
+	lines := strings.SplitAfter(rest, "\n")
+	offset := 0
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "---" {
+			return rest[:offset], rest[offset+len(line):], nil
+		}
+		offset += len(line)
+	}
+	return "", "", errors.New("unterminated front matter")
+}
+
+// splitSections cuts text into sections, each starting at a line for which
+// startKey returns a key. The text before the first one has an empty key. The same
+// key seen several times gets its position appended so keys stay unique.
+func splitSections(text string, startKey func(line string) (string, bool)) []section {
+	var sections []section
+	seen := map[string]int{}
+	current := section{}
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if line == "" {
+			continue
+		}
+		if key, ok := startKey(line); ok {
+			if current.key != "" || current.content != "" {
+				sections = append(sections, current)
+			}
+			seen[key]++
+			if seen[key] > 1 {
+				key = fmt.Sprintf("%s#%d", key, seen[key])
+			}
+			current = section{key: key}
+		}
+		current.content += line
+	}
+	if current.key != "" || current.content != "" {
+		sections = append(sections, current)
+	}
+	return sections
+}
+
+// frontMatterSections cuts the front matter in top level fields, with their
+// nested values.
+func frontMatterSections(frontMatter string) []section {
+	return splitSections(frontMatter, func(line string) (string, bool) {
+		key := frontMatterKeyRe.FindString(line)
+		return strings.TrimSpace(strings.TrimSuffix(key, ":")), key != ""
+	})
+}
+
+// bodySections cuts the markdown body at each heading.
+func bodySections(body string) []section {
+	return splitSections(body, func(line string) (string, bool) {
+		if !headingRe.MatchString(line) {
+			return "", false
+		}
+		return strings.TrimSpace(line), true
+	})
+}
+
+func sectionsByKey(sections []section) map[string]string {
+	m := make(map[string]string, len(sections))
+	for _, s := range sections {
+		m[s.key] = s.content
+	}
+	return m
+}
+
+// mergeSections merges the rendered sections into the existing ones. With
+// the base, the file as first generated, a section edited by hand is one
+// that differs from the base: it is kept, while untouched sections follow the
+// template. Without the base, every existing section is considered edited by
+// hand and the template only brings new sections.
+func mergeSections(base, existing, rendered []section, hasBase bool) []section {
+	baseByKey := sectionsByKey(base)
+	existingByKey := sectionsByKey(existing)
+	renderedByKey := sectionsByKey(rendered)
+
+	var merged []section
+	for _, r := range rendered {
+		e, inExisting := existingByKey[r.key]
+		b, inBase := baseByKey[r.key]
+		switch {
+		case !inExisting && hasBase && inBase:
+			// Removed by hand
+		case !inExisting:
+			merged = append(merged, r)
+		case hasBase && inBase && e == b:
+			merged = append(merged, r)
+		default:
+			merged = append(merged, section{key: r.key, content: e})
+		}
+	}
+
+	// Keep the sections added by hand, after the section they followed
+	for i, e := range existing {
+		if _, ok := renderedByKey[e.key]; ok {
+			continue
+		}
+		if b, inBase := baseByKey[e.key]; hasBase && inBase && b == e.content {
+			// Removed from the template and untouched
+			continue
+		}
+
+		at := 0
+		for j := i - 1; j >= 0 && at == 0; j-- {
+			for k, m := range merged {
+				if m.key == existing[j].key {
+					at = k + 1
+					break
+				}
+			}
+		}
+		merged = append(merged[:at], append([]section{e}, merged[at:]...)...)
+	}
+
+	return merged
+}
+
+func joinSections(sections []section) string {
+	var b strings.Builder
+	for _, s := range sections {
+		b.WriteString(s.content)
+	}
+	return b.String()
+}
+
+// mergeEvent re-renders an existing event, keeping its front matter fields
+// and body sections edited by hand. base is the event as first generated, or
+// nil if unknown.
+func mergeEvent(base, existing, rendered []byte) ([]byte, error) {
+	existingFM, existingBody, err := splitEvent(string(existing))
+	if err != nil {
+		return nil, fmt.Errorf("existing event: %v", err)
+	}
+	renderedFM, renderedBody, err := splitEvent(string(rendered))
+	if err != nil {
+		return nil, fmt.Errorf("rendered event: %v", err)
+	}
+
+	var baseFM, baseBody string
+	hasBase := base != nil
+	if hasBase {
+		baseFM, baseBody, err = splitEvent(string(base))
+		if err != nil {
+			// Not worth failing for, merge without it.
+			hasBase = false
+		}
+	}
+
+	frontMatter := mergeSections(frontMatterSections(baseFM), frontMatterSections(existingFM), frontMatterSections(renderedFM), hasBase)
+	body := mergeSections(bodySections(baseBody), bodySections(existingBody), bodySections(renderedBody), hasBase)
+
+	return []byte("---\n" + joinSections(frontMatter) + "---\n" + joinSections(body)), nil
+}
+
+// originalEvent returns the event file as it was when first committed, or
+// nil if it can't be found in the git history.
+func originalEvent(runner gitCommandRunner, repoDir, path string) []byte {
+	out, err := runGitCommandWrapper(runner, repoDir, "log", "--diff-filter=A", "--format=%H", "--", path)
+	if err != nil {
+		return nil
+	}
+
+	hashes := strings.Fields(out)
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	// The oldest commit adding the file is listed last
+	content, err := runGitCommandWrapper(runner, repoDir, "show", hashes[len(hashes)-1]+":"+path)
+	if err != nil {
+		return nil
+	}
+	return []byte(content)
+}
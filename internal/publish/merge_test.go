@@ -0,0 +1,239 @@
+package publish
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/osfs"
+)
+
+const mergeBase = `---
+title: "Bal"
+startDate: "2024-12-24T18:30:00+02:00"
+place: La Kulture
+social_media:
+  instagram: https://www.instagram.com/lakulture.v2/
+---
+
+Chaque mardi on danse.
+
+# Programme
+
+- 19h : initiation
+- 20h : bal
+
+# Infos
+
+Entrée libre.
+`
+
+func TestMergeEvent(t *testing.T) {
+	// The event edited by hand: new guest in the title, a new field, a new
+	// section, and a modified program.
+	existing := `---
+title: "Bal avec Trio Nordestino"
+startDate: "2024-12-24T18:30:00+02:00"
+place: La Kulture
+guest: Trio Nordestino
+social_media:
+  instagram: https://www.instagram.com/lakulture.v2/
+---
+
+Chaque mardi on danse.
+
+# Programme
+
+- 19h : initiation
+- 20h : concert
+
+# Le groupe
+
+Trio Nordestino vient de Recife.
+
+# Infos
+
+Entrée libre.
+`
+
+	// The template changed: new place and infos, a new price field, and the
+	// social media field dropped.
+	rendered := `---
+title: "Bal"
+startDate: "2024-12-24T18:30:00+02:00"
+place: La Kulture, 9 rue des bateliers
+price: gratuit
+---
+
+Chaque mardi on danse.
+
+# Programme
+
+- 19h : initiation
+- 20h : bal
+
+# Infos
+
+Entrée libre, consommation au bar.
+`
+
+	tests := []struct {
+		name string
+		base []byte
+		want string
+	}{
+		{
+			name: "with the original version",
+			base: []byte(mergeBase),
+			want: `---
+title: "Bal avec Trio Nordestino"
+startDate: "2024-12-24T18:30:00+02:00"
+place: La Kulture, 9 rue des bateliers
+guest: Trio Nordestino
+price: gratuit
+---
+
+Chaque mardi on danse.
+
+# Programme
+
+- 19h : initiation
+- 20h : concert
+
+# Le groupe
+
+Trio Nordestino vient de Recife.
+
+# Infos
+
+Entrée libre, consommation au bar.
+`,
+		},
+		{
+			name: "without the original version",
+			want: `---
+title: "Bal avec Trio Nordestino"
+startDate: "2024-12-24T18:30:00+02:00"
+place: La Kulture
+guest: Trio Nordestino
+social_media:
+  instagram: https://www.instagram.com/lakulture.v2/
+price: gratuit
+---
+
+Chaque mardi on danse.
+
+# Programme
+
+- 19h : initiation
+- 20h : concert
+
+# Le groupe
+
+Trio Nordestino vient de Recife.
+
+# Infos
+
+Entrée libre.
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeEvent(tt.base, []byte(existing), []byte(rendered))
+			if err != nil {
+				t.Fatalf("mergeEvent() unexpected error: %v", err)
+			}
+			if string(got) != tt.want {
+				diff, _, _ := eventDiff(osfs.New(""), writeTemp(t, tt.want), got)
+				t.Errorf("mergeEvent() differs from the expected result:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMergeEventInvalid(t *testing.T) {
+	if _, err := mergeEvent(nil, []byte("no front matter"), []byte(mergeBase)); err == nil {
+		t.Error("mergeEvent() expected error for an existing event without front matter")
+	}
+	if _, err := mergeEvent(nil, []byte(mergeBase), []byte("---\ntitle: unterminated\n")); err == nil {
+		t.Error("mergeEvent() expected error for an unterminated front matter")
+	}
+}
+
+func writeTemp(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "event.md")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	return path
+}
+
+func TestResolveExistingEvent(t *testing.T) {
+	rendered := strings.Replace(mergeBase, "La Kulture", "Social Bar", 1)
+	edited := strings.Replace(mergeBase, `title: "Bal"`, `title: "Bal de Noël"`, 1)
+
+	// The git history knows the original version of the event
+	runner := func(dir string, args ...string) (string, error) {
+		switch args[0] {
+		case "log":
+			return "newer\noldest\n", nil
+		case "show":
+			if !strings.HasPrefix(args[1], "oldest:") {
+				return "", fmt.Errorf("unexpected revision %s", args[1])
+			}
+			return mergeBase, nil
+		}
+		return "", fmt.Errorf("unexpected git command %v", args)
+	}
+
+	tests := []struct {
+		name        string
+		existing    string
+		mode        existingEventMode
+		want        string
+		errContains string
+	}{
+		{name: "no existing event", mode: existingRefuse, want: rendered},
+		{name: "identical existing event", existing: rendered, mode: existingRefuse, want: rendered},
+		{name: "refuse", existing: edited, mode: existingRefuse, errContains: "-title: \"Bal de Noël\""},
+		{name: "overwrite", existing: edited, mode: existingOverwrite, want: rendered},
+		{
+			name:     "merge",
+			existing: edited,
+			mode:     existingMerge,
+			want:     strings.Replace(rendered, `title: "Bal"`, `title: "Bal de Noël"`, 1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			t.Chdir(tmpDir)
+			path := "event.md"
+			if tt.existing != "" {
+				if err := os.WriteFile(path, []byte(tt.existing), 0644); err != nil {
+					t.Fatalf("Failed to write existing event: %v", err)
+				}
+			}
+
+			got, err := resolveExistingEvent(osRepository(runner, nil), path, []byte(rendered), tt.mode)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveExistingEvent() unexpected error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("resolveExistingEvent() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,959 @@
+package publish
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/pmezard/go-difflib/difflib"
+	"gopkg.in/yaml.v3"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/event"
+	"github.com/dolanor/forrostrasbourg.fr/internal/secrets"
+)
+
+// EventData holds date-related information for the event.
+type EventData struct {
+	Date                string
+	LongDate            string
+	LongDateCapitalized string
+	// Time is the start time (HH:MM), from -time or the template catalog.
+	Time string
+	// Vars holds the custom variables given with -var and -vars, available
+	// in templates as {{.Vars.name}}.
+	Vars map[string]any
+}
+
+// templateVars collects the repeatable -var key=value flags.
+type templateVars map[string]any
+
+func (v templateVars) String() string {
+	pairs := make([]string, 0, len(v))
+	for key, value := range v {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", key, value))
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (v templateVars) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return fmt.Errorf("invalid variable %q, expected key=value", s)
+	}
+	v[key] = value
+	return nil
+}
+
+// loadVarsFile reads template variables from a YAML file.
+func loadVarsFile(path string) (map[string]any, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vars file: %v", err)
+	}
+
+	vars := map[string]any{}
+	if err := yaml.Unmarshal(b, &vars); err != nil {
+		return nil, fmt.Errorf("failed to parse vars file %s: %v", path, err)
+	}
+	return vars, nil
+}
+
+// mergeVars returns a new map with the variables of each map, later maps
+// overriding earlier ones.
+func mergeVars(maps ...map[string]any) map[string]any {
+	merged := map[string]any{}
+	for _, m := range maps {
+		for key, value := range m {
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// FrontMatterData holds the front matter data extracted from the markdown
+// file, as told in the announcements.
+type FrontMatterData struct {
+	Title string
+	Venue string
+	Place string
+	City  string
+	// Category and Tags classify the event, see event.Categories.
+	Category string
+	Tags     []string
+	// Address is the canonical address of the venue, if any.
+	Address string
+	// MapURL links to the venue on OpenStreetMap, if it has coordinates.
+	MapURL string
+}
+
+// newFrontMatterData takes the front matter data of the event, with the
+// canonical address of its venue from venues.
+func newFrontMatterData(e event.Event, venues event.Venues) FrontMatterData {
+	fmData := FrontMatterData{
+		Title:    e.Title,
+		Venue:    e.Venue,
+		Place:    e.Place,
+		City:     e.City,
+		Category: e.Category,
+		Tags:     e.Tags,
+	}
+	if v, ok := venues[e.Venue]; ok {
+		fmData.Address = v.FullAddress()
+		fmData.MapURL = v.OSMURL()
+	}
+	return fmData
+}
+
+// location is where the event happens, as told in the announcements, with
+// a link to the map.
+func (fm FrontMatterData) location() string {
+	location := fm.Place + ", " + fm.City
+	if fm.Address != "" {
+		location = fm.Address
+	}
+	if fm.MapURL != "" {
+		location += "\n📍 " + fm.MapURL
+	}
+	return location
+}
+
+// eventsDir is where the event markdown files are created.
+const eventsDir = "content/evenements"
+
+// gitCommandRunner is a function type for running git commands
+type gitCommandRunner func(dir string, args ...string) (string, error)
+
+// gitChangeChecker is a function type for checking git changes
+type gitChangeChecker func(dir, filePath string) (bool, error)
+
+// repoDir is where the git commands run: the root of the worktree, the
+// working directory for the git binary.
+const repoDir = "."
+
+// gitRepository is the worktree the events are written to, and the git
+// commands run on it.
+type gitRepository struct {
+	files        billy.Filesystem
+	run          gitCommandRunner
+	checkChanges gitChangeChecker
+}
+
+// osRepository is the repository of the working directory, with the git
+// commands of runner and checker.
+func osRepository(runner gitCommandRunner, checker gitChangeChecker) gitRepository {
+	return gitRepository{files: osfs.New(""), run: runner, checkChanges: checker}
+}
+
+// Default implementations
+var (
+	runGitCommand gitCommandRunner = func(dir string, args ...string) (string, error) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return string(output), fmt.Errorf("failed to run git command '%v': %v\nOutput: %s", args, err, string(output))
+		}
+		return string(output), nil
+	}
+
+	runGitCheckChanges gitChangeChecker = func(dir, filePath string) (bool, error) {
+		cmd := exec.Command("git", "diff", "--cached", "--exit-code", filePath)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				if exitErr.ExitCode() == 1 {
+					return true, nil
+				}
+			}
+			return false, fmt.Errorf("error running git diff: %v\nOutput: %s", err, string(output))
+		}
+		return false, nil
+	}
+)
+
+// runGitCommand executes a Git command in the specified directory.
+func runGitCommandWrapper(runner gitCommandRunner, dir string, args ...string) (string, error) {
+	return runner(dir, args...)
+}
+
+// runGitCheckChanges checks if there are staged changes for the specified file.
+func runGitCheckChangesWrapper(checker gitChangeChecker, dir, filePath string) (bool, error) {
+	return checker(dir, filePath)
+}
+
+func getWeekdayName(d time.Time, lang string) string {
+	var weekdays []string
+	switch lang {
+	case "fr":
+		weekdays = []string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"}
+	default:
+		weekdays = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+	}
+
+	return weekdays[int(d.Weekday())]
+}
+
+func getMonthName(d time.Time, lang string) string {
+	var months []string
+	switch lang {
+	case "fr":
+		months = []string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"}
+	default:
+		months = []string{"january", "february", "march", "april", "may", "june", "july", "august", "september", "october", "november", "december"}
+	}
+
+	return months[int(d.Month())-1]
+}
+
+func capitalizeFirstLetter(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(string(s[0])) + s[1:]
+}
+
+// extractFrontMatter parses the front matter from the generated markdown file
+// and returns title, place, and city.
+func extractFrontMatter(files billy.Filesystem, filePath string) (FrontMatterData, error) {
+	content, err := util.ReadFile(files, filePath)
+	if err != nil {
+		return FrontMatterData{}, fmt.Errorf("failed to open file for front matter parsing: %v", err)
+	}
+
+	fmData, err := parseFrontMatter(content)
+	if err != nil {
+		return fmData, fmt.Errorf("%v in %s", err, filePath)
+	}
+	return fmData, nil
+}
+
+// parseFrontMatter parses the front matter of markdown content, and
+// resolves its venue from the registry.
+func parseFrontMatter(content []byte) (FrontMatterData, error) {
+	e, err := event.Parse(content)
+	if err != nil {
+		return FrontMatterData{}, err
+	}
+	if e.Venue == "" {
+		return newFrontMatterData(e, nil), nil
+	}
+
+	venues, err := loadVenues()
+	if err != nil {
+		return FrontMatterData{}, err
+	}
+	if err := venues.Resolve(&e); err != nil {
+		return FrontMatterData{}, fmt.Errorf("%v, see %s", err, event.VenuesFile)
+	}
+	return newFrontMatterData(e, venues), nil
+}
+
+// newEventData prepares the data given to the templates.
+func newEventData(parsedDate time.Time, dateStr, lang, startTime string, vars map[string]any) EventData {
+	weekdayLower := getWeekdayName(parsedDate, lang)
+	monthLower := getMonthName(parsedDate, lang)
+	day := parsedDate.Day()
+	longDate := fmt.Sprintf("%s %d %s", weekdayLower, day, monthLower)
+	longDateCapitalized := fmt.Sprintf("%s %d %s", capitalizeFirstLetter(weekdayLower), day, monthLower)
+
+	return EventData{
+		Date:                dateStr,
+		LongDate:            longDate,
+		LongDateCapitalized: longDateCapitalized,
+		Time:                startTime,
+		Vars:                mergeVars(vars),
+	}
+}
+
+// renderTemplate executes the template file of tmplInfo with data and
+// returns the rendered event, with the category and tags of the catalog if
+// the template doesn't tell.
+func renderTemplate(tmplInfo TemplateInfo, data EventData) ([]byte, error) {
+	templatePath := tmplInfo.File
+	// Fail on variables the template uses but nobody provided, instead
+	// of rendering "<no value>" in the event page.
+	tmpl, err := template.New(filepath.Base(templatePath)).Option("missingkey=error").ParseFiles(templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template file: %v", err)
+	}
+	// An empty time would render a broken start date
+	if data.Time == "" && usesTime(tmpl) {
+		return nil, fmt.Errorf("template %s uses {{.Time}} but no start time was given, set one or the time of the template in the catalog", filepath.Base(templatePath))
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return nil, fmt.Errorf("error executing template: %v", err)
+	}
+	return withCatalogDefaults(rendered.Bytes(), tmplInfo)
+}
+
+// withCatalogDefaults adds the category and tags of the catalog to the front
+// matter of the rendered event, unless it has its own.
+func withCatalogDefaults(rendered []byte, tmplInfo TemplateInfo) ([]byte, error) {
+	if tmplInfo.Category == "" && len(tmplInfo.Tags) == 0 {
+		return rendered, nil
+	}
+	e, err := event.Parse(rendered)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rendered event: %v", err)
+	}
+
+	var defaults strings.Builder
+	if e.Category == "" && tmplInfo.Category != "" {
+		fmt.Fprintf(&defaults, "category: %s\n", tmplInfo.Category)
+	}
+	if len(e.Tags) == 0 && len(tmplInfo.Tags) > 0 {
+		fmt.Fprintf(&defaults, "tags: [%s]\n", strings.Join(tmplInfo.Tags, ", "))
+	}
+	if defaults.Len() == 0 {
+		return rendered, nil
+	}
+	return appendFrontMatter(rendered, defaults.String())
+}
+
+// appendFrontMatter adds the lines at the end of the front matter of the
+// event page, right before its closing ---.
+func appendFrontMatter(content []byte, lines string) ([]byte, error) {
+	text := string(content)
+	start := strings.Index(text, "---\n") + len("---\n")
+	end := strings.Index(text[start:], "\n---")
+	if start < len("---\n") || end < 0 {
+		return nil, errors.New("invalid event: unterminated front matter")
+	}
+	end += start + 1
+	return []byte(text[:end] + lines + text[end:]), nil
+}
+
+// eventPaths returns the path of the markdown file and the URL of the event
+// generated from the template for the given date.
+func eventPaths(tmplInfo TemplateInfo, parsedDate time.Time) (outputPath, eventURL string) {
+	// Convert date to YYMMDD format
+	formattedDate := parsedDate.Format("060102")
+
+	// Construct the output filename: e.g. "241129-pachamamas.md"
+	outputFilename := fmt.Sprintf("%s-%s.md", formattedDate, tmplInfo.slug())
+	outputPath = filepath.Join(eventsDir, outputFilename)
+
+	// Construct the event URL
+	eventSlug := strings.TrimSuffix(outputFilename, ".md") // e.g. "241129-pachamamas"
+	eventURL = fmt.Sprintf("https://forrostrasbourg.fr/evenements/%s/", eventSlug)
+
+	return outputPath, eventURL
+}
+
+// publishEventMarkdown creates the markdown file and handles git operations.
+// It logs every action and performs it only if dryRun is false.
+// Returns outputPath, EventData, FrontMatterData, a boolean if event was already published, and eventURL.
+func publishEventMarkdown(tmplInfo TemplateInfo, parsedDate time.Time, dateStr, lang string, vars map[string]any, existing existingEventMode, commit commitSettings, dryRun bool, repo gitRepository) (string, EventData, FrontMatterData, bool, string, error) {
+	outputPath, eventURL := eventPaths(tmplInfo, parsedDate)
+
+	data := newEventData(parsedDate, dateStr, lang, tmplInfo.Time, vars)
+
+	// Render in memory first so a failing template doesn't leave a
+	// half-written event behind, and so dry runs catch template errors.
+	rendered, err := renderTemplate(tmplInfo, data)
+	if err != nil {
+		return "", data, FrontMatterData{}, false, eventURL, err
+	}
+
+	// Don't silently clobber the edits made by hand on an existing event
+	content, err := resolveExistingEvent(repo, outputPath, rendered, existing)
+	if err != nil {
+		return "", data, FrontMatterData{}, false, eventURL, err
+	}
+
+	// The structured data for the search engines, embedded by the page
+	venues, err := loadVenues()
+	if err != nil {
+		return "", data, FrontMatterData{}, false, eventURL, err
+	}
+	jsonldPath, jsonld, err := eventJSONLD(outputPath, content, venues)
+	if err != nil {
+		return "", data, FrontMatterData{}, false, eventURL, err
+	}
+
+	// The map of the venue, drawn from the cached tiles. The event can do
+	// without it.
+	mapImagePath, mapImage, err := eventMap(outputPath, content, venues)
+	if err != nil {
+		log.Printf("Warning: no map for the event: %v", err)
+	}
+
+	// Log file creation
+	log.Printf("Creating event markdown file at: %s", outputPath)
+	diff, exists, err := eventDiff(repo.files, outputPath, content)
+	if err != nil {
+		return "", data, FrontMatterData{}, false, eventURL, err
+	}
+	if dryRun {
+		switch {
+		case !exists:
+			log.Printf("[Dry Run] Would create %s with:\n%s", outputPath, content)
+		case diff == "":
+			log.Printf("[Dry Run] %s already exists and wouldn't change", outputPath)
+		default:
+			log.Printf("[Dry Run] %s already exists, it would change as follows:\n%s", outputPath, diff)
+		}
+	} else {
+		if diff != "" {
+			log.Printf("Updating existing event %s:\n%s", outputPath, diff)
+		}
+
+		if err := writeFile(repo.files, outputPath, content); err != nil {
+			return "", data, FrontMatterData{}, false, eventURL, fmt.Errorf("failed to create output file: %v", err)
+		}
+	}
+
+	if jsonldPath != "" {
+		log.Printf("Writing structured data at: %s", jsonldPath)
+		if dryRun {
+			log.Printf("[Dry Run] Would write %s with:\n%s", jsonldPath, jsonld)
+		} else if err := writeFile(repo.files, jsonldPath, jsonld); err != nil {
+			return "", data, FrontMatterData{}, false, eventURL, fmt.Errorf("failed to write structured data: %v", err)
+		}
+	}
+
+	if mapImagePath != "" {
+		log.Printf("Writing map at: %s", mapImagePath)
+		if dryRun {
+			log.Printf("[Dry Run] Would write the %d bytes map %s", len(mapImage), mapImagePath)
+		} else if err := writeFile(repo.files, mapImagePath, mapImage); err != nil {
+			return "", data, FrontMatterData{}, false, eventURL, fmt.Errorf("failed to write map: %v", err)
+		}
+	}
+
+	var fmData FrontMatterData
+	if dryRun {
+		fmData, err = parseFrontMatter(content)
+	} else {
+		fmData, err = extractFrontMatter(repo.files, outputPath)
+	}
+	if err != nil {
+		return outputPath, data, fmData, false, eventURL, fmt.Errorf("failed to extract front matter: %v", err)
+	}
+
+	// Log git add
+	paths := []string{outputPath}
+	if jsonldPath != "" {
+		paths = append(paths, jsonldPath)
+	}
+	if mapImagePath != "" {
+		paths = append(paths, mapImagePath)
+	}
+	log.Printf("Running 'git add' on %s", strings.Join(paths, " "))
+	if !dryRun {
+		if _, err := runGitCommandWrapper(repo.run, repoDir, append([]string{"add"}, paths...)...); err != nil {
+			return outputPath, data, fmData, false, eventURL, fmt.Errorf("git add failed: %v", err)
+		}
+
+		// Now check if there are any changes via git diff
+		hasChanges := false
+		for _, path := range paths {
+			changed, err := runGitCheckChangesWrapper(repo.checkChanges, repoDir, path)
+			if err != nil {
+				return outputPath, data, fmData, false, eventURL, err
+			}
+			hasChanges = hasChanges || changed
+		}
+		if !hasChanges {
+			// No changes to commit
+			log.Println("No changes detected. The event appears to be already published.")
+			return outputPath, data, fmData, true, eventURL, nil
+		}
+
+		// If we reach here, changes are present, proceed to commit
+		commitArgs, err := commit.args(newCommitMessageData(tmplInfo, dateStr, outputPath, eventURL, fmData))
+		if err != nil {
+			return outputPath, data, fmData, false, eventURL, err
+		}
+		log.Printf("Running 'git commit' with message: %q", commitArgs[2])
+		if _, err := runGitCommandWrapper(repo.run, repoDir, commitArgs...); err != nil {
+			return outputPath, data, fmData, false, eventURL, fmt.Errorf("git commit failed: %v", err)
+		}
+
+		// Log git push
+		//log.Println("Running 'git push'")
+		//if _, err := runGitCommandWrapper(runner, repoDir, "push"); err != nil {
+		//	return outputPath, data, fmData, false, eventURL, fmt.Errorf("git push failed: %v", err)
+		//}
+	}
+
+	return outputPath, data, fmData, false, eventURL, nil
+}
+
+// resolveExistingEvent returns the content to write at outputPath, according
+// to what should be done with an existing event file that differs from the
+// rendered one.
+func resolveExistingEvent(repo gitRepository, outputPath string, rendered []byte, existing existingEventMode) ([]byte, error) {
+	existingContent, err := util.ReadFile(repo.files, outputPath)
+	if os.IsNotExist(err) {
+		return rendered, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read existing event: %v", err)
+	}
+	if bytes.Equal(existingContent, rendered) {
+		return rendered, nil
+	}
+
+	switch existing {
+	case existingOverwrite:
+		return rendered, nil
+
+	case existingMerge:
+		base := originalEvent(repo.run, repoDir, outputPath)
+		if base == nil {
+			log.Printf("Original version of %s not found in git, keeping all its existing fields and sections", outputPath)
+		}
+		merged, err := mergeEvent(base, existingContent, rendered)
+		if err != nil {
+			return nil, fmt.Errorf("failed to merge existing event: %v", err)
+		}
+		return merged, nil
+
+	default:
+		diff, _, err := eventDiff(repo.files, outputPath, rendered)
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("event file %s already exists and differs from the template, use -merge to keep its manual edits or -overwrite to replace it:\n%s", outputPath, diff)
+	}
+}
+
+// eventDiff returns the unified diff between the event file at path, if it
+// exists in files, and its rendered content.
+func eventDiff(files billy.Filesystem, path string, rendered []byte) (diff string, exists bool, err error) {
+	existing, err := util.ReadFile(files, path)
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read existing event: %v", err)
+	}
+
+	diff, err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(existing)),
+		B:        difflib.SplitLines(string(rendered)),
+		FromFile: path,
+		ToFile:   path + " (rendered)",
+		Context:  3,
+	})
+	if err != nil {
+		return "", true, fmt.Errorf("failed to diff existing event: %v", err)
+	}
+	return diff, true, nil
+}
+
+// waitForEventPage checks the given URL periodically until it gets a 200 response or hits a timeout.
+func waitForEventPage(eventURL string, timeout, interval time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(eventURL)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				// Page is live
+				return nil
+			}
+		}
+
+		// Page not live yet, wait before retrying
+		log.Printf("Event page not available yet. Retrying in %v...", interval)
+		time.Sleep(interval)
+	}
+
+	return errors.New("timed out waiting for the event page to become available")
+}
+
+// facebookMessage creates a simple French message describing the event.
+func facebookMessage(data EventData, fmData FrontMatterData, eventURL string) string {
+	return fmt.Sprintf(
+		`%s: %s
+%s
+
+Plus d'informations :
+%s`,
+		data.LongDateCapitalized,
+		fmData.Title,
+		fmData.location(),
+		eventURL,
+	)
+}
+
+// publishEventOnFacebook posts the event details to a given Facebook page.
+// It returns the URL of the published Facebook post.
+func publishEventOnFacebook(data EventData, fmData FrontMatterData, eventURL, pageID, pageAccessToken string, dryRun bool) (string, error) {
+	log.Printf("Publishing event on Facebook Page: %s", pageID)
+
+	message := facebookMessage(data, fmData, eventURL)
+
+	if dryRun {
+		log.Println("[Dry Run] Would publish the following message to Facebook:")
+		log.Println(message)
+		simulatedPostURL := fmt.Sprintf("https://www.facebook.com/%s/posts/SimulatedPostID", pageID)
+		log.Printf("[Dry Run] Simulated Facebook post URL: %s\n", simulatedPostURL)
+		return simulatedPostURL, nil
+	}
+
+	// Prepare the request payload
+	url := fmt.Sprintf("https://graph.facebook.com/%s/feed", pageID)
+	requestBody := map[string]string{
+		"message":      message,
+		"access_token": pageAccessToken,
+	}
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling request body: %v", err)
+	}
+
+	// Perform the POST request to Facebook Graph API
+	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("error posting to Facebook: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var fbErr map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&fbErr); err == nil {
+			return "", fmt.Errorf("facebook API returned status %d: %v", resp.StatusCode, fbErr)
+		}
+		return "", fmt.Errorf("facebook API returned status %d", resp.StatusCode)
+	}
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("error decoding response body: %v", err)
+	}
+
+	// Extract the 'id' from the response
+	postID, ok := result["id"].(string)
+	if !ok || postID == "" {
+		return "", fmt.Errorf("no 'id' returned from Facebook API")
+	}
+
+	// Split the 'id' into pageID and postID
+	parts := strings.Split(postID, "_")
+	if len(parts) != 2 {
+		return "", fmt.Errorf("unexpected format for post id: %s", postID)
+	}
+
+	extractedPageID := parts[0]
+	fbPostID := parts[1]
+	postURL := fmt.Sprintf("https://www.facebook.com/%s/posts/%s", extractedPageID, fbPostID)
+
+	log.Printf("Post published successfully on Facebook at: %s\n", postURL)
+	return postURL, nil
+}
+
+// EventContext contains all parameters needed for event publishing
+type EventContext struct {
+	// Date of the event. If zero, the next date matching the template's
+	// default weekday is used.
+	Date            time.Time
+	TemplatePath    string // Catalog template name or path to a template file
+	CatalogPath     string
+	Time            string // Start time overriding the catalog default
+	Language        string
+	DryRun          bool
+	PublishFacebook bool
+	PageAccessToken string
+	FacebookPages   string // Comma-separated list of Facebook pages to publish to
+	Vars            map[string]any
+	Existing        existingEventMode // What to do if the event file exists with other content
+	GitBackend      string            // "exec" (default) to run the git binary, "go" to use go-git
+	Review          *ReviewOptions    // If set, go through a pull request instead of committing on the current branch
+	CommitMessage   string            // Commit message template, see commitMessageData
+	CommitAuthor    string            // Commit author override, as "Name <email>"
+}
+
+// facebookPageNames returns the pages to publish to from the
+// comma-separated list, "all" or empty meaning every page.
+func facebookPageNames(pages string) []string {
+	if pages == "" || pages == "all" {
+		return []string{"forro-a-strasbourg", "forro-stras"}
+	}
+	return strings.Split(pages, ",")
+}
+
+// facebookPageAccessToken reads FACEBOOK_PAGE_ACCESS_TOKEN from the
+// secrets: env, encrypted secrets file or keyring. It is empty if missing,
+// for publishEvent to tell.
+func facebookPageAccessToken() (string, error) {
+	token, err := secrets.Get("FACEBOOK_PAGE_ACCESS_TOKEN")
+	if errors.Is(err, secrets.ErrNotFound) {
+		return "", nil
+	}
+	return token, err
+}
+
+func publishEvent(ctx EventContext) error {
+	repo, err := gitBackend(ctx.GitBackend)
+	if err != nil {
+		return fmt.Errorf("error publishing event: %v", err)
+	}
+	return publishEventIn(ctx, repo)
+}
+
+// publishEventIn publishes the event of ctx, its files written and committed
+// in repo.
+func publishEventIn(ctx EventContext, repo gitRepository) error {
+	// Check FACEBOOK_PAGE_ACCESS_TOKEN once if publishing to Facebook
+	if ctx.PublishFacebook && ctx.PageAccessToken == "" {
+		return fmt.Errorf("FACEBOOK_PAGE_ACCESS_TOKEN not set in the env, the secrets file or the keyring")
+	}
+
+	catalog, err := loadTemplateCatalog(ctx.CatalogPath)
+	if err != nil {
+		return fmt.Errorf("error publishing event: %v", err)
+	}
+
+	tmplInfo, err := catalog.resolveTemplate(ctx.TemplatePath)
+	if err != nil {
+		return fmt.Errorf("error publishing event: %v", err)
+	}
+
+	if ctx.Time != "" {
+		if !timeOfDayRe.MatchString(ctx.Time) {
+			return fmt.Errorf("error publishing event: invalid time %q, expected HH:MM", ctx.Time)
+		}
+		tmplInfo.Time = ctx.Time
+	}
+
+	if ctx.Date.IsZero() {
+		if tmplInfo.Weekday == "" {
+			return fmt.Errorf("error publishing event: no date given and template %q has no default weekday", ctx.TemplatePath)
+		}
+		weekday, err := parseWeekday(tmplInfo.Weekday)
+		if err != nil {
+			return fmt.Errorf("error publishing event: %v", err)
+		}
+		ctx.Date = nextWeekday(time.Now(), weekday)
+		log.Printf("No date given, using next %s: %s", tmplInfo.Weekday, ctx.Date.Format("2006-01-02"))
+	}
+
+	// Default to the pages the template is usually published to
+	if ctx.FacebookPages == "" && len(tmplInfo.FacebookPages) > 0 {
+		ctx.FacebookPages = strings.Join(tmplInfo.FacebookPages, ",")
+	}
+
+	// Record where the event goes in the commit trailers
+	commit := commitSettings{
+		Message: ctx.CommitMessage,
+		Author:  ctx.CommitAuthor,
+		Targets: []string{"site"},
+	}
+	if ctx.PublishFacebook {
+		for _, page := range facebookPageNames(ctx.FacebookPages) {
+			commit.Targets = append(commit.Targets, "facebook:"+page)
+		}
+	}
+	if err := commit.validate(); err != nil {
+		return fmt.Errorf("error publishing event: %v", err)
+	}
+
+	// In review mode, the event is committed on its own branch
+	var review *reviewSession
+	if ctx.Review != nil {
+		outputPath, _ := eventPaths(tmplInfo, ctx.Date)
+		branch := reviewBranch(outputPath)
+		if ctx.DryRun {
+			log.Printf("[Dry Run] Would commit on branch %s, push it to %s and open a pull request against %s", branch, ctx.Review.Remote, ctx.Review.BaseBranch)
+		} else {
+			review, err = startReview(*ctx.Review, branch, repo.run)
+			if err != nil {
+				return fmt.Errorf("error publishing event: %v", err)
+			}
+		}
+	}
+
+	// Publish the markdown (file creation and git)
+	outputPath, data, fmData, alreadyPublished, eventURL, err := publishEventMarkdown(
+		tmplInfo,
+		ctx.Date,
+		ctx.Date.Format("2006-01-02"),
+		ctx.Language,
+		ctx.Vars,
+		ctx.Existing,
+		commit,
+		ctx.DryRun,
+		repo,
+	)
+	if review != nil {
+		if err != nil || alreadyPublished {
+			if abandonErr := review.abandon(); abandonErr != nil {
+				log.Printf("Failed to clean up branch %s: %v", review.branch, abandonErr)
+			}
+		} else {
+			msgData := newCommitMessageData(tmplInfo, ctx.Date.Format("2006-01-02"), outputPath, eventURL, fmData)
+			title, err := commit.subject(msgData)
+			if err != nil {
+				if abandonErr := review.abandon(); abandonErr != nil {
+					log.Printf("Failed to clean up branch %s: %v", review.branch, abandonErr)
+				}
+				return fmt.Errorf("error publishing event: %v", err)
+			}
+			body := fmt.Sprintf("Event page once deployed: %s\n\n%s", eventURL, commit.trailers(msgData))
+			if err := review.finish(title, body); err != nil {
+				return fmt.Errorf("error publishing event: %v", err)
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error publishing event: %v", err)
+	}
+
+	// Event is successfully published (git)
+	log.Printf("Event published successfully: %s\n", outputPath)
+
+	// Attempt Facebook publishing only if requested
+	if ctx.PublishFacebook {
+		if !ctx.DryRun {
+			log.Printf("Waiting for event page to become available: %s", eventURL)
+			if err := waitForEventPage(eventURL, 5*time.Minute, 10*time.Second); err != nil {
+				return fmt.Errorf("event page did not become available in time: %v", err)
+			}
+		}
+
+		// Define Facebook page IDs
+		pageIDs := map[string]string{
+			"forro-a-strasbourg": "351984064669408", // Forró à Strasbourg
+			"forro-stras":        "111247753705287", // Forró Stras
+		}
+
+		// Publish to each selected page
+		var publishErrors []string
+		for _, pageName := range facebookPageNames(ctx.FacebookPages) {
+			pageID, exists := pageIDs[pageName]
+			if !exists {
+				log.Printf("Warning: Unknown Facebook page '%s', skipping", pageName)
+				continue
+			}
+
+			log.Printf("Publishing to Facebook page: %s", pageName)
+			_, err := publishEventOnFacebook(data, fmData, eventURL, pageID, ctx.PageAccessToken, ctx.DryRun)
+			if err != nil {
+				errMsg := fmt.Sprintf("Failed to publish event on Facebook page '%s': %v", pageName, err)
+				log.Print(errMsg)
+				publishErrors = append(publishErrors, errMsg)
+				continue
+			}
+		}
+
+		// If any Facebook publishing failed, return an error with all failures
+		if len(publishErrors) > 0 {
+			return fmt.Errorf("Facebook publishing errors:\n%s", strings.Join(publishErrors, "\n"))
+		}
+	}
+
+	return nil
+}
+
+// runEventCommand implements the default command: it publishes the event of
+// a template.
+func runEventCommand(args []string) error {
+	fs := newFlagSet("", "")
+	dateStr := fs.String("date", "", "Event date in YYYY-MM-DD format (defaults to the next occurrence of the template's weekday)")
+	templatePath := fs.String("template", "", "Name of a template from the catalog (see 'templates list') or path to a template file (e.g. pachamamas.md.template)")
+	catalogPath := fs.String("catalog", defaultCatalogPath, "Path to the template catalog")
+	startTime := fs.String("time", "", "Event start time in HH:MM format (defaults to the template's time)")
+	lang := fs.String("lang", "fr", "Language code for date formatting (e.g. 'fr' or 'en')")
+	dryRun := fs.Bool("dry-run", false, "If true, only echo the actions without carrying them out")
+	publishFacebook := fs.Bool("publish-facebook", false, "If true, attempt to publish the event on Facebook")
+	facebookPages := fs.String("facebook-pages", "", "Comma-separated list of Facebook pages to publish to ('all', 'forro-a-strasbourg', 'forro-stras'), defaults to the template's pages or all")
+	varsFile := fs.String("vars", "", "Path to a YAML file of template variables, available as {{.Vars.name}}")
+	merge := fs.Bool("merge", false, "If the event file already exists, re-render it but keep the fields and sections edited by hand")
+	overwrite := fs.Bool("overwrite", false, "If the event file already exists, replace it with the rendered template")
+	gitBackendName := fs.String("git-backend", "exec", "Git implementation to use: 'exec' runs the git binary, 'go' uses a built-in implementation")
+	commitMessage := fs.String("commit-message", defaultCommitMessage, "Template of the commit message, with {{.Date}}, {{.Title}}, {{.Slug}}, {{.URL}}, {{.Template}} and {{.TemplateFile}}")
+	commitAuthor := fs.String("author", "", "Commit author override in 'Name <email>' format, e.g. for bots")
+	reviewOptions := addReviewFlags(fs)
+	vars := templateVars{}
+	fs.Var(vars, "var", "Template variable in key=value format, available as {{.Vars.key}} (can be repeated, overrides -vars)")
+	fs.Parse(args)
+
+	// Validate required flags
+	if *templatePath == "" {
+		return errors.New("you must provide a -template parameter")
+	}
+	if *merge && *overwrite {
+		return errors.New("-merge and -overwrite can't be used together")
+	}
+
+	existing := existingRefuse
+	switch {
+	case *merge:
+		existing = existingMerge
+	case *overwrite:
+		existing = existingOverwrite
+	}
+
+	review, err := reviewOptions()
+	if err != nil {
+		return err
+	}
+
+	// Parse the date
+	var parsedDate time.Time
+	if *dateStr != "" {
+		parsedDate, err = time.Parse("2006-01-02", *dateStr)
+		if err != nil {
+			return fmt.Errorf("invalid date format, expected YYYY-MM-DD, got %s: %v", *dateStr, err)
+		}
+	}
+
+	var fileVars map[string]any
+	if *varsFile != "" {
+		fileVars, err = loadVarsFile(*varsFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Get Facebook page access token from the secrets if needed
+	var pageAccessToken string
+	if *publishFacebook {
+		pageAccessToken, err = facebookPageAccessToken()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create context
+	ctx := EventContext{
+		Date:            parsedDate,
+		TemplatePath:    *templatePath,
+		CatalogPath:     *catalogPath,
+		Time:            *startTime,
+		Language:        *lang,
+		DryRun:          *dryRun,
+		PublishFacebook: *publishFacebook,
+		PageAccessToken: pageAccessToken,
+		FacebookPages:   *facebookPages,
+		Vars:            mergeVars(fileVars, vars),
+		Existing:        existing,
+		GitBackend:      *gitBackendName,
+		Review:          review,
+		CommitMessage:   *commitMessage,
+		CommitAuthor:    *commitAuthor,
+	}
+
+	return publishEvent(ctx)
+}
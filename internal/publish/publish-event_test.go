@@ -1,4 +1,4 @@
-package main
+package publish
 
 import (
 	"fmt"
@@ -6,9 +6,12 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/go-git/go-billy/v5/osfs"
 )
 
 func TestExtractFrontMatter(t *testing.T) {
@@ -61,7 +64,7 @@ title: [invalid yaml
 			}
 
 			// Test the function
-			fmData, err := extractFrontMatter(tmpFile)
+			fmData, err := extractFrontMatter(osfs.New(""), tmpFile)
 			if tt.wantErr {
 				if err == nil {
 					t.Error("extractFrontMatter() expected error but got none")
@@ -516,10 +519,48 @@ Capitalized: {{ .LongDateCapitalized }}`
 			expectError: false,
 		},
 		{
-			name:        "dry run",
-			setup:       func(string) {},
+			name: "dry run",
+			setup: func(dir string) {
+				templateContent := `---
+title: Test Event
+---
+Event on {{ .Date }}`
+				templatePath := filepath.Join(dir, "test.template.md")
+				if err := os.WriteFile(templatePath, []byte(templateContent), 0644); err != nil {
+					t.Fatalf("Failed to create template file: %v", err)
+				}
+			},
 			expectError: false,
 		},
+		{
+			name: "dry run with template error",
+			setup: func(dir string) {
+				templateContent := `---
+title: Test Event
+---
+Event on {{ .Date`
+				templatePath := filepath.Join(dir, "test.template.md")
+				if err := os.WriteFile(templatePath, []byte(templateContent), 0644); err != nil {
+					t.Fatalf("Failed to create template file: %v", err)
+				}
+			},
+			expectError: true,
+		},
+		{
+			name: "template using the time without one",
+			setup: func(dir string) {
+				templateContent := `---
+title: Test Event
+startDate: "{{ .Date }}T{{ .Time }}:00+01:00"
+---
+Event on {{ .Date }}`
+				templatePath := filepath.Join(dir, "test.template.md")
+				if err := os.WriteFile(templatePath, []byte(templateContent), 0644); err != nil {
+					t.Fatalf("Failed to create template file: %v", err)
+				}
+			},
+			expectError: true,
+		},
 		{
 			name:        "invalid template path",
 			setup:       func(string) {},
@@ -538,10 +579,13 @@ Capitalized: {{ .LongDateCapitalized }}`
 			// Run setup
 			tt.setup(testDir)
 
+			// Each case creates its event in its own directory
+			t.Chdir(testDir)
+
 			// Run the function
 			date := time.Date(2024, 12, 23, 0, 0, 0, 0, time.UTC)
 			templatePath := filepath.Join(testDir, "test.template.md")
-			_, _, _, _, _, err := publishEventMarkdown(templatePath, date, date.Format("2006-01-02"), "fr", tt.name == "dry run", mockGitCommand, mockGitCheckChanges)
+			_, _, _, _, _, err := publishEventMarkdown(TemplateInfo{File: templatePath}, date, date.Format("2006-01-02"), "fr", nil, existingRefuse, commitSettings{}, strings.HasPrefix(tt.name, "dry run"), osRepository(mockGitCommand, mockGitCheckChanges))
 
 			// Check results
 			if tt.expectError && err == nil {
@@ -562,6 +606,9 @@ func TestPublishEvent(t *testing.T) {
 		runGitCheckChanges = origGitCheckChanges
 	}()
 
+	// The pages are written in the current directory
+	t.Chdir(t.TempDir())
+
 	// Create a temporary template file
 	tmpDir := t.TempDir()
 	templatePath := filepath.Join(tmpDir, "test.md.template")
@@ -745,3 +792,228 @@ Capitalized: {{ .LongDateCapitalized }}`
 		})
 	}
 }
+
+func TestTemplateVarsSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		key     string
+		value   string
+		wantErr bool
+	}{
+		{name: "simple", input: "teacher=Maria", key: "teacher", value: "Maria"},
+		{name: "value with equal sign", input: "note=a=b", key: "note", value: "a=b"},
+		{name: "empty value", input: "price=", key: "price", value: ""},
+		{name: "missing equal sign", input: "teacher", wantErr: true},
+		{name: "missing key", input: "=Maria", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars := templateVars{}
+			err := vars.Set(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Set() expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Set() unexpected error: %v", err)
+			}
+			if vars[tt.key] != tt.value {
+				t.Errorf("vars[%q] = %v, want %q", tt.key, vars[tt.key], tt.value)
+			}
+		})
+	}
+}
+
+func TestPublishEventMarkdownVars(t *testing.T) {
+	mockGitCommand := func(dir string, args ...string) (string, error) { return "", nil }
+	mockGitCheckChanges := func(dir, filePath string) (bool, error) { return true, nil }
+
+	templateContent := `---
+title: Cours avec {{ .Vars.teacher }}
+place: Test Place
+city: Test City
+---
+Prix : {{ .Vars.price }}`
+
+	tests := []struct {
+		name        string
+		vars        map[string]any
+		wantTitle   string
+		errContains string
+	}{
+		{
+			name:      "all variables provided",
+			vars:      map[string]any{"teacher": "Maria", "price": "10€"},
+			wantTitle: "Cours avec Maria",
+		},
+		{
+			name:        "undefined variable",
+			vars:        map[string]any{"teacher": "Maria"},
+			errContains: `map has no entry for key "price"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			t.Chdir(tmpDir)
+
+			templatePath := filepath.Join(tmpDir, "cours.md.template")
+			if err := os.WriteFile(templatePath, []byte(templateContent), 0644); err != nil {
+				t.Fatalf("Failed to create template file: %v", err)
+			}
+
+			date := time.Date(2024, 12, 23, 0, 0, 0, 0, time.UTC)
+			outputPath, _, fmData, _, _, err := publishEventMarkdown(TemplateInfo{File: templatePath}, date, date.Format("2006-01-02"), "fr", tt.vars, existingRefuse, commitSettings{}, false, osRepository(mockGitCommand, mockGitCheckChanges))
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("error = %v, want error containing %q", err, tt.errContains)
+				}
+				if _, err := os.Stat(filepath.Join("content", "evenements", "241223-cours.md")); !os.IsNotExist(err) {
+					t.Errorf("event file should not be written when the template fails, stat error: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if fmData.Title != tt.wantTitle {
+				t.Errorf("Title = %q, want %q", fmData.Title, tt.wantTitle)
+			}
+			if _, err := os.Stat(outputPath); err != nil {
+				t.Errorf("event file not written: %v", err)
+			}
+		})
+	}
+}
+
+func TestWithCatalogDefaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		rendered string
+		tmplInfo TemplateInfo
+		want     string
+	}{
+		{
+			name:     "no defaults",
+			rendered: "---\ntitle: Bal\n---\nBody\n",
+			want:     "---\ntitle: Bal\n---\nBody\n",
+		},
+		{
+			name:     "catalog defaults",
+			rendered: "---\ntitle: Bal\n---\nBody\n",
+			tmplInfo: TemplateInfo{Category: "bal", Tags: []string{"initiation", "plein-air"}},
+			want:     "---\ntitle: Bal\ncategory: bal\ntags: [initiation, plein-air]\n---\nBody\n",
+		},
+		{
+			name:     "the template tells",
+			rendered: "---\ntitle: Stage\ncategory: stage\n---\nBody\n",
+			tmplInfo: TemplateInfo{Category: "bal", Tags: []string{"initiation"}},
+			want:     "---\ntitle: Stage\ncategory: stage\ntags: [initiation]\n---\nBody\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := withCatalogDefaults([]byte(tt.rendered), tt.tmplInfo)
+			if err != nil {
+				t.Fatalf("withCatalogDefaults: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("withCatalogDefaults =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublishEventMarkdownDryRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	failingGitCommand := func(dir string, args ...string) (string, error) {
+		t.Errorf("git %v run during a dry run", args)
+		return "", nil
+	}
+	failingGitCheckChanges := func(dir, filePath string) (bool, error) {
+		t.Errorf("git diff run during a dry run")
+		return false, nil
+	}
+
+	templatePath := filepath.Join(tmpDir, "bal.md.template")
+	templateContent := `---
+title: Bal
+place: La Kulture
+city: Strasbourg
+---
+Bal le {{ .LongDate }}
+`
+	if err := os.WriteFile(templatePath, []byte(templateContent), 0644); err != nil {
+		t.Fatalf("Failed to create template file: %v", err)
+	}
+
+	// An existing event with a manual edit
+	existingPath := filepath.Join("content", "evenements", "241224-bal.md")
+	if err := os.MkdirAll(filepath.Dir(existingPath), 0755); err != nil {
+		t.Fatalf("Failed to create events directory: %v", err)
+	}
+	existing := strings.Replace(templateContent, "{{ .LongDate }}", "mardi 24 décembre, avec DJ Zé", 1)
+	if err := os.WriteFile(existingPath, []byte(existing), 0644); err != nil {
+		t.Fatalf("Failed to create existing event: %v", err)
+	}
+
+	date := time.Date(2024, 12, 24, 0, 0, 0, 0, time.UTC)
+	_, _, _, _, _, err := publishEventMarkdown(TemplateInfo{File: templatePath}, date, date.Format("2006-01-02"), "fr", nil, existingRefuse, commitSettings{}, true, osRepository(failingGitCommand, failingGitCheckChanges))
+	if err == nil || !strings.Contains(err.Error(), "+Bal le mardi 24 décembre") {
+		t.Errorf("dry run on a modified event should fail with the diff, got error: %v", err)
+	}
+
+	_, _, fmData, _, _, err := publishEventMarkdown(TemplateInfo{File: templatePath}, date, date.Format("2006-01-02"), "fr", nil, existingOverwrite, commitSettings{}, true, osRepository(failingGitCommand, failingGitCheckChanges))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := FrontMatterData{Title: "Bal", Place: "La Kulture", City: "Strasbourg"}
+	if !reflect.DeepEqual(fmData, want) {
+		t.Errorf("front matter = %+v, want %+v", fmData, want)
+	}
+
+	got, err := os.ReadFile(existingPath)
+	if err != nil {
+		t.Fatalf("Failed to read existing event: %v", err)
+	}
+	if string(got) != existing {
+		t.Errorf("dry run modified the existing event:\n%s", got)
+	}
+}
+
+func TestEventDiff(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "event.md")
+
+	_, exists, err := eventDiff(osfs.New(""), path, []byte("new\n"))
+	if err != nil || exists {
+		t.Fatalf("eventDiff() on a missing file = exists %v, error %v", exists, err)
+	}
+
+	if err := os.WriteFile(path, []byte("title: Bal\nplace: La Kulture\n"), 0644); err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	diff, exists, err := eventDiff(osfs.New(""), path, []byte("title: Bal\nplace: La Kulture\n"))
+	if err != nil || !exists || diff != "" {
+		t.Errorf("eventDiff() on an unchanged file = %q, exists %v, error %v", diff, exists, err)
+	}
+
+	diff, _, err = eventDiff(osfs.New(""), path, []byte("title: Bal\nplace: Social Bar\n"))
+	if err != nil {
+		t.Fatalf("eventDiff() unexpected error: %v", err)
+	}
+	for _, want := range []string{"-place: La Kulture", "+place: Social Bar", " title: Bal"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("diff doesn't contain %q:\n%s", want, diff)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package publish
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/secrets"
+)
+
+// forgeClient talks to the API of the forge hosting the site repository to
+// open pull requests. GitHub and Gitea share the subset used here.
+type forgeClient struct {
+	Kind       string // "github" or "gitea"
+	BaseURL    string // API root, e.g. https://api.github.com
+	Repo       string // owner/name
+	Token      string
+	HTTPClient *http.Client
+}
+
+// newForgeClient returns a client for the forge kind. baseURL defaults to
+// api.github.com for GitHub, for Gitea it is the instance URL.
+func newForgeClient(kind, baseURL, repo, token string) (*forgeClient, error) {
+	if repo == "" {
+		return nil, errors.New("no forge repository given, expected owner/name")
+	}
+
+	switch kind {
+	case "github":
+		if baseURL == "" {
+			baseURL = "https://api.github.com"
+		}
+	case "gitea":
+		if baseURL == "" {
+			return nil, errors.New("the Gitea instance URL is required")
+		}
+		baseURL = strings.TrimSuffix(baseURL, "/") + "/api/v1"
+	default:
+		return nil, fmt.Errorf("unknown forge %q, expected 'github' or 'gitea'", kind)
+	}
+
+	return &forgeClient{
+		Kind:       kind,
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Repo:       repo,
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// pullRequest is the part of a pull request returned by the forge we use.
+type pullRequest struct {
+	Number int    `json:"number"`
+	URL    string `json:"html_url"`
+	State  string `json:"state"` // "open" or "closed"
+	Merged bool   `json:"merged"`
+}
+
+func (c *forgeClient) do(method, path string, body any, result any) error {
+	var reqBody io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("error marshaling request body: %v", err)
+		}
+		reqBody = bytes.NewReader(jsonData)
+	}
+
+	req, err := http.NewRequest(method, c.BaseURL+"/repos/"+c.Repo+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != "" {
+		if c.Kind == "gitea" {
+			req.Header.Set("Authorization", "token "+c.Token)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+c.Token)
+		}
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling %s API: %v", c.Kind, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s API returned status %d: %s", c.Kind, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("error decoding response body: %v", err)
+	}
+	return nil
+}
+
+// openPullRequest opens a pull request merging head into base.
+func (c *forgeClient) openPullRequest(title, body, head, base string) (pullRequest, error) {
+	var pr pullRequest
+	err := c.do(http.MethodPost, "/pulls", map[string]string{
+		"title": title,
+		"body":  body,
+		"head":  head,
+		"base":  base,
+	}, &pr)
+	return pr, err
+}
+
+// pullRequest returns the current state of the pull request.
+func (c *forgeClient) pullRequest(number int) (pullRequest, error) {
+	var pr pullRequest
+	err := c.do(http.MethodGet, fmt.Sprintf("/pulls/%d", number), nil, &pr)
+	return pr, err
+}
+
+// waitForMerge polls the pull request until it is merged. A pull request
+// closed without being merged is an error.
+func waitForMerge(c *forgeClient, number int, timeout, interval time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for time.Now().Before(deadline) {
+		pr, err := c.pullRequest(number)
+		if err != nil {
+			log.Printf("Failed to get pull request #%d: %v", number, err)
+		} else if pr.Merged {
+			return nil
+		} else if pr.State == "closed" {
+			return fmt.Errorf("pull request #%d was closed without being merged", number)
+		}
+
+		log.Printf("Pull request #%d not merged yet. Retrying in %v...", number, interval)
+		time.Sleep(interval)
+	}
+
+	return fmt.Errorf("timed out waiting for pull request #%d to be merged", number)
+}
+
+// ReviewOptions configures the review mode: instead of committing on the
+// checked out branch, the event is committed on its own branch, pushed, and
+// proposed in a pull request that must be merged before going social.
+type ReviewOptions struct {
+	Forge        *forgeClient
+	Remote       string // Remote to push the branch to
+	BaseBranch   string // Branch the pull request targets
+	MergeTimeout time.Duration
+	PollInterval time.Duration
+}
+
+// reviewBranch returns the branch name for the event file, e.g.
+// event/241224-bal.
+func reviewBranch(outputPath string) string {
+	return "event/" + strings.TrimSuffix(filepath.Base(outputPath), filepath.Ext(outputPath))
+}
+
+// reviewSession is a review in progress: the event branch is checked out
+// until finish is called.
+type reviewSession struct {
+	opts        ReviewOptions
+	runner      gitCommandRunner
+	repoDir     string
+	branch      string
+	startBranch string
+}
+
+// startReview creates the event branch from the base branch of the remote,
+// fetched first, and checks it out, remembering the branch to come back to.
+// The forge token is only needed from here, when a review really starts.
+func startReview(opts ReviewOptions, branch string, runner gitCommandRunner) (*reviewSession, error) {
+	if opts.Forge != nil && opts.Forge.Token == "" {
+		token, err := secrets.Get("FORGE_TOKEN")
+		if err != nil {
+			return nil, err
+		}
+		opts.Forge.Token = token
+	}
+
+	out, err := runGitCommandWrapper(runner, repoDir, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	startBranch := strings.TrimSpace(out)
+	if startBranch == "HEAD" {
+		return nil, errors.New("review mode needs a branch checked out, HEAD is detached")
+	}
+
+	log.Printf("Running 'git fetch %s %s'", opts.Remote, opts.BaseBranch)
+	if _, err := runGitCommandWrapper(runner, repoDir, "fetch", opts.Remote, opts.BaseBranch); err != nil {
+		return nil, err
+	}
+	base := opts.Remote + "/" + opts.BaseBranch
+	log.Printf("Running 'git checkout -b %s %s'", branch, base)
+	if _, err := runGitCommandWrapper(runner, repoDir, "checkout", "-b", branch, base); err != nil {
+		return nil, err
+	}
+
+	return &reviewSession{
+		opts:        opts,
+		runner:      runner,
+		repoDir:     repoDir,
+		branch:      branch,
+		startBranch: startBranch,
+	}, nil
+}
+
+// restore goes back to the starting branch.
+func (s *reviewSession) restore() error {
+	_, err := runGitCommandWrapper(s.runner, s.repoDir, "checkout", s.startBranch)
+	return err
+}
+
+// abandon goes back to the starting branch and deletes the event branch,
+// when there was nothing to review.
+func (s *reviewSession) abandon() error {
+	if err := s.restore(); err != nil {
+		return err
+	}
+	_, err := runGitCommandWrapper(s.runner, s.repoDir, "branch", "-D", s.branch)
+	return err
+}
+
+// finish pushes the event branch, opens the pull request, goes back to the
+// starting branch and waits for the pull request to be merged. If the pull
+// request can't be opened, it goes back to the starting branch and deletes
+// the event branch, pushed or not, so the next run can create it again.
+func (s *reviewSession) finish(title, body string) error {
+	pushed, opened := false, false
+	defer func() {
+		if opened {
+			return
+		}
+		if pushed {
+			if _, err := runGitCommandWrapper(s.runner, s.repoDir, "push", s.opts.Remote, "--delete", s.branch); err != nil {
+				log.Printf("Failed to delete branch %s of %s: %v", s.branch, s.opts.Remote, err)
+			}
+		}
+		if err := s.abandon(); err != nil {
+			log.Printf("Failed to clean up branch %s: %v", s.branch, err)
+		}
+	}()
+
+	log.Printf("Running 'git push -u %s %s'", s.opts.Remote, s.branch)
+	if _, err := runGitCommandWrapper(s.runner, s.repoDir, "push", "-u", s.opts.Remote, s.branch); err != nil {
+		return fmt.Errorf("git push failed: %v", err)
+	}
+	pushed = true
+
+	pr, err := s.opts.Forge.openPullRequest(title, body, s.branch, s.opts.BaseBranch)
+	if err != nil {
+		return fmt.Errorf("failed to open pull request: %v", err)
+	}
+	log.Printf("Opened pull request #%d: %s", pr.Number, pr.URL)
+
+	opened = true
+	if err := s.restore(); err != nil {
+		return err
+	}
+
+	log.Printf("Waiting for pull request #%d to be merged", pr.Number)
+	return waitForMerge(s.opts.Forge, pr.Number, s.opts.MergeTimeout, s.opts.PollInterval)
+}
+
+// addReviewFlags defines the review mode flags on fs. The returned function
+// builds the options once the flags are parsed, nil if review mode is off.
+// The forge token is read when the review starts.
+func addReviewFlags(fs *flag.FlagSet) func() (*ReviewOptions, error) {
+	review := fs.Bool("review", false, "Commit the event on its own branch, push it and open a pull request, then wait for it to be merged before publishing on social media")
+	forge := fs.String("forge", "github", "Forge hosting the repository for review mode: 'github' or 'gitea'")
+	forgeURL := fs.String("forge-url", "", "Forge API URL (defaults to https://api.github.com), the instance URL for Gitea")
+	forgeRepo := fs.String("forge-repo", "dolanor/forrostrasbourg.fr", "Repository on the forge, as owner/name")
+	remote := fs.String("remote", "origin", "Git remote to push the event branch to")
+	baseBranch := fs.String("base-branch", "main", "Branch the pull request targets")
+	mergeTimeout := fs.Duration("merge-timeout", time.Hour, "How long to wait for the pull request to be merged")
+
+	return func() (*ReviewOptions, error) {
+		if !*review {
+			return nil, nil
+		}
+		client, err := newForgeClient(*forge, *forgeURL, *forgeRepo, "")
+		if err != nil {
+			return nil, err
+		}
+		return &ReviewOptions{
+			Forge:        client,
+			Remote:       *remote,
+			BaseBranch:   *baseBranch,
+			MergeTimeout: *mergeTimeout,
+			PollInterval: 30 * time.Second,
+		}, nil
+	}
+}
@@ -0,0 +1,436 @@
+package publish
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// fakeForge is a minimal GitHub/Gitea compatible pull request API.
+type fakeForge struct {
+	t      *testing.T
+	prefix string // "" for GitHub, "/api/v1" for Gitea
+	auth   string // Expected Authorization header
+
+	mu      sync.Mutex
+	opened  []map[string]string
+	polls   int
+	mergeAt int // Number of polls after which the pull request is merged, -1 to close it
+}
+
+func (f *fakeForge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if got := r.Header.Get("Authorization"); got != f.auth {
+		f.t.Errorf("Authorization = %q, want %q", got, f.auth)
+	}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == f.prefix+"/repos/forro/site/pulls":
+		var req map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			f.t.Errorf("Invalid pull request body: %v", err)
+		}
+		f.opened = append(f.opened, req)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(pullRequest{Number: 7, URL: "https://forge.example/forro/site/pull/7", State: "open"})
+	case r.Method == http.MethodGet && r.URL.Path == f.prefix+"/repos/forro/site/pulls/7":
+		f.polls++
+		pr := pullRequest{Number: 7, State: "open"}
+		switch {
+		case f.mergeAt < 0:
+			pr.State = "closed"
+		case f.polls > f.mergeAt:
+			pr.State = "closed"
+			pr.Merged = true
+		}
+		json.NewEncoder(w).Encode(pr)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestNewForgeClient(t *testing.T) {
+	tests := []struct {
+		name        string
+		kind        string
+		baseURL     string
+		repo        string
+		wantBaseURL string
+		expectError bool
+	}{
+		{name: "github default", kind: "github", repo: "forro/site", wantBaseURL: "https://api.github.com"},
+		{name: "github enterprise", kind: "github", baseURL: "https://git.example/api/v3/", repo: "forro/site", wantBaseURL: "https://git.example/api/v3"},
+		{name: "gitea", kind: "gitea", baseURL: "https://gitea.example/", repo: "forro/site", wantBaseURL: "https://gitea.example/api/v1"},
+		{name: "gitea without URL", kind: "gitea", repo: "forro/site", expectError: true},
+		{name: "no repository", kind: "github", expectError: true},
+		{name: "unknown forge", kind: "svn", repo: "forro/site", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := newForgeClient(tt.kind, tt.baseURL, tt.repo, "")
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if c.BaseURL != tt.wantBaseURL {
+				t.Errorf("BaseURL = %q, want %q", c.BaseURL, tt.wantBaseURL)
+			}
+		})
+	}
+}
+
+func TestAddReviewFlags(t *testing.T) {
+	t.Setenv("SECRETS_PROVIDERS", "env")
+	t.Setenv("FORGE_TOKEN", "")
+	os.Unsetenv("FORGE_TOKEN")
+
+	// Without -review, no token is needed
+	fs := newFlagSet("test", "")
+	reviewOptions := addReviewFlags(fs)
+	fs.Parse(nil)
+	if opts, err := reviewOptions(); opts != nil || err != nil {
+		t.Errorf("reviewOptions() = %v, %v, want no review", opts, err)
+	}
+
+	// With it, the token is read when the review starts
+	fs = newFlagSet("test", "")
+	reviewOptions = addReviewFlags(fs)
+	fs.Parse([]string{"-review", "-forge-repo", "forro/site"})
+	opts, err := reviewOptions()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	runner := func(dir string, args ...string) (string, error) {
+		t.Errorf("git %v run without a token", args)
+		return "", nil
+	}
+	if _, err := startReview(*opts, "event/241224-bal", runner); err == nil || !strings.Contains(err.Error(), "FORGE_TOKEN") {
+		t.Errorf("error = %v, want the missing FORGE_TOKEN", err)
+	}
+
+	t.Setenv("FORGE_TOKEN", "secret")
+	b, _, _ := initReviewRepo(t)
+	if _, err := startReview(*opts, "event/241224-bal", b.runCommand); err != nil {
+		t.Fatalf("startReview: %v", err)
+	}
+	if opts.Forge.Token != "secret" {
+		t.Errorf("Token = %q, want the one of the secrets", opts.Forge.Token)
+	}
+}
+
+func TestForgeClientPullRequest(t *testing.T) {
+	tests := []struct {
+		kind   string
+		prefix string
+		auth   string
+	}{
+		{kind: "github", auth: "Bearer secret"},
+		{kind: "gitea", prefix: "/api/v1", auth: "token secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			forge := &fakeForge{t: t, prefix: tt.prefix, auth: tt.auth}
+			server := httptest.NewServer(forge)
+			defer server.Close()
+
+			c, err := newForgeClient(tt.kind, server.URL, "forro/site", "secret")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pr, err := c.openPullRequest("Add event", "Body", "event/241224-bal", "main")
+			if err != nil {
+				t.Fatalf("openPullRequest: %v", err)
+			}
+			if pr.Number != 7 || pr.URL != "https://forge.example/forro/site/pull/7" {
+				t.Errorf("openPullRequest = %+v", pr)
+			}
+			want := map[string]string{"title": "Add event", "body": "Body", "head": "event/241224-bal", "base": "main"}
+			if len(forge.opened) != 1 || !equalStringMaps(forge.opened[0], want) {
+				t.Errorf("opened pull requests = %v, want [%v]", forge.opened, want)
+			}
+
+			if _, err := c.pullRequest(8); err == nil {
+				t.Error("Expected error for an unknown pull request")
+			}
+		})
+	}
+}
+
+func equalStringMaps(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func TestWaitForMerge(t *testing.T) {
+	tests := []struct {
+		name        string
+		mergeAt     int
+		expectError bool
+	}{
+		{name: "merged after a few polls", mergeAt: 2},
+		{name: "closed without merge", mergeAt: -1, expectError: true},
+		{name: "timeout", mergeAt: 1000, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forge := &fakeForge{t: t, mergeAt: tt.mergeAt}
+			server := httptest.NewServer(forge)
+			defer server.Close()
+
+			c, err := newForgeClient("github", server.URL, "forro/site", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = waitForMerge(c, 7, 50*time.Millisecond, time.Millisecond)
+			if tt.expectError && err == nil {
+				t.Error("Expected error but got none")
+			} else if !tt.expectError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestReviewBranch(t *testing.T) {
+	got := reviewBranch(filepath.Join("content", "evenements", "241224-bal.md"))
+	if got != "event/241224-bal" {
+		t.Errorf("reviewBranch = %q, want %q", got, "event/241224-bal")
+	}
+}
+
+// initReviewRepo returns a repository whose origin remote, a bare
+// repository standing for the forge, has the main branch. The local
+// repository doesn't know origin/main until it fetches it.
+func initReviewRepo(t *testing.T) (goGitBackend, *git.Repository, string) {
+	t.Helper()
+	remoteDir := t.TempDir()
+	if _, err := git.PlainInit(remoteDir, true); err != nil {
+		t.Fatal(err)
+	}
+
+	b := initGoGitRepo(t)
+	repo, err := b.open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{remoteDir}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(b.files, "README.md", []byte("Forró Strasbourg\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.Add("README.md"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.Commit("Add README", &git.CommitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Push(&git.PushOptions{RemoteName: "origin", RefSpecs: []config.RefSpec{config.RefSpec(head.Name() + ":refs/heads/main")}}); err != nil {
+		t.Fatal(err)
+	}
+	return b, repo, remoteDir
+}
+
+func TestReviewFinishCleansUp(t *testing.T) {
+	tests := []struct {
+		name     string
+		failPush bool
+	}{
+		{name: "push failing", failPush: true},
+		{name: "pull request failing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, repo, remoteDir := initReviewRepo(t)
+			runner := func(dir string, args ...string) (string, error) {
+				if tt.failPush && args[0] == "push" {
+					return "", errors.New("remote unavailable")
+				}
+				return b.runCommand(dir, args...)
+			}
+
+			// The forge doesn't know the repository
+			forge := &fakeForge{t: t, auth: "Bearer secret"}
+			server := httptest.NewServer(forge)
+			defer server.Close()
+			client, err := newForgeClient("github", server.URL, "forro/other", "secret")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s, err := startReview(ReviewOptions{Forge: client, Remote: "origin", BaseBranch: "main"}, "event/241224-bal", runner)
+			if err != nil {
+				t.Fatalf("startReview: %v", err)
+			}
+			if err := s.finish("Bal", ""); err == nil {
+				t.Fatal("finish succeeded, want it to fail")
+			}
+
+			head, err := repo.Head()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if head.Name().Short() != s.startBranch {
+				t.Errorf("HEAD = %s, want back on %s", head.Name().Short(), s.startBranch)
+			}
+
+			// Nothing left behind for the next run
+			if _, err := repo.Reference(plumbing.NewBranchReferenceName("event/241224-bal"), false); err == nil {
+				t.Error("the event branch is left behind")
+			}
+			remote, err := git.PlainOpen(remoteDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := remote.Reference(plumbing.NewBranchReferenceName("event/241224-bal"), false); err == nil {
+				t.Error("the pushed event branch is left behind")
+			}
+		})
+	}
+}
+
+func TestPublishEventReview(t *testing.T) {
+	b, repo, remoteDir := initReviewRepo(t)
+	remote, err := git.PlainOpen(remoteDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mainRef, err := remote.Reference(plumbing.NewBranchReferenceName("main"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := mainRef.Hash()
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Local work on the starting branch stays out of the pull request
+	if err := util.WriteFile(b.files, "draft.md", []byte("Brouillon\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.Add("draft.md"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.Commit("Add draft", &git.CommitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	startHead, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forge := &fakeForge{t: t, prefix: "/api/v1", auth: "token secret", mergeAt: 1}
+	server := httptest.NewServer(forge)
+	defer server.Close()
+	client, err := newForgeClient("gitea", server.URL, "forro/site", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The template is read from the disk
+	dir := t.TempDir()
+	t.Chdir(dir)
+	templatePath := filepath.Join(dir, "bal.md.template")
+	if err := os.WriteFile(templatePath, []byte("---\ntitle: Bal\nplace: La Kulture\ncity: Strasbourg\n---\nBal le {{ .LongDate }}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = publishEventIn(EventContext{
+		Date:         time.Date(2024, 12, 24, 0, 0, 0, 0, time.UTC),
+		TemplatePath: templatePath,
+		CatalogPath:  filepath.Join(dir, "catalog.yaml"),
+		Language:     "fr",
+		Review: &ReviewOptions{
+			Forge:        client,
+			Remote:       "origin",
+			BaseBranch:   "main",
+			MergeTimeout: time.Second,
+			PollInterval: time.Millisecond,
+		},
+	}, b.repository())
+	if err != nil {
+		t.Fatalf("publishEvent: %v", err)
+	}
+
+	// The pull request was opened for the event branch and waited for
+	if len(forge.opened) != 1 {
+		t.Fatalf("opened %d pull requests, want 1", len(forge.opened))
+	}
+	if got := forge.opened[0]["head"]; got != "event/241224-bal" {
+		t.Errorf("pull request head = %q, want %q", got, "event/241224-bal")
+	}
+	if !strings.Contains(forge.opened[0]["body"], "https://forrostrasbourg.fr/evenements/241224-bal/") {
+		t.Errorf("pull request body = %q, want the event URL", forge.opened[0]["body"])
+	}
+	if forge.polls < 2 {
+		t.Errorf("polled %d times, want to wait for the merge", forge.polls)
+	}
+
+	// The event branch was pushed with the event
+	ref, err := remote.Reference(plumbing.NewBranchReferenceName("event/241224-bal"), true)
+	if err != nil {
+		t.Fatalf("event branch not pushed: %v", err)
+	}
+	commit, err := remote.CommitObject(ref.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := commit.File("content/evenements/241224-bal.md"); err != nil {
+		t.Errorf("event not in the pushed commit: %v", err)
+	}
+	if len(commit.ParentHashes) != 1 || commit.ParentHashes[0] != base {
+		t.Errorf("event commit parents = %v, want origin/main %v", commit.ParentHashes, base)
+	}
+	if _, err := commit.File("draft.md"); err == nil {
+		t.Error("the local work of the starting branch was pushed")
+	}
+
+	// We're back on the starting branch, untouched
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Name() != startHead.Name() || head.Hash() != startHead.Hash() {
+		t.Errorf("HEAD = %v, want %v", head, startHead)
+	}
+}
@@ -0,0 +1,286 @@
+package publish
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultSchedulePath = "scripts/schedule.yaml"
+	// defaultStatePath is where the scheduler remembers the runs, not
+	// committed.
+	defaultStatePath = ".scheduler-state.json"
+)
+
+// scheduledJob is a job of the schedule file, run every day or every week
+// at a time of day.
+type scheduledJob struct {
+	Name string `yaml:"name"`
+	// Every is "day" or a weekday, in English or French.
+	Every string `yaml:"every"`
+	// At is the time of day, as HH:MM in local time.
+	At string `yaml:"at"`
+	// Publish are the arguments of the publish pipeline to run, e.g.
+	// [-template, bal-social-bar].
+	Publish []string `yaml:"publish"`
+	// Command is another program to run, e.g. [go, run, ./cmd/forro, digest, send].
+	Command []string `yaml:"command"`
+}
+
+type schedule struct {
+	Jobs []scheduledJob `yaml:"jobs"`
+}
+
+// loadSchedule reads and validates the schedule file.
+func loadSchedule(path string) (schedule, error) {
+	var s schedule
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return s, fmt.Errorf("failed to read schedule: %v", err)
+	}
+	if err := yaml.Unmarshal(b, &s); err != nil {
+		return s, fmt.Errorf("failed to parse schedule %s: %v", path, err)
+	}
+
+	seen := map[string]bool{}
+	for _, job := range s.Jobs {
+		if job.Name == "" {
+			return s, fmt.Errorf("schedule %s: job without a name", path)
+		}
+		if seen[job.Name] {
+			return s, fmt.Errorf("schedule %s: duplicate job %q", path, job.Name)
+		}
+		seen[job.Name] = true
+
+		if job.Every != "day" {
+			if _, err := parseWeekday(job.Every); err != nil {
+				return s, fmt.Errorf("schedule %s: job %q: every: expected day or a weekday: %v", path, job.Name, err)
+			}
+		}
+		if !timeOfDayRe.MatchString(job.At) {
+			return s, fmt.Errorf("schedule %s: job %q: invalid time %q, expected HH:MM", path, job.Name, job.At)
+		}
+		if (len(job.Publish) == 0) == (len(job.Command) == 0) {
+			return s, fmt.Errorf("schedule %s: job %q: expected either publish or command", path, job.Name)
+		}
+	}
+	return s, nil
+}
+
+// previous returns the last time the job was due, at or before now.
+func (j scheduledJob) previous(now time.Time) time.Time {
+	var hour, minute int
+	fmt.Sscanf(j.At, "%d:%d", &hour, &minute)
+	weekday, err := parseWeekday(j.Every)
+	daily := err != nil
+
+	year, month, day := now.Date()
+	for i := 0; ; i++ {
+		t := time.Date(year, month, day-i, hour, minute, 0, 0, now.Location())
+		if t.After(now) || (!daily && t.Weekday() != weekday) {
+			continue
+		}
+		return t
+	}
+}
+
+func (j scheduledJob) String() string {
+	if len(j.Publish) > 0 {
+		return "publish " + strings.Join(j.Publish, " ")
+	}
+	return strings.Join(j.Command, " ")
+}
+
+// jobState is what the scheduler remembers of a job between runs.
+type jobState struct {
+	// Scheduled is the last due time that was handled: run, or skipped as
+	// too old to catch up.
+	Scheduled time.Time `json:"scheduled"`
+	LastRun   time.Time `json:"last_run,omitzero"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// loadSchedulerState reads the state file. A missing file is an empty state.
+func loadSchedulerState(path string) (map[string]jobState, error) {
+	state := map[string]jobState{}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse scheduler state %s: %v", path, err)
+	}
+	return state, nil
+}
+
+// saveSchedulerState writes the state file, through a temporary file so an
+// interruption doesn't lose it.
+func saveSchedulerState(path string, state map[string]jobState) error {
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// jobRunner runs a job, its output going to w.
+type jobRunner func(ctx context.Context, job scheduledJob, w io.Writer) error
+
+// execJob runs the publish pipeline of a job with this very program, or its
+// command.
+func execJob(ctx context.Context, job scheduledJob, w io.Writer) error {
+	args := job.Command
+	if len(job.Publish) > 0 {
+		self := SelfCommand
+		if len(self) == 0 {
+			exe, err := os.Executable()
+			if err != nil {
+				return err
+			}
+			self = []string{exe}
+		}
+		args = append(slices.Clone(self), job.Publish...)
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = w
+	cmd.Stderr = w
+	return cmd.Run()
+}
+
+// scheduler runs the jobs when they are due, catching up the runs missed
+// while it wasn't running, unless they are older than catchUp.
+type scheduler struct {
+	jobs      []scheduledJob
+	statePath string
+	catchUp   time.Duration
+	dryRun    bool
+	run       jobRunner
+	out       io.Writer
+	logger    *log.Logger
+}
+
+// tick runs the jobs due at now. Jobs not in the state yet are only run from
+// their next due time.
+func (s *scheduler) tick(ctx context.Context, now time.Time) error {
+	state, err := loadSchedulerState(s.statePath)
+	if err != nil {
+		return err
+	}
+
+	for _, job := range s.jobs {
+		due := job.previous(now)
+		st, ok := state[job.Name]
+		switch {
+		case !ok:
+			s.logger.Printf("job %s: new, not catching up the run of %s", job.Name, due.Format("2006-01-02 15:04"))
+		case !due.After(st.Scheduled):
+			continue
+		case now.Sub(due) > s.catchUp:
+			s.logger.Printf("job %s: missed the run of %s, too late to catch up", job.Name, due.Format("2006-01-02 15:04"))
+		case s.dryRun:
+			s.logger.Printf("job %s: would run %s (due %s)", job.Name, job, due.Format("2006-01-02 15:04"))
+			continue
+		default:
+			if now.Sub(due) > time.Minute {
+				s.logger.Printf("job %s: catching up the run of %s", job.Name, due.Format("2006-01-02 15:04"))
+			}
+			s.logger.Printf("job %s: running %s", job.Name, job)
+			start := time.Now()
+			err := s.run(ctx, job, s.out)
+			st.LastRun = now
+			st.LastError = ""
+			if err != nil {
+				st.LastError = err.Error()
+				s.logger.Printf("job %s: failed after %s: %v", job.Name, time.Since(start).Round(time.Second), err)
+			} else {
+				s.logger.Printf("job %s: done in %s", job.Name, time.Since(start).Round(time.Second))
+			}
+		}
+		if s.dryRun {
+			continue
+		}
+
+		// Failed runs aren't retried: a job may have half done its work,
+		// e.g. sent a message to some of the chats.
+		st.Scheduled = due
+		state[job.Name] = st
+		if err := saveSchedulerState(s.statePath, state); err != nil {
+			return fmt.Errorf("failed to save scheduler state: %v", err)
+		}
+	}
+	return nil
+}
+
+// runSchedulerCommand implements the "scheduler" command: it keeps running
+// the jobs of the schedule file when they are due, until interrupted.
+func runSchedulerCommand(args []string, w io.Writer) error {
+	fs := newFlagSet("scheduler", "")
+	schedulePath := fs.String("schedule", defaultSchedulePath, "Path to the schedule file")
+	statePath := fs.String("state", defaultStatePath, "Path to the file remembering the runs")
+	interval := fs.Duration("interval", time.Minute, "How often to check for due jobs")
+	catchUp := fs.Duration("catch-up", 24*time.Hour, "How late a missed run can still be caught up")
+	once := fs.Bool("once", false, "Run the due jobs and exit, e.g. from cron")
+	dryRun := fs.Bool("dry-run", false, "Only log the jobs that would run")
+	fs.Parse(args)
+
+	sched, err := loadSchedule(*schedulePath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(*statePath), 0o755); err != nil {
+		return err
+	}
+
+	s := &scheduler{
+		jobs:      sched.Jobs,
+		statePath: *statePath,
+		catchUp:   *catchUp,
+		dryRun:    *dryRun,
+		run:       execJob,
+		out:       w,
+		logger:    log.New(w, "", log.LstdFlags),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s.logger.Printf("scheduler: %d jobs from %s", len(sched.Jobs), *schedulePath)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		if err := s.tick(ctx, time.Now()); err != nil {
+			return err
+		}
+		if *once {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			s.logger.Printf("scheduler: stopped")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadSchedule(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		errContains string
+	}{
+		{
+			name:    "valid",
+			content: "jobs:\n  - name: digest\n    every: monday\n    at: \"10:00\"\n    command: [true]\n  - name: bal\n    every: dimanche\n    at: \"09:30\"\n    publish: [-template, bal]\n  - name: daily\n    every: day\n    at: \"00:00\"\n    command: [true]\n",
+		},
+		{name: "no name", content: "jobs:\n  - every: day\n    at: \"10:00\"\n    command: [true]\n", errContains: "job without a name"},
+		{name: "duplicate", content: "jobs:\n  - {name: a, every: day, at: \"10:00\", command: [true]}\n  - {name: a, every: day, at: \"11:00\", command: [true]}\n", errContains: `duplicate job "a"`},
+		{name: "unknown weekday", content: "jobs:\n  - {name: a, every: someday, at: \"10:00\", command: [true]}\n", errContains: "expected day or a weekday"},
+		{name: "invalid time", content: "jobs:\n  - {name: a, every: day, at: \"10h\", command: [true]}\n", errContains: `invalid time "10h"`},
+		{name: "nothing to run", content: "jobs:\n  - {name: a, every: day, at: \"10:00\"}\n", errContains: "expected either publish or command"},
+		{name: "both", content: "jobs:\n  - {name: a, every: day, at: \"10:00\", command: [true], publish: [-template, bal]}\n", errContains: "expected either publish or command"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "schedule.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			s, err := loadSchedule(path)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("expected error containing %q, got %v", tt.errContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(s.Jobs) != 3 {
+				t.Errorf("got %d jobs, want 3", len(s.Jobs))
+			}
+		})
+	}
+}
+
+func TestRepositorySchedule(t *testing.T) {
+	if _, err := loadSchedule(filepath.Join("..", "..", "scripts", "schedule.yaml")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestScheduledJobPrevious(t *testing.T) {
+	// 2024-12-25 is a wednesday
+	now := time.Date(2024, 12, 25, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		job  scheduledJob
+		want time.Time
+	}{
+		{scheduledJob{Every: "day", At: "10:00"}, time.Date(2024, 12, 25, 10, 0, 0, 0, time.UTC)},
+		{scheduledJob{Every: "day", At: "12:00"}, time.Date(2024, 12, 25, 12, 0, 0, 0, time.UTC)},
+		{scheduledJob{Every: "day", At: "17:00"}, time.Date(2024, 12, 24, 17, 0, 0, 0, time.UTC)},
+		{scheduledJob{Every: "wednesday", At: "17:00"}, time.Date(2024, 12, 18, 17, 0, 0, 0, time.UTC)},
+		{scheduledJob{Every: "monday", At: "10:00"}, time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)},
+		{scheduledJob{Every: "dimanche", At: "23:59"}, time.Date(2024, 12, 22, 23, 59, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		if got := tt.job.previous(now); !got.Equal(tt.want) {
+			t.Errorf("%s %s: previous = %v, want %v", tt.job.Every, tt.job.At, got, tt.want)
+		}
+	}
+}
+
+func TestSchedulerTick(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	var ran []string
+	var logs bytes.Buffer
+	s := &scheduler{
+		jobs: []scheduledJob{
+			{Name: "digest", Every: "monday", At: "10:00", Command: []string{"send"}},
+			{Name: "bal", Every: "sunday", At: "10:00", Publish: []string{"-template", "bal"}},
+		},
+		statePath: statePath,
+		catchUp:   24 * time.Hour,
+		run: func(ctx context.Context, job scheduledJob, w io.Writer) error {
+			ran = append(ran, job.Name)
+			if job.Name == "bal" {
+				return errors.New("template not found")
+			}
+			return nil
+		},
+		out:    io.Discard,
+		logger: log.New(&logs, "", 0),
+	}
+	tick := func(now time.Time) {
+		t.Helper()
+		ran = nil
+		if err := s.tick(context.Background(), now); err != nil {
+			t.Fatalf("tick: %v", err)
+		}
+	}
+
+	// New jobs don't run for a due time before the scheduler knew them
+	tick(time.Date(2024, 12, 23, 12, 0, 0, 0, time.UTC))
+	if len(ran) != 0 {
+		t.Errorf("ran %v on first start", ran)
+	}
+
+	// Nothing due
+	tick(time.Date(2024, 12, 23, 13, 0, 0, 0, time.UTC))
+	if len(ran) != 0 {
+		t.Errorf("ran %v while nothing is due", ran)
+	}
+
+	// The bal is due on sunday and fails
+	tick(time.Date(2024, 12, 29, 10, 0, 30, 0, time.UTC))
+	if strings.Join(ran, ",") != "bal" {
+		t.Errorf("ran %v, want bal", ran)
+	}
+	// It isn't retried
+	tick(time.Date(2024, 12, 29, 10, 1, 30, 0, time.UTC))
+	if len(ran) != 0 {
+		t.Errorf("ran %v again", ran)
+	}
+
+	// Down on monday morning, the digest is caught up on monday evening
+	tick(time.Date(2024, 12, 30, 19, 0, 0, 0, time.UTC))
+	if strings.Join(ran, ",") != "digest" {
+		t.Errorf("ran %v, want digest", ran)
+	}
+
+	// Down for a week, the missed runs are too old to catch up
+	tick(time.Date(2025, 1, 7, 12, 0, 0, 0, time.UTC))
+	if len(ran) != 0 {
+		t.Errorf("caught up %v after a week", ran)
+	}
+
+	state, err := loadSchedulerState(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := state["bal"]; st.LastError != "template not found" || !st.LastRun.Equal(time.Date(2024, 12, 29, 10, 0, 30, 0, time.UTC)) {
+		t.Errorf("bal state = %+v", st)
+	}
+	if st := state["digest"]; !st.Scheduled.Equal(time.Date(2025, 1, 6, 10, 0, 0, 0, time.UTC)) || st.LastError != "" {
+		t.Errorf("digest state = %+v", st)
+	}
+
+	for _, want := range []string{
+		"job bal: failed after 0s: template not found",
+		"job digest: catching up the run of 2024-12-30 10:00",
+		"job digest: done in 0s",
+		"job digest: missed the run of 2025-01-06 10:00, too late to catch up",
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs don't contain %q:\n%s", want, logs.String())
+		}
+	}
+}
+
+func TestRunSchedulerCommand(t *testing.T) {
+	dir := t.TempDir()
+	schedulePath := filepath.Join(dir, "schedule.yaml")
+	statePath := filepath.Join(dir, "state.json")
+	marker := filepath.Join(dir, "ran")
+
+	schedule := "jobs:\n  - name: touch\n    every: day\n    at: \"00:00\"\n    command: [touch, " + marker + "]\n"
+	if err := os.WriteFile(schedulePath, []byte(schedule), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Last run the day before
+	yesterday := time.Now().AddDate(0, 0, -1)
+	if err := saveSchedulerState(statePath, map[string]jobState{"touch": {Scheduled: scheduledJob{Every: "day", At: "00:00"}.previous(yesterday)}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	args := []string{"-schedule", schedulePath, "-state", statePath, "-once"}
+	if err := runSchedulerCommand(append(args, "-dry-run"), &out); err != nil {
+		t.Fatalf("runSchedulerCommand: %v", err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("job run in dry-run mode")
+	}
+	if !strings.Contains(out.String(), "job touch: would run touch "+marker) {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := runSchedulerCommand(args, &out); err != nil {
+		t.Fatalf("runSchedulerCommand: %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("job not run: %v\n%s", err, out.String())
+	}
+}
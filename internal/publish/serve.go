@@ -0,0 +1,494 @@
+package publish
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"go.abhg.dev/goldmark/frontmatter"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/send"
+)
+
+const adminPagesTempl = `
+{{ define "header" -}}
+<!DOCTYPE html>
+<html lang="fr">
+<head>
+<meta charset="utf-8">
+<title>Forró Strasbourg - publier un événement</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: 2em auto; padding: 0 1em; }
+label { display: block; margin-top: 1em; font-weight: bold; }
+pre { background: #f4f4f4; padding: 1em; white-space: pre-wrap; }
+.page { border: 1px solid #ccc; padding: 1em; }
+.error { color: #b00; }
+</style>
+</head>
+<body>
+<h1><a href="/">Publier un événement</a></h1>
+{{- end }}
+
+{{ define "footer" }}
+</body>
+</html>
+{{- end }}
+
+{{ define "form" -}}
+{{ template "header" }}
+{{ with .Error }}<p class="error">{{ . }}</p>{{ end }}
+<form method="post" action="/preview">
+	<label for="template">Modèle</label>
+	<select id="template" name="template">
+	{{ range .Templates }}
+		<option value="{{ .Name }}"{{ if eq .Name $.Form.Template }} selected{{ end }}>{{ .Name }} - {{ .Description }}</option>
+	{{ end }}
+	</select>
+
+	<label for="date">Date (laisser vide pour le prochain jour habituel)</label>
+	<input type="date" id="date" name="date" value="{{ .Form.Date }}">
+
+	<label for="time">Heure de début (laisser vide pour l'heure habituelle)</label>
+	<input type="time" id="time" name="time" value="{{ .Form.Time }}">
+
+	<label for="vars">Variables (une par ligne, nom=valeur)</label>
+	<textarea id="vars" name="vars" rows="4" cols="60">{{ .Form.Vars }}</textarea>
+
+	<p><button type="submit">Prévisualiser</button></p>
+</form>
+{{ template "footer" }}
+{{- end }}
+
+{{ define "preview" -}}
+{{ template "header" }}
+<h2>{{ .OutputPath }}</h2>
+<div class="page">{{ .PageHTML }}</div>
+
+<h2>Markdown</h2>
+<pre>{{ .Markdown }}</pre>
+
+<h2>Publication Facebook</h2>
+<pre>{{ .FacebookMessage }}</pre>
+
+<h2>Message dans les groupes</h2>
+<pre>{{ .ChatMessage }}</pre>
+
+<form method="post" action="/publish">
+	<input type="hidden" name="template" value="{{ .Form.Template }}">
+	<input type="hidden" name="date" value="{{ .Form.Date }}">
+	<input type="hidden" name="time" value="{{ .Form.Time }}">
+	<textarea name="vars" hidden>{{ .Form.Vars }}</textarea>
+
+	{{ with .FacebookPages }}
+	<label><input type="checkbox" name="facebook" value="1"> Publier sur Facebook ({{ . }})</label>
+	{{ end }}
+	{{ range .Chats }}
+	<label><input type="checkbox" name="chats" value="{{ . }}"> Annoncer dans le groupe {{ . }}</label>
+	{{ end }}
+
+	<p>
+		<button type="submit">Publier</button>
+		<button type="submit" formaction="/" formmethod="post">Modifier</button>
+	</p>
+</form>
+{{ template "footer" }}
+{{- end }}
+
+{{ define "result" -}}
+{{ template "header" }}
+{{ if .Errors }}
+<p class="error">L'événement n'a pas été entièrement publié :</p>
+<ul class="error">{{ range .Errors }}<li>{{ . }}</li>{{ end }}</ul>
+{{ else }}
+<p>L'événement est publié{{ if .DryRun }} (simulation){{ end }} : <a href="{{ .EventURL }}">{{ .EventURL }}</a></p>
+{{ end }}
+<p><a href="/">Publier un autre événement</a></p>
+{{ template "footer" }}
+{{- end }}
+`
+
+var adminPages = template.Must(template.New("admin").Parse(adminPagesTempl))
+
+// adminForm holds the values of the event form, carried from one page to the
+// next.
+type adminForm struct {
+	Template string
+	Date     string
+	Time     string
+	Vars     string
+}
+
+// adminServer is the local web admin to draft and publish events.
+type adminServer struct {
+	catalogPath string
+	lang        string
+	dryRun      bool
+	now         func() time.Time
+	publish     func(EventContext) error
+	// chats announce a message in the channels of send.yaml, by name.
+	chats map[string]func(message string) error
+}
+
+// adminEvent is an event rendered from the form, ready to be previewed or
+// published.
+type adminEvent struct {
+	ctx             EventContext
+	tmplInfo        TemplateInfo
+	outputPath      string
+	eventURL        string
+	markdown        []byte
+	facebookMessage string
+	chatMessage     string
+}
+
+func (s *adminServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleForm)
+	mux.HandleFunc("POST /preview", s.handlePreview)
+	mux.HandleFunc("POST /publish", s.handlePublish)
+	return sameOriginOnly(mux)
+}
+
+// sameOriginOnly rejects cross-site form posts, so a random website can't use
+// the browser of an organizer to publish through the admin. It also rejects
+// the requests for another host than the loopback one, so a website whose
+// name is rebound to 127.0.0.1 can't read the admin nor post to it.
+func sameOriginOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isLoopbackHost(r.Host) {
+			http.Error(w, "unknown host refused", http.StatusForbidden)
+			return
+		}
+		if r.Method == http.MethodPost {
+			if origin := r.Header.Get("Origin"); origin != "" {
+				u, err := url.Parse(origin)
+				if err != nil || u.Host != r.Host {
+					http.Error(w, "cross-origin request refused", http.StatusForbidden)
+					return
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func formFromRequest(r *http.Request) adminForm {
+	return adminForm{
+		Template: r.FormValue("template"),
+		Date:     strings.TrimSpace(r.FormValue("date")),
+		Time:     strings.TrimSpace(r.FormValue("time")),
+		Vars:     r.FormValue("vars"),
+	}
+}
+
+func (s *adminServer) handleForm(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	s.renderForm(w, formFromRequest(r), nil)
+}
+
+func (s *adminServer) renderForm(w http.ResponseWriter, form adminForm, formErr error) {
+	catalog, err := loadTemplateCatalog(s.catalogPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Templates []TemplateInfo
+		Form      adminForm
+		Error     error
+	}{catalog.Templates, form, formErr}
+
+	status := http.StatusOK
+	if formErr != nil {
+		status = http.StatusBadRequest
+	}
+	s.renderPage(w, status, "form", data)
+}
+
+func (s *adminServer) renderPage(w http.ResponseWriter, status int, name string, data any) {
+	var buf bytes.Buffer
+	if err := adminPages.ExecuteTemplate(&buf, name, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
+
+// prepare renders the event described by the form.
+func (s *adminServer) prepare(form adminForm) (adminEvent, error) {
+	var ev adminEvent
+
+	catalog, err := loadTemplateCatalog(s.catalogPath)
+	if err != nil {
+		return ev, err
+	}
+	tmplInfo, ok := catalog.lookup(form.Template)
+	if !ok {
+		return ev, fmt.Errorf("unknown template %q", form.Template)
+	}
+
+	vars := templateVars{}
+	for _, line := range strings.Split(form.Vars, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if err := vars.Set(line); err != nil {
+			return ev, err
+		}
+	}
+
+	var date time.Time
+	switch {
+	case form.Date != "":
+		date, err = time.Parse("2006-01-02", form.Date)
+		if err != nil {
+			return ev, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", form.Date)
+		}
+	case tmplInfo.Weekday != "":
+		weekday, err := parseWeekday(tmplInfo.Weekday)
+		if err != nil {
+			return ev, err
+		}
+		date = nextWeekday(s.now(), weekday)
+	default:
+		return ev, fmt.Errorf("template %q has no usual weekday, a date is needed", tmplInfo.Name)
+	}
+
+	if form.Time != "" {
+		if !timeOfDayRe.MatchString(form.Time) {
+			return ev, fmt.Errorf("invalid time %q, expected HH:MM", form.Time)
+		}
+		tmplInfo.Time = form.Time
+	}
+
+	dateStr := date.Format("2006-01-02")
+	data := newEventData(date, dateStr, s.lang, tmplInfo.Time, vars)
+	rendered, err := renderTemplate(tmplInfo, data)
+	if err != nil {
+		return ev, err
+	}
+	fmData, err := parseFrontMatter(rendered)
+	if err != nil {
+		return ev, fmt.Errorf("invalid rendered event: %v", err)
+	}
+
+	ev.tmplInfo = tmplInfo
+	ev.markdown = rendered
+	ev.outputPath, ev.eventURL = eventPaths(tmplInfo, date)
+	ev.facebookMessage = facebookMessage(data, fmData, ev.eventURL)
+	ev.chatMessage = chatAnnouncement(data, fmData, ev.eventURL)
+	ev.ctx = EventContext{
+		Date:          date,
+		TemplatePath:  tmplInfo.Name,
+		CatalogPath:   s.catalogPath,
+		Time:          form.Time,
+		Language:      s.lang,
+		DryRun:        s.dryRun,
+		FacebookPages: strings.Join(tmplInfo.FacebookPages, ","),
+		Vars:          vars,
+	}
+	return ev, nil
+}
+
+func (s *adminServer) handlePreview(w http.ResponseWriter, r *http.Request) {
+	form := formFromRequest(r)
+	ev, err := s.prepare(form)
+	if err != nil {
+		s.renderForm(w, form, err)
+		return
+	}
+
+	md := goldmark.New(goldmark.WithExtensions(&frontmatter.Extender{}))
+	var page bytes.Buffer
+	if err := md.Convert(ev.markdown, &page); err != nil {
+		s.renderForm(w, form, fmt.Errorf("failed to render the event page: %v", err))
+		return
+	}
+
+	data := struct {
+		Form            adminForm
+		OutputPath      string
+		PageHTML        template.HTML
+		Markdown        string
+		FacebookMessage string
+		ChatMessage     string
+		FacebookPages   string
+		Chats           []string
+	}{
+		Form:            form,
+		OutputPath:      ev.outputPath,
+		PageHTML:        template.HTML(page.String()), // rendered from our own templates
+		Markdown:        string(ev.markdown),
+		FacebookMessage: ev.facebookMessage,
+		ChatMessage:     ev.chatMessage,
+		FacebookPages:   ev.ctx.FacebookPages,
+		Chats:           announcingChats(s.chats, ev.tmplInfo),
+	}
+	s.renderPage(w, http.StatusOK, "preview", data)
+}
+
+// announcingChats returns the chats of the template that are channels of
+// send.yaml getting the announcements.
+func announcingChats(chats map[string]func(message string) error, tmplInfo TemplateInfo) []string {
+	var names []string
+	for _, name := range tmplInfo.Chats {
+		if chats[name] != nil {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// announceEvent sends the announcement of the event to the named chats, or
+// logs it on a dry run, and returns the errors of the chats it failed for.
+func announceEvent(chats map[string]func(message string) error, names []string, message string, dryRun bool) []string {
+	var errs []string
+	for _, name := range names {
+		announce := chats[name]
+		if announce == nil {
+			errs = append(errs, fmt.Sprintf("unknown chat %q", name))
+			continue
+		}
+
+		log.Printf("Announcing event in chat: %s", name)
+		if dryRun {
+			log.Printf("[Dry Run] Would send the following message to %s:\n%s", name, message)
+			continue
+		}
+		if err := announce(message); err != nil {
+			errs = append(errs, fmt.Sprintf("failed to announce the event in chat %q: %v", name, err))
+		}
+	}
+	return errs
+}
+
+func (s *adminServer) handlePublish(w http.ResponseWriter, r *http.Request) {
+	form := formFromRequest(r)
+	ev, err := s.prepare(form)
+	if err != nil {
+		s.renderForm(w, form, err)
+		return
+	}
+
+	ev.ctx.PublishFacebook = r.FormValue("facebook") != ""
+	var publishErrors []string
+	if ev.ctx.PublishFacebook {
+		ev.ctx.PageAccessToken, err = facebookPageAccessToken()
+	}
+	if err != nil {
+		publishErrors = append(publishErrors, err.Error())
+	} else if err := s.publish(ev.ctx); err != nil {
+		publishErrors = append(publishErrors, err.Error())
+	} else {
+		publishErrors = append(publishErrors, announceEvent(s.chats, r.Form["chats"], ev.chatMessage, s.dryRun)...)
+	}
+
+	data := struct {
+		EventURL string
+		DryRun   bool
+		Errors   []string
+	}{ev.eventURL, s.dryRun, publishErrors}
+
+	status := http.StatusOK
+	if len(publishErrors) > 0 {
+		status = http.StatusInternalServerError
+	}
+	s.renderPage(w, status, "result", data)
+}
+
+// chatAnnouncement creates the message announcing a new event in the chats.
+func chatAnnouncement(data EventData, fmData FrontMatterData, eventURL string) string {
+	when := data.LongDateCapitalized
+	if data.Time != "" {
+		when += " à " + strings.Replace(data.Time, ":", "h", 1)
+	}
+
+	return fmt.Sprintf(
+		`Nouvel événement : %s, %s
+%s
+
+%s`,
+		when,
+		fmData.Title,
+		fmData.location(),
+		eventURL,
+	)
+}
+
+// isLoopback reports whether addr only listens on the local machine.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	return isLoopbackName(host)
+}
+
+// isLoopbackHost reports whether the Host header of a request names the
+// local machine, with or without a port.
+func isLoopbackHost(host string) bool {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	return isLoopbackName(strings.Trim(host, "[]"))
+}
+
+// isLoopbackName reports whether host is localhost or a loopback IP.
+func isLoopbackName(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// runServeCommand implements the "serve" command.
+func runServeCommand(args []string) error {
+	fs := newFlagSet("serve", "")
+	addr := fs.String("addr", "localhost:8080", "Address to listen on, must be a loopback address")
+	catalogPath := fs.String("catalog", defaultCatalogPath, "Path to the template catalog")
+	lang := fs.String("lang", "fr", "Language code for date formatting (e.g. 'fr' or 'en')")
+	dryRun := fs.Bool("dry-run", false, "If true, only echo the actions without carrying them out")
+	configPath := fs.String("config", "send.yaml", "Channels configuration of the chats announcing the events, the env is used if it doesn't exist")
+	fs.Parse(args)
+
+	if !isLoopback(*addr) {
+		return errors.New("the admin has no authentication, it must listen on a loopback address (e.g. localhost:8080)")
+	}
+
+	if _, err := loadTemplateCatalog(*catalogPath); err != nil {
+		return err
+	}
+
+	// A missing token fails when announcing, not when starting the admin
+	chats, err := send.Announcers(*configPath)
+	if err != nil {
+		return err
+	}
+	s := &adminServer{
+		catalogPath: *catalogPath,
+		lang:        *lang,
+		dryRun:      *dryRun,
+		now:         time.Now,
+		publish:     publishEvent,
+		chats:       chats,
+	}
+
+	log.Printf("Admin available at http://%s/", *addr)
+	return http.ListenAndServe(*addr, s.routes())
+}
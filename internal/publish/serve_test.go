@@ -0,0 +1,245 @@
+package publish
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestAdminServer(t *testing.T) (*adminServer, *[]EventContext, *[]string) {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	templateContent := `---
+title: Bal avec {{ .Vars.band }}
+startDate: "{{.Date}}T{{.Time}}:00+02:00"
+place: La Kulture
+city: Strasbourg
+---
+Bal le {{ .LongDate }}`
+	if err := os.WriteFile(filepath.Join(tmpDir, "bal.md.template"), []byte(templateContent), 0644); err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+	catalogPath := filepath.Join(tmpDir, "catalog.yaml")
+	catalog := `templates:
+  - name: bal
+    file: bal.md.template
+    weekday: tuesday
+    time: "18:30"
+    facebook_pages: [forro-stras]
+    chats: [forrostrasbourg, special]
+`
+	if err := os.WriteFile(catalogPath, []byte(catalog), 0644); err != nil {
+		t.Fatalf("Failed to create catalog: %v", err)
+	}
+
+	var published []EventContext
+	var announced []string
+	s := &adminServer{
+		catalogPath: catalogPath,
+		lang:        "fr",
+		now:         func() time.Time { return time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC) },
+		publish: func(ctx EventContext) error {
+			published = append(published, ctx)
+			return nil
+		},
+		chats: map[string]func(string) error{
+			"forrostrasbourg": func(message string) error {
+				announced = append(announced, "forrostrasbourg: "+message)
+				return nil
+			},
+		},
+	}
+	return s, &published, &announced
+}
+
+func postForm(t *testing.T, h http.Handler, path string, form url.Values, origin string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Host = "localhost:8080"
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminServerForm(t *testing.T) {
+	s, _, _ := newTestAdminServer(t)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "localhost:8080"
+	s.routes().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if !strings.Contains(rec.Body.String(), `<option value="bal"`) {
+		t.Errorf("form doesn't list the templates:\n%s", rec.Body.String())
+	}
+}
+
+func TestAdminServerPreview(t *testing.T) {
+	s, published, _ := newTestAdminServer(t)
+
+	tests := []struct {
+		name       string
+		form       url.Values
+		wantStatus int
+		wantBody   []string
+	}{
+		{
+			name:       "valid event",
+			form:       url.Values{"template": {"bal"}, "vars": {"band=Trio Nordestino"}},
+			wantStatus: http.StatusOK,
+			wantBody: []string{
+				"content/evenements/241224-bal.md",
+				`startDate: &#34;2024-12-24T18:30:00&#43;02:00&#34;`,
+				"<p>Bal le mardi 24 décembre</p>",
+				"Mardi 24 décembre: Bal avec Trio Nordestino",
+				"Nouvel événement : Mardi 24 décembre à 18h30, Bal avec Trio Nordestino",
+				`value="forrostrasbourg"`,
+			},
+		},
+		{
+			name:       "missing variable",
+			form:       url.Values{"template": {"bal"}, "date": {"2024-12-31"}},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{"map has no entry for key"},
+		},
+		{
+			name:       "invalid time",
+			form:       url.Values{"template": {"bal"}, "time": {"7pm"}, "vars": {"band=Trio"}},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{"invalid time"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := postForm(t, s.routes(), "/preview", tt.form, "")
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d\n%s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(rec.Body.String(), want) {
+					t.Errorf("body doesn't contain %q:\n%s", want, rec.Body.String())
+				}
+			}
+		})
+	}
+
+	if len(*published) != 0 {
+		t.Errorf("preview published %d events", len(*published))
+	}
+}
+
+func TestAdminServerPublish(t *testing.T) {
+	s, published, announced := newTestAdminServer(t)
+
+	form := url.Values{
+		"template": {"bal"},
+		"date":     {"2024-12-31"},
+		"time":     {"19:00"},
+		"vars":     {"band=Trio"},
+		"facebook": {"1"},
+		"chats":    {"forrostrasbourg"},
+	}
+	rec := postForm(t, s.routes(), "/publish", form, "http://localhost:8080")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d\n%s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	if len(*published) != 1 {
+		t.Fatalf("published %d events, want 1", len(*published))
+	}
+	ctx := (*published)[0]
+	if !ctx.Date.Equal(time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)) || ctx.TemplatePath != "bal" || ctx.Time != "19:00" || !ctx.PublishFacebook || ctx.FacebookPages != "forro-stras" {
+		t.Errorf("unexpected context: %+v", ctx)
+	}
+	if ctx.Vars["band"] != "Trio" {
+		t.Errorf("Vars = %v, want band=Trio", ctx.Vars)
+	}
+
+	if len(*announced) != 1 || !strings.HasPrefix((*announced)[0], "forrostrasbourg: Nouvel événement : Mardi 31 décembre à 19h00") {
+		t.Errorf("announced = %q", *announced)
+	}
+}
+
+func TestAdminServerRefusesCrossOrigin(t *testing.T) {
+	s, published, _ := newTestAdminServer(t)
+
+	form := url.Values{"template": {"bal"}, "vars": {"band=Trio"}}
+	rec := postForm(t, s.routes(), "/publish", form, "https://evil.example.org")
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if len(*published) != 0 {
+		t.Errorf("published %d events, want none", len(*published))
+	}
+}
+
+func TestAdminServerRefusesOtherHosts(t *testing.T) {
+	s, published, _ := newTestAdminServer(t)
+
+	// A website rebound to 127.0.0.1 still sends its own name
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://evil.example.org:8080/", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("GET status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	form := url.Values{"template": {"bal"}, "vars": {"band=Trio"}}
+	req := httptest.NewRequest(http.MethodPost, "http://evil.example.org:8080/publish", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Origin", "http://evil.example.org:8080")
+	rec = httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("POST status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if len(*published) != 0 {
+		t.Errorf("published %d events, want none", len(*published))
+	}
+}
+
+func TestIsLoopbackHost(t *testing.T) {
+	tests := map[string]bool{
+		"localhost:8080":      true,
+		"localhost":           true,
+		"127.0.0.1:8080":      true,
+		"[::1]:8080":          true,
+		"evil.example.org":    false,
+		"evil.example.org:80": false,
+		"192.168.1.2:8080":    false,
+	}
+	for host, want := range tests {
+		if got := isLoopbackHost(host); got != want {
+			t.Errorf("isLoopbackHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	tests := map[string]bool{
+		"localhost:8080": true,
+		"127.0.0.1:8080": true,
+		"[::1]:8080":     true,
+		":8080":          false,
+		"0.0.0.0:8080":   false,
+		"192.168.1.2:80": false,
+		"localhost":      false,
+	}
+	for addr, want := range tests {
+		if got := isLoopback(addr); got != want {
+			t.Errorf("isLoopback(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
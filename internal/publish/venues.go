@@ -0,0 +1,191 @@
+package publish
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/event"
+)
+
+// loadVenues reads the venues registry of the site.
+func loadVenues() (event.Venues, error) {
+	venues, err := event.LoadVenues(event.VenuesFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load venues: %v", err)
+	}
+	return venues, nil
+}
+
+// loadEvents reads the event pages of dir, logging the pages that can't be
+// read, or failing on them if strict.
+func loadEvents(dir string, venues event.Venues, strict bool) ([]event.Event, error) {
+	events, skipped, err := event.LoadDir(dir, venues)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load events: %w", err)
+	}
+	for _, s := range skipped {
+		log.Printf("SKIPPED: %s: %v", s.Path, s.Err)
+	}
+	if strict && len(skipped) > 0 {
+		return nil, fmt.Errorf("%d event pages skipped", len(skipped))
+	}
+	return events, nil
+}
+
+// lintIssue is a problem found by the lint command. Warnings don't make it
+// fail.
+type lintIssue struct {
+	path    string
+	message string
+	warning bool
+}
+
+func (i lintIssue) String() string {
+	level := "error"
+	if i.warning {
+		level = "warning"
+	}
+	return fmt.Sprintf("%s: %s: %s", i.path, level, i.message)
+}
+
+// lintVenues checks the venues registry: every venue needs a city to be
+// announced, and coordinates to be shown on a map.
+func lintVenues(venues event.Venues) []lintIssue {
+	var issues []lintIssue
+	for _, id := range venues.IDs() {
+		v := venues[id]
+		path := event.VenuesFile + ": " + id
+		if v.Name == "" && v.Address == "" {
+			issues = append(issues, lintIssue{path: path, message: "no name nor address"})
+		}
+		if v.City == "" {
+			issues = append(issues, lintIssue{path: path, message: "no city"})
+		}
+		switch {
+		case !v.HasCoordinates():
+			issues = append(issues, lintIssue{path: path, message: "no latitude/longitude, it can't be shown on a map", warning: true})
+		case v.Latitude < -90 || v.Latitude > 90 || v.Longitude < -180 || v.Longitude > 180:
+			issues = append(issues, lintIssue{path: path, message: fmt.Sprintf("invalid coordinates %v, %v", v.Latitude, v.Longitude)})
+		}
+	}
+	return issues
+}
+
+// lintPage checks the venue of an event page or template. Templates still
+// using a free-text place of the registry are told which venue to use.
+func lintPage(path string, content []byte, venues event.Venues, template bool) []lintIssue {
+	if template {
+		var err error
+		content, err = renderSample(content)
+		if err != nil {
+			return []lintIssue{{path: path, message: err.Error()}}
+		}
+	}
+	e, err := event.Parse(content)
+	if err != nil {
+		return []lintIssue{{path: path, message: err.Error()}}
+	}
+	if !event.ValidCategory(e.Category) {
+		return []lintIssue{{path: path, message: fmt.Sprintf("unknown category %q, expected one of %s", e.Category, strings.Join(event.Categories, ", "))}}
+	}
+
+	if e.Venue != "" {
+		if err := venues.Resolve(&e); err != nil {
+			return []lintIssue{{path: path, message: err.Error()}}
+		}
+		return nil
+	}
+
+	if !template || e.Place == "" {
+		return nil
+	}
+	for _, id := range venues.IDs() {
+		if sameText(venues[id].Place(), e.Place) {
+			return []lintIssue{{path: path, message: fmt.Sprintf("place %q is venue %q, use 'venue: %s'", e.Place, id, id), warning: true}}
+		}
+	}
+	return []lintIssue{{path: path, message: fmt.Sprintf("free-text place %q, add it to %s and use 'venue:'", e.Place, event.VenuesFile), warning: true}}
+}
+
+// renderSample renders a template for a sample date, so its front matter
+// reads like the one of an event page.
+func renderSample(content []byte) ([]byte, error) {
+	tmpl, err := template.New("").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template: %v", err)
+	}
+	sample := newEventData(time.Date(2006, time.January, 2, 0, 0, 0, 0, time.UTC), "2006-01-02", "fr", "20:00", nil)
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, sample); err != nil {
+		return nil, fmt.Errorf("error executing template: %v", err)
+	}
+	return rendered.Bytes(), nil
+}
+
+// sameText compares texts written by hand, ignoring case and spacing.
+func sameText(a, b string) bool {
+	return strings.EqualFold(strings.Join(strings.Fields(a), " "), strings.Join(strings.Fields(b), " "))
+}
+
+// runLintCommand implements the "lint" command: it checks the venues
+// registry, and the venues and categories of the event pages and templates.
+func runLintCommand(args []string, w io.Writer) error {
+	fs := newFlagSet("lint", "")
+	dir := fs.String("dir", eventsDir, "Directory of the event pages and templates")
+	fs.Parse(args)
+
+	venues, err := loadVenues()
+	if err != nil {
+		return err
+	}
+
+	issues := lintVenues(venues)
+	pageIssues, err := lintPages(*dir, venues)
+	if err != nil {
+		return err
+	}
+	issues = append(issues, pageIssues...)
+
+	errs := 0
+	for _, issue := range issues {
+		fmt.Fprintln(w, issue)
+		if !issue.warning {
+			errs++
+		}
+	}
+	if errs > 0 {
+		return fmt.Errorf("%d errors, %d warnings", errs, len(issues)-errs)
+	}
+	fmt.Fprintf(w, "No errors, %d warnings\n", len(issues))
+	return nil
+}
+
+// lintPages lints the event pages and templates of dir.
+func lintPages(dir string, venues event.Venues) ([]lintIssue, error) {
+	var issues []lintIssue
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		template := strings.HasSuffix(path, ".template")
+		if d.IsDir() || (filepath.Ext(path) != ".md" && !template) {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		issues = append(issues, lintPage(path, content, venues, template)...)
+		return nil
+	})
+	return issues, err
+}
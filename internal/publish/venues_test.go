@@ -0,0 +1,181 @@
+package publish
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/event"
+)
+
+const testVenues = `kulture:
+  name: La Kulture
+  address: 9 rue des Bateliers
+  postal_code: "67000"
+  city: Strasbourg
+  latitude: 48.5806
+  longitude: 7.7527
+quai:
+  address: 36 quai des Bateliers
+  city: Strasbourg
+`
+
+// writeTestVenues writes the venues registry in the current directory.
+func writeTestVenues(t *testing.T) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(event.VenuesFile), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(event.VenuesFile, []byte(testVenues), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseFrontMatterVenue(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeTestVenues(t)
+
+	fmData, err := parseFrontMatter([]byte("---\ntitle: Bal\nvenue: kulture\n---\n"))
+	if err != nil {
+		t.Fatalf("parseFrontMatter: %v", err)
+	}
+	if fmData.Place != "La Kulture, 9 rue des Bateliers" || fmData.City != "Strasbourg" {
+		t.Errorf("venue resolved as %q, %q", fmData.Place, fmData.City)
+	}
+
+	// The announcements use the canonical address
+	message := facebookMessage(EventData{LongDateCapitalized: "Mardi 24 décembre"}, fmData, "https://forrostrasbourg.fr/evenements/241224-bal/")
+	if !strings.Contains(message, "\nLa Kulture, 9 rue des Bateliers, 67000 Strasbourg\n📍 https://www.openstreetmap.org/?mlat=48.58060&mlon=7.75270#map=17/48.58060/7.75270\n") {
+		t.Errorf("message doesn't tell the canonical address:\n%s", message)
+	}
+
+	if _, err := parseFrontMatter([]byte("---\ntitle: Bal\nvenue: nowhere\n---\n")); err == nil || !strings.Contains(err.Error(), `unknown venue "nowhere"`) {
+		t.Errorf("expected an unknown venue error, got %v", err)
+	}
+}
+
+func TestFacebookMessageWithoutVenue(t *testing.T) {
+	fmData := FrontMatterData{Title: "Bal", Place: "Chez Jo", City: "Kehl"}
+	message := facebookMessage(EventData{LongDateCapitalized: "Mardi 24 décembre"}, fmData, "https://forrostrasbourg.fr/evenements/241224-bal/")
+	if !strings.Contains(message, "\nChez Jo, Kehl\n") {
+		t.Errorf("unexpected message:\n%s", message)
+	}
+}
+
+func TestLintPage(t *testing.T) {
+	venues := event.Venues{"kulture": {Name: "La Kulture", Address: "9 rue des Bateliers", City: "Strasbourg"}}
+
+	tests := []struct {
+		name        string
+		content     string
+		template    bool
+		wantMessage string
+		wantWarning bool
+	}{
+		{name: "known venue", content: "---\nvenue: kulture\n---\n"},
+		{name: "unknown venue", content: "---\nvenue: nowhere\n---\n", wantMessage: `unknown venue "nowhere"`},
+		{name: "unknown venue in template", content: "---\nvenue: nowhere\n---\n", template: true, wantMessage: `unknown venue "nowhere"`},
+		{name: "free-text page", content: "---\nplace: Chez Jo\n---\n"},
+		{name: "template with a registry place", content: "---\nplace: La Kulture,  9 rue des bateliers\n---\n", template: true, wantMessage: "use 'venue: kulture'", wantWarning: true},
+		{name: "template with a free-text place", content: "---\nplace: Chez Jo\n---\n", template: true, wantMessage: "free-text place", wantWarning: true},
+		{name: "known category", content: "---\ncategory: bal\nvenue: kulture\n---\n"},
+		{name: "unknown category", content: "---\ncategory: soirée\n---\n", wantMessage: `unknown category "soirée"`},
+		{name: "no front matter", content: "Bal", wantMessage: "no front matter"},
+		{name: "template with dates", content: "---\nstartDate: \"{{.Date}}T{{.Time}}:00+02:00\"\nvenue: kulture\n---\n", template: true},
+		{name: "template with a broken action", content: "---\ntitle: \"{{.Date\"\n---\n", template: true, wantMessage: "error parsing template"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := lintPage("page.md", []byte(tt.content), venues, tt.template)
+			if tt.wantMessage == "" {
+				if len(issues) > 0 {
+					t.Errorf("unexpected issues: %v", issues)
+				}
+				return
+			}
+			if len(issues) != 1 {
+				t.Fatalf("got issues %v, want one", issues)
+			}
+			if !strings.Contains(issues[0].message, tt.wantMessage) || issues[0].warning != tt.wantWarning {
+				t.Errorf("issue = %v, want %q (warning: %v)", issues[0], tt.wantMessage, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestLintVenues(t *testing.T) {
+	venues := event.Venues{
+		"ok":         {Name: "La Kulture", City: "Strasbourg", Latitude: 48.58, Longitude: 7.75},
+		"no-city":    {Name: "Chez Jo", Latitude: 48.58, Longitude: 7.75},
+		"no-coords":  {Name: "Chez Jo", City: "Kehl"},
+		"bad-coords": {Name: "Chez Jo", City: "Kehl", Latitude: 748.58, Longitude: 7.75},
+		"unnamed":    {City: "Kehl", Latitude: 48.58, Longitude: 7.75},
+	}
+
+	var got []string
+	for _, issue := range lintVenues(venues) {
+		got = append(got, issue.String())
+	}
+	want := []string{
+		"data/venues.yaml: bad-coords: error: invalid coordinates 748.58, 7.75",
+		"data/venues.yaml: no-city: error: no city",
+		"data/venues.yaml: no-coords: warning: no latitude/longitude, it can't be shown on a map",
+		"data/venues.yaml: unnamed: error: no name nor address",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("lintVenues =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRunLintCommand(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeTestVenues(t)
+
+	pages := map[string]string{
+		"241224-bal.md":             "---\ntitle: Bal\nvenue: kulture\n---\n",
+		"templates/bal.md.template": "---\ntitle: Bal\nvenue: kulture\n---\n",
+	}
+	for name, content := range pages {
+		path := filepath.Join(eventsDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := runLintCommand(nil, &out); err != nil {
+		t.Fatalf("runLintCommand: %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "No errors, 1 warnings") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+
+	// An unknown venue fails
+	if err := os.WriteFile(filepath.Join(eventsDir, "241231-bal.md"), []byte("---\nvenue: nowhere\n---\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	err := runLintCommand(nil, &out)
+	if err == nil || err.Error() != "1 errors, 1 warnings" {
+		t.Errorf("error = %v, want 1 errors, 1 warnings", err)
+	}
+	if !strings.Contains(out.String(), filepath.Join(eventsDir, "241231-bal.md")+`: error: unknown venue "nowhere"`) {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestRepositoryVenues(t *testing.T) {
+	// The event pages and templates must only use known venues
+	t.Chdir(filepath.Join("..", ".."))
+
+	var out bytes.Buffer
+	if err := runLintCommand(nil, &out); err != nil {
+		t.Errorf("lint: %v\n%s", err, out.String())
+	}
+}
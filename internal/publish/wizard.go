@@ -0,0 +1,387 @@
+package publish
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/send"
+)
+
+// wizard asks the event details one question at a time in the terminal.
+type wizard struct {
+	in  *bufio.Reader
+	out io.Writer
+	// chats send the announcements, by channel name of send.yaml.
+	chats map[string]func(message string) error
+}
+
+var errWizardAborted = errors.New("wizard aborted")
+
+// ask prints the question with its default value and returns the answer,
+// or the default if the answer is empty.
+func (w wizard) ask(question, defaultValue string) (string, error) {
+	if defaultValue != "" {
+		fmt.Fprintf(w.out, "%s [%s]: ", question, defaultValue)
+	} else {
+		fmt.Fprintf(w.out, "%s: ", question)
+	}
+
+	line, err := w.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", errWizardAborted
+	}
+
+	answer := strings.TrimSpace(line)
+	if answer == "" {
+		return defaultValue, nil
+	}
+	return answer, nil
+}
+
+// confirm asks a yes/no question, defaulting to no.
+func (w wizard) confirm(question string) (bool, error) {
+	answer, err := w.ask(question+" (y/N)", "")
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(answer) {
+	case "y", "yes", "o", "oui":
+		return true, nil
+	}
+	return false, nil
+}
+
+// chooseTemplate lists the catalog templates and asks for one, by number or
+// by name.
+func (w wizard) chooseTemplate(catalog templateCatalog) (TemplateInfo, error) {
+	if len(catalog.Templates) == 0 {
+		return TemplateInfo{}, errors.New("the template catalog is empty")
+	}
+
+	fmt.Fprintln(w.out, "Available templates:")
+	for i, t := range catalog.Templates {
+		fmt.Fprintf(w.out, "  %2d. %-40s %s\n", i+1, t.Name, t.Description)
+	}
+
+	for {
+		answer, err := w.ask("Template", "")
+		if err != nil {
+			return TemplateInfo{}, err
+		}
+
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(catalog.Templates) {
+			return catalog.Templates[n-1], nil
+		}
+		if t, ok := catalog.lookup(answer); ok {
+			return t, nil
+		}
+		fmt.Fprintf(w.out, "Unknown template %q, type its number or its name.\n", answer)
+	}
+}
+
+// askDate asks for the event date, suggesting the next occurrence of the
+// template's weekday.
+func (w wizard) askDate(tmplInfo TemplateInfo, now time.Time) (time.Time, error) {
+	defaultDate := ""
+	if weekday, err := parseWeekday(tmplInfo.Weekday); err == nil {
+		defaultDate = nextWeekday(now, weekday).Format("2006-01-02")
+	}
+
+	for {
+		answer, err := w.ask("Date (YYYY-MM-DD)", defaultDate)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		date, err := time.Parse("2006-01-02", answer)
+		if err == nil {
+			return date, nil
+		}
+		fmt.Fprintf(w.out, "Invalid date %q, expected YYYY-MM-DD.\n", answer)
+	}
+}
+
+// askTime asks for the start time, defaulting to the template's. It can
+// only be left empty if the template doesn't use it.
+func (w wizard) askTime(tmplInfo TemplateInfo) (string, error) {
+	required, err := templateUsesTime(tmplInfo.File)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		answer, err := w.ask("Start time (HH:MM)", tmplInfo.Time)
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case answer == "" && required:
+			fmt.Fprintf(w.out, "The template needs a start time, expected HH:MM.\n")
+		case answer == "" || timeOfDayRe.MatchString(answer):
+			return answer, nil
+		default:
+			fmt.Fprintf(w.out, "Invalid time %q, expected HH:MM.\n", answer)
+		}
+	}
+}
+
+// askVars asks a value for each variable the template uses, then for any
+// extra key=value variable.
+func (w wizard) askVars(tmplInfo TemplateInfo) (map[string]any, error) {
+	names, err := templateVarNames(tmplInfo.File)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := templateVars{}
+	for _, name := range names {
+		value, err := w.ask(fmt.Sprintf("Value of %q", name), "")
+		if err != nil {
+			return nil, err
+		}
+		vars[name] = value
+	}
+
+	for {
+		answer, err := w.ask("Extra variable (key=value, empty to continue)", "")
+		if err != nil {
+			return nil, err
+		}
+		if answer == "" {
+			return vars, nil
+		}
+		if err := vars.Set(answer); err != nil {
+			fmt.Fprintln(w.out, err)
+		}
+	}
+}
+
+// templateVarNames returns the sorted names of the {{.Vars.name}} variables
+// used in the template file.
+func templateVarNames(templatePath string) ([]string, error) {
+	tmpl, err := template.ParseFiles(templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template file: %v", err)
+	}
+
+	seen := map[string]bool{}
+	templateFields(tmpl, func(ident []string) {
+		if len(ident) >= 2 && ident[0] == "Vars" {
+			seen[ident[1]] = true
+		}
+	})
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// templateUsesTime tells whether the template file uses {{.Time}}, so the
+// event needs a start time.
+func templateUsesTime(templatePath string) (bool, error) {
+	tmpl, err := template.ParseFiles(templatePath)
+	if err != nil {
+		return false, fmt.Errorf("error parsing template file: %v", err)
+	}
+	return usesTime(tmpl), nil
+}
+
+// usesTime tells whether the templates of tmpl use {{.Time}}.
+func usesTime(tmpl *template.Template) bool {
+	uses := false
+	templateFields(tmpl, func(ident []string) {
+		if len(ident) == 1 && ident[0] == "Time" {
+			uses = true
+		}
+	})
+	return uses
+}
+
+// templateFields calls fn with the fields of the data used by the templates
+// of tmpl, e.g. [Vars teacher] for {{.Vars.teacher}}.
+func templateFields(tmpl *template.Template, fn func(ident []string)) {
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.FieldNode:
+			fn(n.Ident)
+		}
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			walk(t.Tree.Root)
+		}
+	}
+}
+
+// run asks everything needed to publish an event, shows what would be
+// published and publishes it on confirmation.
+func (w wizard) run(catalogPath, lang string, dryRun bool, now time.Time, publish func(EventContext) error) error {
+	catalog, err := loadTemplateCatalog(catalogPath)
+	if err != nil {
+		return err
+	}
+
+	tmplInfo, err := w.chooseTemplate(catalog)
+	if err != nil {
+		return err
+	}
+
+	date, err := w.askDate(tmplInfo, now)
+	if err != nil {
+		return err
+	}
+
+	startTime, err := w.askTime(tmplInfo)
+	if err != nil {
+		return err
+	}
+
+	vars, err := w.askVars(tmplInfo)
+	if err != nil {
+		return err
+	}
+
+	// Preview the event page, the social post and the chat announcement
+	dateStr := date.Format("2006-01-02")
+	data := newEventData(date, dateStr, lang, startTime, vars)
+	rendered, err := renderTemplate(tmplInfo, data)
+	if err != nil {
+		return err
+	}
+	fmData, err := parseFrontMatter(rendered)
+	if err != nil {
+		return fmt.Errorf("invalid rendered event: %v", err)
+	}
+	outputPath, eventURL := eventPaths(tmplInfo, date)
+
+	fmt.Fprintf(w.out, "\n===== %s =====\n%s\n", outputPath, rendered)
+	fmt.Fprintf(w.out, "\n===== Facebook post =====\n%s\n\n", facebookMessage(data, fmData, eventURL))
+	chats := announcingChats(w.chats, tmplInfo)
+	chatMessage := chatAnnouncement(data, fmData, eventURL)
+	if len(chats) > 0 {
+		fmt.Fprintf(w.out, "===== Chat announcement =====\n%s\n\n", chatMessage)
+	}
+
+	publishFacebook := false
+	pages := strings.Join(tmplInfo.FacebookPages, ",")
+	if pages != "" {
+		publishFacebook, err = w.confirm(fmt.Sprintf("Also publish on Facebook (%s)?", pages))
+		if err != nil {
+			return err
+		}
+	}
+
+	announce := false
+	if len(chats) > 0 {
+		announce, err = w.confirm(fmt.Sprintf("Also announce in the chats (%s)?", strings.Join(chats, ", ")))
+		if err != nil {
+			return err
+		}
+	}
+
+	ok, err := w.confirm("Publish this event?")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Fprintln(w.out, "Nothing published.")
+		return nil
+	}
+
+	ctx := EventContext{
+		Date:            date,
+		TemplatePath:    tmplInfo.Name,
+		CatalogPath:     catalogPath,
+		Time:            startTime,
+		Language:        lang,
+		DryRun:          dryRun,
+		PublishFacebook: publishFacebook,
+		FacebookPages:   pages,
+		Vars:            vars,
+	}
+	if publishFacebook {
+		ctx.PageAccessToken, err = facebookPageAccessToken()
+		if err != nil {
+			return err
+		}
+	}
+	if err := publish(ctx); err != nil {
+		return err
+	}
+
+	// Announced once the event page is published, as the admin does
+	if !announce {
+		return nil
+	}
+	if errs := announceEvent(w.chats, chats, chatMessage, dryRun); len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	fmt.Fprintf(w.out, "Announced in %s.\n", strings.Join(chats, ", "))
+	return nil
+}
+
+// runWizardCommand implements the "wizard" command.
+func runWizardCommand(args []string) error {
+	fs := newFlagSet("wizard", "")
+	catalogPath := fs.String("catalog", defaultCatalogPath, "Path to the template catalog")
+	lang := fs.String("lang", "fr", "Language code for date formatting (e.g. 'fr' or 'en')")
+	dryRun := fs.Bool("dry-run", false, "If true, only echo the actions without carrying them out")
+	configPath := fs.String("config", "send.yaml", "Channels configuration of the chats announcing the events, the env is used if it doesn't exist")
+	fs.Parse(args)
+
+	// A missing token fails when announcing, not when starting the wizard
+	chats, err := send.Announcers(*configPath)
+	if err != nil {
+		return err
+	}
+	w := wizard{in: bufio.NewReader(os.Stdin), out: os.Stdout, chats: chats}
+	return w.run(*catalogPath, *lang, *dryRun, time.Now(), publishEvent)
+}
@@ -0,0 +1,199 @@
+package publish
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemplateVarNames(t *testing.T) {
+	tmpDir := t.TempDir()
+	templatePath := filepath.Join(tmpDir, "test.md.template")
+	content := `---
+title: Cours avec {{ .Vars.teacher }}
+---
+{{ if .Vars.theme }}Thème : {{ .Vars.theme | printf "%s" }}{{ end }}
+{{ .Date }} {{ .Vars.teacher }}`
+	if err := os.WriteFile(templatePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+
+	got, err := templateVarNames(templatePath)
+	if err != nil {
+		t.Fatalf("templateVarNames() unexpected error: %v", err)
+	}
+	want := []string{"teacher", "theme"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("templateVarNames() = %v, want %v", got, want)
+	}
+}
+
+func TestWizardRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	templateContent := `---
+title: Cours avec {{ .Vars.teacher }}
+startDate: "{{.Date}}T{{.Time}}:00+02:00"
+place: Test Place
+city: Test City
+---
+Event on {{ .LongDate }}`
+	if err := os.WriteFile(filepath.Join(tmpDir, "cours.md.template"), []byte(templateContent), 0644); err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+	catalogPath := filepath.Join(tmpDir, "catalog.yaml")
+	catalog := `templates:
+  - name: other
+    file: other.md.template
+  - name: cours
+    file: cours.md.template
+    weekday: monday
+    time: "19:30"
+    facebook_pages: [forro-stras]
+    chats: [forrostrasbourg]
+  - name: cours-sans-heure
+    file: cours.md.template
+`
+	if err := os.WriteFile(catalogPath, []byte(catalog), 0644); err != nil {
+		t.Fatalf("Failed to create catalog: %v", err)
+	}
+
+	// A tuesday, so the suggested date is the following monday
+	now := time.Date(2024, 12, 24, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		input        string
+		wantPublish  bool
+		wantAnnounce bool
+		wantCtx      EventContext
+		wantOutput   []string
+	}{
+		{
+			name: "defaults and publish",
+			input: strings.Join([]string{
+				"cours",     // template
+				"",          // date
+				"20:00",     // time
+				"Maria",     // teacher
+				"price=10€", // extra variable
+				"",          // no more variables
+				"n",         // facebook
+				"y",         // chats
+				"y",         // publish
+			}, "\n") + "\n",
+			wantPublish:  true,
+			wantAnnounce: true,
+			wantCtx: EventContext{
+				Date:          time.Date(2024, 12, 30, 0, 0, 0, 0, time.UTC),
+				TemplatePath:  "cours",
+				CatalogPath:   catalogPath,
+				Time:          "20:00",
+				Language:      "fr",
+				FacebookPages: "forro-stras",
+				Vars:          map[string]any{"teacher": "Maria", "price": "10€"},
+			},
+			wantOutput: []string{
+				"title: Cours avec Maria",
+				`startDate: "2024-12-30T20:00:00+02:00"`,
+				"Lundi 30 décembre: Cours avec Maria",
+				"https://forrostrasbourg.fr/evenements/241230-cours/",
+				"===== Chat announcement =====\nNouvel événement : Lundi 30 décembre à 20h00, Cours avec Maria\nTest Place, Test City\n",
+				"Also announce in the chats (forrostrasbourg)?",
+				"Announced in forrostrasbourg.",
+			},
+		},
+		{
+			name: "retry invalid answers then cancel",
+			input: strings.Join([]string{
+				"42",         // invalid template number
+				"2",          // template
+				"2024-13-01", // invalid date
+				"2025-01-06", // date
+				"7pm",        // invalid time
+				"",           // time
+				"Manoel",     // teacher
+				"",           // no more variables
+				"",           // facebook
+				"",           // chats
+				"",           // publish
+			}, "\n") + "\n",
+			wantPublish: false,
+			wantOutput: []string{
+				`Unknown template "42"`,
+				`Invalid date "2024-13-01"`,
+				`Invalid time "7pm"`,
+				`startDate: "2025-01-06T19:30:00+02:00"`,
+				"Nothing published.",
+			},
+		},
+		{
+			name: "time required by the template",
+			input: strings.Join([]string{
+				"cours-sans-heure", // template
+				"2025-01-06",       // date
+				"",                 // no time
+				"21:00",            // time
+				"Manoel",           // teacher
+				"",                 // no more variables
+				"",                 // facebook
+				"",                 // publish
+			}, "\n") + "\n",
+			wantPublish: false,
+			wantOutput: []string{
+				"The template needs a start time",
+				`startDate: "2025-01-06T21:00:00+02:00"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			var announced []string
+			w := wizard{in: bufio.NewReader(strings.NewReader(tt.input)), out: &out, chats: map[string]func(string) error{
+				"forrostrasbourg": func(message string) error {
+					announced = append(announced, message)
+					return nil
+				},
+			}}
+
+			published := false
+			var gotCtx EventContext
+			err := w.run(catalogPath, "fr", false, now, func(ctx EventContext) error {
+				published = true
+				gotCtx = ctx
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("run() unexpected error: %v\noutput:\n%s", err, out.String())
+			}
+
+			if published != tt.wantPublish {
+				t.Fatalf("published = %v, want %v", published, tt.wantPublish)
+			}
+			if len(announced) > 0 != tt.wantAnnounce {
+				t.Errorf("announced = %q, want announced: %v", announced, tt.wantAnnounce)
+			}
+			if tt.wantPublish && !reflect.DeepEqual(gotCtx, tt.wantCtx) {
+				t.Errorf("context = %+v, want %+v", gotCtx, tt.wantCtx)
+			}
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output doesn't contain %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestWizardAbortOnEOF(t *testing.T) {
+	w := wizard{in: bufio.NewReader(strings.NewReader("")), out: &bytes.Buffer{}}
+	if _, err := w.ask("Template", ""); err != errWizardAborted {
+		t.Errorf("ask() error = %v, want %v", err, errWizardAborted)
+	}
+}
@@ -0,0 +1,288 @@
+// Package secrets reads the access tokens of the scripts from the env, from
+// an encrypted secrets file shared in the repository, or from the keyring of
+// the desktop, so they don't have to live in a plaintext .env.
+package secrets
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/joho/godotenv"
+)
+
+// DefaultFile is the encrypted secrets file, from the root of the site.
+const DefaultFile = "secrets.env.age"
+
+// DefaultService is the service of the secrets in the keyring.
+const DefaultService = "forrostrasbourg.fr"
+
+// DefaultProviders are the providers looked up, in order.
+var DefaultProviders = []string{"env", "file", "keyring"}
+
+// ErrNotFound is returned for a secret that a provider doesn't have.
+var ErrNotFound = errors.New("secret not found")
+
+// Provider is a place the secrets are read from.
+type Provider interface {
+	// Lookup returns the secret, or ErrNotFound.
+	Lookup(name string) (string, error)
+}
+
+// Runner runs a command and returns its output, e.g. to fake gpg and
+// secret-tool in tests.
+type Runner func(name string, args ...string) ([]byte, error)
+
+func runCommand(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).Output()
+}
+
+// Env reads the secrets from the env variables, including the ones of the
+// .env loaded by the scripts. An empty variable has no secret, so it
+// doesn't hide the ones of the next providers.
+type Env struct{}
+
+func (Env) Lookup(name string) (string, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok || v == "" {
+		return "", ErrNotFound
+	}
+	return v, nil
+}
+
+// File reads the secrets of an encrypted dotenv file: with age, or with gpg
+// if its name ends in .gpg. It is decrypted on the first lookup. A missing
+// file has no secrets, and neither has a file without the age identity to
+// decrypt it, so the organizers without the key use the next providers.
+type File struct {
+	Path string
+	// Identity is the file of the age identities decrypting Path.
+	Identity string
+	// Run runs gpg, exec.Command by default.
+	Run Runner
+
+	once    sync.Once
+	secrets map[string]string
+	err     error
+}
+
+func (f *File) Lookup(name string) (string, error) {
+	f.once.Do(func() {
+		f.secrets, f.err = f.decrypt()
+	})
+	if f.err != nil {
+		return "", f.err
+	}
+	v, ok := f.secrets[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return v, nil
+}
+
+func (f *File) decrypt() (map[string]string, error) {
+	if _, err := os.Stat(f.Path); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if strings.HasSuffix(f.Path, ".gpg") {
+		run := f.Run
+		if run == nil {
+			run = runCommand
+		}
+		out, err := run("gpg", "--batch", "--quiet", "--decrypt", f.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s with gpg: %v", f.Path, err)
+		}
+		return parseDotenv(f.Path, bytes.NewReader(out))
+	}
+
+	if _, err := os.Stat(f.Identity); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	identities, err := readIdentities(f.Identity)
+	if err != nil {
+		return nil, err
+	}
+	in, err := os.Open(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets: %v", err)
+	}
+	defer in.Close()
+
+	// The file can be armored, to be readable in the diffs
+	var r io.Reader = bufio.NewReader(in)
+	if start, _ := r.(*bufio.Reader).Peek(len(armor.Header)); string(start) == armor.Header {
+		r = armor.NewReader(r)
+	}
+	plain, err := age.Decrypt(r, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s with %s: %v", f.Path, f.Identity, err)
+	}
+	return parseDotenv(f.Path, plain)
+}
+
+func readIdentities(path string) ([]age.Identity, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read age identity: %v", err)
+	}
+	defer f.Close()
+
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse age identity %s: %v", path, err)
+	}
+	return identities, nil
+}
+
+func parseDotenv(path string, r io.Reader) (map[string]string, error) {
+	secrets, err := godotenv.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse secrets %s: %v", path, err)
+	}
+	return secrets, nil
+}
+
+// Keyring reads the secrets of the Secret Service of the desktop (GNOME
+// Keyring, KWallet) with secret-tool, stored with the service and name
+// attributes. Without secret-tool, the keyring has no secrets.
+type Keyring struct {
+	Service string
+	// Run runs secret-tool, exec.Command by default.
+	Run Runner
+}
+
+func (k Keyring) Lookup(name string) (string, error) {
+	run := k.Run
+	if run == nil {
+		run = runCommand
+	}
+	out, err := run("secret-tool", "lookup", "service", k.Service, "name", name)
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		return "", ErrNotFound
+	case errors.As(err, &exitErr) && len(out) == 0 && len(exitErr.Stderr) == 0:
+		// secret-tool fails silently for a missing secret
+		return "", ErrNotFound
+	case err != nil:
+		return "", fmt.Errorf("failed to read the keyring: %v", err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// Store looks the secrets up in its providers.
+type Store struct {
+	// Names are the providers looked up, in order.
+	Names     []string
+	Providers map[string]Provider
+}
+
+// Get returns the secret of the reference: a name looked up in every
+// provider, or provider:name for a given provider, e.g. keyring:NAME.
+func (s *Store) Get(ref string) (string, error) {
+	names := s.Names
+	name := ref
+	if provider, n, ok := strings.Cut(ref, ":"); ok {
+		if _, ok := s.Providers[provider]; !ok {
+			return "", fmt.Errorf("unknown secret provider %q in %q", provider, ref)
+		}
+		names, name = []string{provider}, n
+	}
+	if name == "" {
+		return "", fmt.Errorf("invalid secret reference %q", ref)
+	}
+
+	for _, n := range names {
+		v, err := s.Providers[n].Lookup(name)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("secret %s from %s: %v", name, n, err)
+		}
+		return v, nil
+	}
+	return "", fmt.Errorf("%w: %s in %s", ErrNotFound, name, strings.Join(names, ", "))
+}
+
+// Default returns the store configured by the env: SECRETS_PROVIDERS, the
+// comma separated providers looked up (env, file, keyring by default),
+// SECRETS_FILE, the encrypted file (DefaultFile by default), and
+// SECRETS_AGE_IDENTITY, its age identity (forrostrasbourg/age.key in the
+// user config directory by default).
+func Default() (*Store, error) {
+	identity := os.Getenv("SECRETS_AGE_IDENTITY")
+	if rest, ok := strings.CutPrefix(identity, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		identity = filepath.Join(home, rest)
+	}
+	if identity == "" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		identity = filepath.Join(dir, "forrostrasbourg", "age.key")
+	}
+	path := os.Getenv("SECRETS_FILE")
+	if path == "" {
+		path = DefaultFile
+	}
+
+	s := &Store{
+		Names: DefaultProviders,
+		Providers: map[string]Provider{
+			"env":     Env{},
+			"file":    &File{Path: path, Identity: identity},
+			"keyring": Keyring{Service: DefaultService},
+		},
+	}
+	if v := os.Getenv("SECRETS_PROVIDERS"); v != "" {
+		s.Names = nil
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if _, ok := s.Providers[name]; !ok {
+				return nil, fmt.Errorf("unknown secret provider %q in SECRETS_PROVIDERS", name)
+			}
+			s.Names = append(s.Names, name)
+		}
+	}
+	return s, nil
+}
+
+// defaultStore is the Default store, built once for the configuration of
+// the env, so the secrets file is decrypted once.
+var defaultStore struct {
+	sync.Mutex
+	config string
+	store  *Store
+}
+
+// Get returns the secret of the reference from the Default store.
+func Get(ref string) (string, error) {
+	defaultStore.Lock()
+	defer defaultStore.Unlock()
+
+	config := strings.Join([]string{os.Getenv("SECRETS_PROVIDERS"), os.Getenv("SECRETS_FILE"), os.Getenv("SECRETS_AGE_IDENTITY")}, "\x00")
+	if defaultStore.store == nil || defaultStore.config != config {
+		s, err := Default()
+		if err != nil {
+			return "", err
+		}
+		defaultStore.config, defaultStore.store = config, s
+	}
+	return defaultStore.store.Get(ref)
+}
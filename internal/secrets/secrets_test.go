@@ -0,0 +1,253 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// encrypt writes the age-encrypted content at path, and returns the path of
+// the identity decrypting it.
+func encrypt(t *testing.T, path, content string, armored bool) string {
+	t.Helper()
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	identityPath := filepath.Join(t.TempDir(), "age.key")
+	if err := os.WriteFile(identityPath, []byte("# test key\n"+identity.String()+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	var out io.WriteCloser = nopCloser{&buf}
+	if armored {
+		out = armor.NewWriter(&buf)
+	}
+	w, err := age.Encrypt(out, identity.Recipient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(w, content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return identityPath
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+func TestFile(t *testing.T) {
+	for _, armored := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "secrets.env.age")
+		identity := encrypt(t, path, "BEEPER_ACCESS_TOKEN=beeper\nFACEBOOK_PAGE_ACCESS_TOKEN='facebook'\n", armored)
+
+		f := &File{Path: path, Identity: identity}
+		if v, err := f.Lookup("FACEBOOK_PAGE_ACCESS_TOKEN"); err != nil || v != "facebook" {
+			t.Errorf("armored %v: Lookup = %q, %v", armored, v, err)
+		}
+		if _, err := f.Lookup("OTHER"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("armored %v: Lookup of a missing secret = %v, want ErrNotFound", armored, err)
+		}
+	}
+}
+
+func TestFileErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	// No file, no secrets
+	f := &File{Path: filepath.Join(dir, "missing.env.age"), Identity: filepath.Join(dir, "missing.key")}
+	if _, err := f.Lookup("BEEPER_ACCESS_TOKEN"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Lookup without a file = %v, want ErrNotFound", err)
+	}
+
+	// The shared file, but no key: no secrets, the next providers are used
+	path := filepath.Join(dir, "secrets.env.age")
+	encrypt(t, path, "BEEPER_ACCESS_TOKEN=beeper\n", false)
+	f = &File{Path: path, Identity: filepath.Join(dir, "missing.key")}
+	if _, err := f.Lookup("BEEPER_ACCESS_TOKEN"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Lookup without an identity = %v, want ErrNotFound", err)
+	}
+	s := &Store{Names: []string{"file", "keyring"}, Providers: map[string]Provider{"file": f, "keyring": mapProvider{"BEEPER_ACCESS_TOKEN": "keyring"}}}
+	if v, err := s.Get("BEEPER_ACCESS_TOKEN"); err != nil || v != "keyring" {
+		t.Errorf("Get without an identity = %q, %v, want the keyring secret", v, err)
+	}
+
+	// Somebody else's key
+	other := encrypt(t, filepath.Join(dir, "other.age"), "", false)
+	f = &File{Path: path, Identity: other}
+	if _, err := f.Lookup("BEEPER_ACCESS_TOKEN"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Lookup with the wrong identity = %v, want a decryption error", err)
+	}
+}
+
+func TestEnv(t *testing.T) {
+	t.Setenv("BEEPER_ACCESS_TOKEN", "beeper")
+	if v, err := (Env{}).Lookup("BEEPER_ACCESS_TOKEN"); err != nil || v != "beeper" {
+		t.Errorf("Lookup = %q, %v", v, err)
+	}
+
+	// An empty variable doesn't hide the other providers
+	t.Setenv("BEEPER_ACCESS_TOKEN", "")
+	if _, err := (Env{}).Lookup("BEEPER_ACCESS_TOKEN"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Lookup of an empty variable = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFileGPG(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.env.gpg")
+	if err := os.WriteFile(path, []byte("encrypted"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var command string
+	f := &File{Path: path, Run: func(name string, args ...string) ([]byte, error) {
+		command = name + " " + strings.Join(args, " ")
+		return []byte("BEEPER_ACCESS_TOKEN=beeper\n"), nil
+	}}
+	if v, err := f.Lookup("BEEPER_ACCESS_TOKEN"); err != nil || v != "beeper" {
+		t.Errorf("Lookup = %q, %v", v, err)
+	}
+	if command != "gpg --batch --quiet --decrypt "+path {
+		t.Errorf("ran %q", command)
+	}
+}
+
+func TestKeyring(t *testing.T) {
+	k := Keyring{Service: "test", Run: func(name string, args ...string) ([]byte, error) {
+		if strings.Join(args, " ") == "lookup service test name BEEPER_ACCESS_TOKEN" {
+			return []byte("beeper\n"), nil
+		}
+		return nil, &exec.ExitError{}
+	}}
+	if v, err := k.Lookup("BEEPER_ACCESS_TOKEN"); err != nil || v != "beeper" {
+		t.Errorf("Lookup = %q, %v", v, err)
+	}
+	if _, err := k.Lookup("OTHER"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Lookup of a missing secret = %v, want ErrNotFound", err)
+	}
+
+	k.Run = func(name string, args ...string) ([]byte, error) { return nil, exec.ErrNotFound }
+	if _, err := k.Lookup("BEEPER_ACCESS_TOKEN"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Lookup without secret-tool = %v, want ErrNotFound", err)
+	}
+}
+
+// mapProvider is a provider of fixed secrets.
+type mapProvider map[string]string
+
+func (p mapProvider) Lookup(name string) (string, error) {
+	v, ok := p[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return v, nil
+}
+
+func TestStore(t *testing.T) {
+	s := &Store{
+		Names: []string{"env", "file"},
+		Providers: map[string]Provider{
+			"env":     mapProvider{"A": "env-a"},
+			"file":    mapProvider{"A": "file-a", "B": "file-b"},
+			"keyring": mapProvider{"C": "keyring-c"},
+		},
+	}
+
+	tests := []struct {
+		ref         string
+		want        string
+		errContains string
+	}{
+		{ref: "A", want: "env-a"},
+		{ref: "B", want: "file-b"},
+		{ref: "file:A", want: "file-a"},
+		{ref: "keyring:C", want: "keyring-c"},
+		{ref: "C", errContains: "secret not found: C in env, file"},
+		{ref: "env:B", errContains: "secret not found: B in env"},
+		{ref: "vault:A", errContains: `unknown secret provider "vault"`},
+		{ref: "env:", errContains: "invalid secret reference"},
+	}
+	for _, tt := range tests {
+		got, err := s.Get(tt.ref)
+		if tt.errContains != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Get(%q) error = %v, want %q", tt.ref, err, tt.errContains)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Get(%q) = %q, %v, want %q", tt.ref, got, err, tt.want)
+		}
+	}
+}
+
+func TestDefault(t *testing.T) {
+	t.Setenv("SECRETS_PROVIDERS", "keyring, env")
+	s, err := Default()
+	if err != nil {
+		t.Fatalf("Default: %v", err)
+	}
+	if strings.Join(s.Names, ",") != "keyring,env" {
+		t.Errorf("providers = %v", s.Names)
+	}
+
+	t.Setenv("HOME", "/home/alice")
+	t.Setenv("SECRETS_AGE_IDENTITY", "~/keys/age.key")
+	s, err = Default()
+	if err != nil {
+		t.Fatalf("Default: %v", err)
+	}
+	if f := s.Providers["file"].(*File); f.Identity != "/home/alice/keys/age.key" {
+		t.Errorf("identity = %s", f.Identity)
+	}
+
+	t.Setenv("SECRETS_PROVIDERS", "env,vault")
+	if _, err := Default(); err == nil {
+		t.Error("Expected error but got none")
+	}
+}
+
+func TestGet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.env.age")
+	identity := encrypt(t, path, "BEEPER_ACCESS_TOKEN=beeper\n", false)
+	t.Setenv("SECRETS_PROVIDERS", "file")
+	t.Setenv("SECRETS_FILE", path)
+	t.Setenv("SECRETS_AGE_IDENTITY", identity)
+
+	if v, err := Get("BEEPER_ACCESS_TOKEN"); err != nil || v != "beeper" {
+		t.Fatalf("Get = %q, %v", v, err)
+	}
+
+	// The file is decrypted once
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := Get("BEEPER_ACCESS_TOKEN"); err != nil || v != "beeper" {
+		t.Errorf("Get after the first one = %q, %v", v, err)
+	}
+
+	// Another configuration gets its own store
+	t.Setenv("SECRETS_PROVIDERS", "file,env")
+	if _, err := Get("BEEPER_ACCESS_TOKEN"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get with another configuration = %v, want ErrNotFound", err)
+	}
+}
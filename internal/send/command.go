@@ -0,0 +1,48 @@
+package send
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// UsageName returns the name of a mode in the usage messages, e.g. "send
+// remind", the empty name being the weekly digest. The forro CLI replaces it
+// by its own command names.
+var UsageName = func(name string) string {
+	return strings.TrimSpace("send " + name)
+}
+
+// newFlagSet returns the flags of the mode, with the usage message telling
+// its arguments.
+func newFlagSet(name, arguments string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		usage := strings.TrimSpace(UsageName(name) + " [flags] " + arguments)
+		fmt.Fprintf(fs.Output(), "Usage: %s\n\nFlags:\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// Run runs the mode of args[0], or the weekly digest if args[0] isn't a
+// mode.
+func Run(args []string, w io.Writer) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "weather-check":
+			return runWeatherCheck(args[1:], w)
+		case "config":
+			return runConfig(args[1:], w)
+		case "remind":
+			return runRemind(args[1:], w)
+		}
+	}
+
+	cfg, err := loadConfig(args)
+	if err != nil {
+		return err
+	}
+	return run(cfg)
+}
@@ -0,0 +1,368 @@
+package send
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/beeper"
+	"github.com/dolanor/forrostrasbourg.fr/internal/secrets"
+)
+
+// defaultConfigPath is the channels configuration, next to the .env.
+const defaultConfigPath = "send.yaml"
+
+// The kinds of messages a channel can get.
+const (
+	messageDigest  = "digest"
+	messageRemind  = "remind"
+	messageWeather = "weather"
+	// messageAnnounce is the announcement of a new event, sent by the form
+	// of publish serve.
+	messageAnnounce = "announce"
+)
+
+var messageKinds = []string{messageDigest, messageRemind, messageWeather, messageAnnounce}
+
+// defaultMessages are the messages of a channel that doesn't tell.
+var defaultMessages = []string{messageDigest, messageRemind, messageAnnounce}
+
+// backends are the messaging services the channels can use.
+var backends = []string{"beeper"}
+
+// defaultToken is the token reference of a channel that doesn't tell.
+const defaultToken = "BEEPER_ACCESS_TOKEN"
+
+// sendConfig is the configuration of send, as read from send.yaml.
+type sendConfig struct {
+	Channels []channel    `yaml:"channels"`
+	Digest   digestConfig `yaml:"digest"`
+}
+
+// digestConfig are the settings of the weekly digest.
+type digestConfig struct {
+	// EmptyWeek is what to do when the week has no event: skip, message or
+	// upcoming. See emptyWeekModes.
+	EmptyWeek string `yaml:"empty_week"`
+	// EmptyWeekMessage is the message sent with the message mode.
+	EmptyWeekMessage string `yaml:"empty_week_message"`
+	// Upcoming is the number of next events listed with the upcoming mode.
+	Upcoming int `yaml:"upcoming"`
+}
+
+// channel is a chat the messages are sent to.
+type channel struct {
+	Name string `yaml:"name"`
+	// Backend is the messaging service, beeper by default.
+	Backend string `yaml:"backend"`
+	ChatID  string `yaml:"chat_id"`
+	// Token references the secret access token of the backend, looked up
+	// by the secrets package: a name, e.g. BEEPER_ACCESS_TOKEN, or
+	// provider:name, e.g. keyring:BEEPER_ACCESS_TOKEN.
+	Token string `yaml:"token"`
+	// Template is a file replacing the template of the weekly digest,
+	// relative to the configuration. It can use the "groups" template.
+	Template string `yaml:"template"`
+	// Categories are the categories or tags of the events announced in the
+	// channel, all of them if empty.
+	Categories []string `yaml:"categories"`
+	// Messages are the kinds of messages sent to the channel: digest, remind
+	// and weather.
+	Messages []string `yaml:"messages"`
+
+	// digestTemplate is the content of Template.
+	digestTemplate string
+}
+
+// wants tells whether the events of category with tags are announced in the
+// channel.
+func (c channel) wants(category string, tags []string) bool {
+	if len(c.Categories) == 0 {
+		return true
+	}
+	for _, want := range c.Categories {
+		if want == category || slices.Contains(tags, want) {
+			return true
+		}
+	}
+	return false
+}
+
+// events returns the events announced in the channel.
+func (c channel) events(events []digestEvent) []digestEvent {
+	var wanted []digestEvent
+	for _, e := range events {
+		if c.wants(e.Category, e.Tags) {
+			wanted = append(wanted, e)
+		}
+	}
+	return wanted
+}
+
+// sender returns the client sending the messages to the channel, with the
+// token of the backend.
+func (c channel) sender() (messageSender, error) {
+	token, err := secrets.Get(c.Token)
+	if err != nil {
+		return nil, fmt.Errorf("channel %s: %v", c.Name, err)
+	}
+	switch c.Backend {
+	case "beeper":
+		return beeper.NewClient(token), nil
+	default:
+		return nil, fmt.Errorf("channel %s: unknown backend %q", c.Name, c.Backend)
+	}
+}
+
+// newSender makes the sender of a channel, replaced in tests.
+var newSender = channel.sender
+
+// deliverySender returns the sender of the channel, or one failing every
+// message if it can't be made, e.g. without its token. The channel is then
+// reported in the summary, without keeping the next channels from their
+// messages.
+func (c channel) deliverySender() messageSender {
+	sender, err := newSender(c)
+	if err != nil {
+		return failingSender{err: err}
+	}
+	return sender
+}
+
+// channels returns the channels getting the kind of messages.
+func (cfg sendConfig) channels(kind string) []channel {
+	var channels []channel
+	for _, c := range cfg.Channels {
+		if slices.Contains(c.Messages, kind) {
+			channels = append(channels, c)
+		}
+	}
+	return channels
+}
+
+// loadSendConfig reads the configuration at path, with the defaults of the
+// channels. Without a configuration, the channels come from the env like
+// before: see channelsFromEnv.
+func loadSendConfig(path string) (sendConfig, error) {
+	var cfg sendConfig
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return channelsFromEnv(), nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read send configuration: %v", err)
+	}
+
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse send configuration %s: %v", path, err)
+	}
+
+	for i := range cfg.Channels {
+		c := &cfg.Channels[i]
+		if c.Backend == "" {
+			c.Backend = "beeper"
+		}
+		if c.Token == "" {
+			c.Token = defaultToken
+		}
+		if c.Messages == nil {
+			c.Messages = defaultMessages
+		}
+		if c.Template != "" {
+			c.Template = filepath.Join(filepath.Dir(path), c.Template)
+			b, err := os.ReadFile(c.Template)
+			if err != nil {
+				return cfg, fmt.Errorf("send configuration %s: channel %s: failed to read template: %v", path, c.Name, err)
+			}
+			c.digestTemplate = string(b)
+		}
+	}
+
+	if cfg.Digest.EmptyWeek == "" {
+		cfg.Digest.EmptyWeek = emptyWeekSkip
+	}
+	if cfg.Digest.EmptyWeekMessage == "" {
+		cfg.Digest.EmptyWeekMessage = defaultEmptyWeekMessage
+	}
+	if cfg.Digest.Upcoming == 0 {
+		cfg.Digest.Upcoming = defaultUpcoming
+	}
+
+	return cfg, nil
+}
+
+// channelsFromEnv are the forrostrasbourg and special channels of the env:
+// <NAME>_CHAT_GROUP_ID, and the comma separated <NAME>_CHAT_CATEGORIES. The
+// chats without an ID are left out.
+func channelsFromEnv() sendConfig {
+	cfg := sendConfig{
+		Digest: digestConfig{
+			EmptyWeek:        emptyWeekSkip,
+			EmptyWeekMessage: defaultEmptyWeekMessage,
+			Upcoming:         defaultUpcoming,
+		},
+	}
+	for _, name := range []string{"forrostrasbourg", "special"} {
+		prefix := strings.ToUpper(name) + "_CHAT_"
+		c := channel{
+			Name:     name,
+			Backend:  "beeper",
+			ChatID:   os.Getenv(prefix + "GROUP_ID"),
+			Token:    defaultToken,
+			Messages: defaultMessages,
+		}
+		if c.ChatID == "" {
+			continue
+		}
+		if name == "forrostrasbourg" {
+			c.Messages = messageKinds
+		}
+		for _, category := range strings.Split(os.Getenv(prefix+"CATEGORIES"), ",") {
+			category = strings.TrimSpace(category)
+			if category != "" {
+				c.Categories = append(c.Categories, category)
+			}
+		}
+		cfg.Channels = append(cfg.Channels, c)
+	}
+	return cfg
+}
+
+// validate returns the problems of the configuration, including the secrets
+// that can't be read.
+func (cfg sendConfig) validate() []error {
+	var errs []error
+	if !slices.Contains(emptyWeekModes, cfg.Digest.EmptyWeek) {
+		errs = append(errs, fmt.Errorf("digest: unknown empty_week %q, expected one of %s", cfg.Digest.EmptyWeek, strings.Join(emptyWeekModes, ", ")))
+	}
+	if cfg.Digest.Upcoming < 0 {
+		errs = append(errs, fmt.Errorf("digest: negative upcoming %d", cfg.Digest.Upcoming))
+	}
+	seen := map[string]bool{}
+	for i, c := range cfg.Channels {
+		if c.Name == "" {
+			errs = append(errs, fmt.Errorf("channel %d has no name", i+1))
+			continue
+		}
+		if seen[c.Name] {
+			errs = append(errs, fmt.Errorf("duplicate channel %q", c.Name))
+		}
+		seen[c.Name] = true
+
+		if !slices.Contains(backends, c.Backend) {
+			errs = append(errs, fmt.Errorf("channel %s: unknown backend %q, expected one of %s", c.Name, c.Backend, strings.Join(backends, ", ")))
+		}
+		if c.ChatID == "" {
+			errs = append(errs, fmt.Errorf("channel %s: no chat_id", c.Name))
+		}
+		if _, err := secrets.Get(c.Token); err != nil {
+			errs = append(errs, fmt.Errorf("channel %s: %v", c.Name, err))
+		}
+		for _, kind := range c.Messages {
+			if !slices.Contains(messageKinds, kind) {
+				errs = append(errs, fmt.Errorf("channel %s: unknown message %q, expected one of %s", c.Name, kind, strings.Join(messageKinds, ", ")))
+			}
+		}
+		if c.digestTemplate != "" {
+			if _, err := parseDigestTemplate(c.digestTemplate); err != nil {
+				errs = append(errs, fmt.Errorf("channel %s: %v", c.Name, err))
+			}
+		}
+	}
+	return errs
+}
+
+// parseDigestTemplate parses the template of the weekly digest, with the
+// "groups" template listing the events by day.
+func parseDigestTemplate(templ string) (*template.Template, error) {
+	t, err := template.New("message").Parse(templ)
+	if err != nil {
+		return nil, err
+	}
+	_, err = t.Parse(groupsTempl)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// runConfig implements the "config" mode: "config validate" checks the
+// configuration and the secrets of the channels.
+func runConfig(args []string, w io.Writer) error {
+	fs := newFlagSet("config", "validate")
+	configPath := fs.String("config", defaultConfigPath, "channels configuration, the env is used if it doesn't exist")
+	fs.Parse(args)
+
+	if fs.Arg(0) != "validate" {
+		fs.Usage()
+		return fmt.Errorf("unknown config command %q", fs.Arg(0))
+	}
+
+	if err := loadEnv(); err != nil {
+		return err
+	}
+	cfg, err := loadSendConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	errs := cfg.validate()
+	for _, err := range errs {
+		fmt.Fprintf(w, "ERROR: %v\n", err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d errors in the send configuration", len(errs))
+	}
+	for _, c := range cfg.Channels {
+		categories := "every event"
+		if len(c.Categories) > 0 {
+			categories = strings.Join(c.Categories, ", ")
+		}
+		fmt.Fprintf(w, "%s: %s chat %s, messages: %s, events: %s\n", c.Name, c.Backend, c.ChatID, strings.Join(c.Messages, ", "), categories)
+	}
+	fmt.Fprintf(w, "OK: %d channels\n", len(cfg.Channels))
+	return nil
+}
+
+// Announcers returns the functions announcing a new event in the channels
+// of the configuration at path getting the announce messages, by channel
+// name. The token of a channel is only read when announcing.
+func Announcers(path string) (map[string]func(message string) error, error) {
+	if err := loadEnv(); err != nil {
+		return nil, err
+	}
+	cfg, err := loadSendConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	announcers := map[string]func(string) error{}
+	for _, c := range cfg.channels(messageAnnounce) {
+		announcers[c.Name] = func(message string) error {
+			sender, err := c.sender()
+			if err != nil {
+				return err
+			}
+			return sender.SendMessage(c.ChatID, message)
+		}
+	}
+	return announcers, nil
+}
+
+// loadEnv reads the .env into the env. The .env is optional: the secrets can
+// come from the encrypted secrets file or the keyring instead.
+func loadEnv() error {
+	err := godotenv.Load()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read .env: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package send
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadSendConfig(t *testing.T) {
+	t.Setenv("SECRETS_PROVIDERS", "env")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "send.yaml")
+	content := `channels:
+  - name: forrostrasbourg
+    chat_id: "!forro:beeper.local"
+    messages: [digest, remind, weather]
+  - name: special
+    backend: beeper
+    chat_id: "!special:beeper.local"
+    token: env:SPECIAL_BEEPER_TOKEN
+    template: festivals.tmpl
+    categories: [festival, stage]
+digest:
+  empty_week: message
+  empty_week_message: Relâche cette semaine
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "festivals.tmpl"), []byte("Les festivals :\n{{ template \"groups\" . }}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadSendConfig(path)
+	if err != nil {
+		t.Fatalf("loadSendConfig: %v", err)
+	}
+	if len(cfg.Channels) != 2 {
+		t.Fatalf("got %d channels, want 2", len(cfg.Channels))
+	}
+	forro, special := cfg.Channels[0], cfg.Channels[1]
+	if forro.Backend != "beeper" || forro.Token != defaultToken {
+		t.Errorf("forrostrasbourg defaults = %+v", forro)
+	}
+	if strings.Join(special.Messages, ",") != "digest,remind,announce" || !strings.HasPrefix(special.digestTemplate, "Les festivals") {
+		t.Errorf("special = %+v", special)
+	}
+
+	if d := cfg.Digest; d.EmptyWeek != emptyWeekMessage || d.EmptyWeekMessage != "Relâche cette semaine" || d.Upcoming != defaultUpcoming {
+		t.Errorf("digest = %+v", d)
+	}
+
+	var names []string
+	for _, c := range cfg.channels(messageWeather) {
+		names = append(names, c.Name)
+	}
+	if strings.Join(names, ",") != "forrostrasbourg" {
+		t.Errorf("weather channels = %v", names)
+	}
+
+	// The secret of the special channel is missing
+	t.Setenv("BEEPER_ACCESS_TOKEN", "token")
+	errs := cfg.validate()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "secret not found: SPECIAL_BEEPER_TOKEN in env") {
+		t.Errorf("validate = %v", errs)
+	}
+	t.Setenv("SPECIAL_BEEPER_TOKEN", "token")
+	if errs := cfg.validate(); len(errs) != 0 {
+		t.Errorf("validate = %v", errs)
+	}
+}
+
+func TestLoadSendConfigFromEnv(t *testing.T) {
+	t.Setenv("FORROSTRASBOURG_CHAT_GROUP_ID", "chat-1")
+	t.Setenv("SPECIAL_CHAT_GROUP_ID", "chat-2")
+	t.Setenv("SPECIAL_CHAT_CATEGORIES", "festival, stage,")
+
+	cfg, err := loadSendConfig(filepath.Join(t.TempDir(), "send.yaml"))
+	if err != nil {
+		t.Fatalf("loadSendConfig: %v", err)
+	}
+	if len(cfg.Channels) != 2 {
+		t.Fatalf("got %d channels, want 2", len(cfg.Channels))
+	}
+	if c := cfg.Channels[0]; c.ChatID != "chat-1" || len(c.Categories) != 0 || len(cfg.channels(messageWeather)) != 1 {
+		t.Errorf("forrostrasbourg channel = %+v", c)
+	}
+	if c := cfg.Channels[1]; c.ChatID != "chat-2" || strings.Join(c.Categories, ",") != "festival,stage" {
+		t.Errorf("special channel = %+v", c)
+	}
+	if len(cfg.channels(messageAnnounce)) != 2 || cfg.Digest.EmptyWeek != emptyWeekSkip {
+		t.Errorf("config = %+v, want both chats announcing and the empty weeks skipped", cfg)
+	}
+
+	// A chat without an ID is left out
+	t.Setenv("SPECIAL_CHAT_GROUP_ID", "")
+	if cfg := channelsFromEnv(); len(cfg.Channels) != 1 {
+		t.Errorf("channels = %+v, want the forrostrasbourg channel only", cfg.Channels)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Setenv("SECRETS_PROVIDERS", "env")
+	t.Setenv("BEEPER_ACCESS_TOKEN", "token")
+	cfg := sendConfig{Digest: digestConfig{EmptyWeek: "never", Upcoming: -1}, Channels: []channel{
+		{Name: "ok", Backend: "beeper", ChatID: "chat-1", Token: defaultToken, Messages: defaultMessages},
+		{Name: "ok", Backend: "beeper", ChatID: "chat-2", Token: defaultToken},
+		{Backend: "beeper"},
+		{Name: "signal", Backend: "signal", ChatID: "chat-3", Token: defaultToken},
+		{Name: "no-chat", Backend: "beeper", Token: defaultToken},
+		{Name: "bad-token", Backend: "beeper", ChatID: "chat-4", Token: "vault:BEEPER_ACCESS_TOKEN"},
+		{Name: "bad-message", Backend: "beeper", ChatID: "chat-5", Token: defaultToken, Messages: []string{"newsletter"}},
+		{Name: "bad-template", Backend: "beeper", ChatID: "chat-6", Token: defaultToken, digestTemplate: "{{ .Oops"},
+	}}
+
+	var got []string
+	for _, err := range cfg.validate() {
+		got = append(got, err.Error())
+	}
+	want := []string{
+		`digest: unknown empty_week "never", expected one of skip, message, upcoming`,
+		"digest: negative upcoming -1",
+		`duplicate channel "ok"`,
+		"channel 3 has no name",
+		`channel signal: unknown backend "signal", expected one of beeper`,
+		"channel no-chat: no chat_id",
+		`channel bad-token: unknown secret provider "vault" in "vault:BEEPER_ACCESS_TOKEN"`,
+		`channel bad-message: unknown message "newsletter", expected one of digest, remind, weather, announce`,
+	}
+	if len(got) != len(want)+1 || strings.Join(got[:len(want)], "\n") != strings.Join(want, "\n") || !strings.HasPrefix(got[len(want)], "channel bad-template: ") {
+		t.Errorf("validate =\n%s\nwant\n%s\nand the template error", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestChannelEvents(t *testing.T) {
+	events := []digestEvent{
+		{Title: "bal", Category: "bal", Tags: []string{"initiation"}},
+		{Title: "festival", Category: "festival"},
+		{Title: "stage de zabumba", Category: "cours", Tags: []string{"stage"}},
+		{Title: "sans catégorie"},
+	}
+	tests := []struct {
+		name       string
+		categories []string
+		want       string
+	}{
+		{name: "every event", want: "bal,festival,stage de zabumba,sans catégorie"},
+		{name: "festivals and stages", categories: []string{"festival", "stage"}, want: "festival,stage de zabumba"},
+		{name: "by tag", categories: []string{"initiation"}, want: "bal"},
+		{name: "nothing", categories: []string{"ailleurs"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, e := range (channel{Categories: tt.categories}).events(events) {
+			got = append(got, e.Title)
+		}
+		if strings.Join(got, ",") != tt.want {
+			t.Errorf("%s: events = %v, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package send
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// nightOwlHours is how late after midnight an event still belongs to the day
+// it started, e.g. a bal ending at 2h isn't a two-day event.
+const nightOwlHours = 6 * time.Hour
+
+type digestEvent struct {
+	Start time.Time
+	// End is the end of the event, or its start if the page has none.
+	End   time.Time
+	Title string
+	URL   *url.URL
+	// Category and Tags pick the chats the event is announced in.
+	Category string
+	Tags     []string
+}
+
+// multiDay tells whether the event spans several days, like a festival.
+func (e digestEvent) multiDay() bool {
+	return sameDay(e.Start, e.End.Add(-nightOwlHours)) < 0
+}
+
+// sameDay compares the days of a and b: -1 if a is a day before b, 0 if on
+// the same day, 1 after.
+func sameDay(a, b time.Time) int {
+	b = b.In(a.Location())
+	return strings.Compare(a.Format(time.DateOnly), b.Format(time.DateOnly))
+}
+
+// digestGroup is a day of the digest, or a multi-day event.
+type digestGroup struct {
+	// Label is the day, e.g. "Mardi 24/12", or the days of a multi-day
+	// event, e.g. "Du vendredi 14 au dimanche 16/03".
+	Label  string
+	Events []digestItem
+}
+
+// digestItem is an event of a digestGroup.
+type digestItem struct {
+	// Hour is the start time, empty for multi-day events.
+	Hour  string
+	Title string
+	URL   *url.URL
+}
+
+// isoWeek returns the start and end of the ISO week in loc.
+func isoWeek(year, week int, loc *time.Location) (time.Time, time.Time) {
+	// The 4th of January is always in the first week
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, loc)
+	monday := jan4.AddDate(0, 0, -(int(jan4.Weekday())+6)%7+(week-1)*7)
+	return monday, monday.AddDate(0, 0, 7)
+}
+
+// weekEvents returns the events overlapping the week from start to end, by
+// start date: a festival started the week before is still on, a bal ending
+// after midnight on Sunday isn't.
+func weekEvents(events []digestEvent, start, end time.Time) []digestEvent {
+	var inWeek []digestEvent
+	for _, e := range events {
+		if !e.Start.Before(end) || (!e.End.Add(-nightOwlHours).After(start) && e.Start.Before(start)) {
+			continue
+		}
+		inWeek = append(inWeek, e)
+	}
+	sortEvents(inWeek)
+	return inWeek
+}
+
+// upcomingEvents returns the first n events starting from after, by date.
+func upcomingEvents(events []digestEvent, after time.Time, n int) []digestEvent {
+	var upcoming []digestEvent
+	for _, e := range events {
+		if !e.Start.Before(after) {
+			upcoming = append(upcoming, e)
+		}
+	}
+	sortEvents(upcoming)
+	if len(upcoming) > n {
+		upcoming = upcoming[:n]
+	}
+	return upcoming
+}
+
+func sortEvents(events []digestEvent) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Start.Before(events[j].Start)
+	})
+}
+
+// groupEvents groups the sorted events by day in loc. Multi-day events get a
+// group of their own.
+func groupEvents(events []digestEvent, loc *time.Location) []digestGroup {
+	var groups []digestGroup
+	days := map[string]int{}
+	for _, e := range events {
+		start := e.Start.In(loc)
+		if e.multiDay() {
+			groups = append(groups, digestGroup{
+				Label:  rangeLabel(start, e.End.In(loc).Add(-nightOwlHours)),
+				Events: []digestItem{{Title: e.Title, URL: e.URL}},
+			})
+			continue
+		}
+
+		item := digestItem{Hour: start.Format("15h04"), Title: e.Title, URL: e.URL}
+		label := capitalize(frenchWeekDay(start.Weekday())) + " " + start.Format("02/01")
+		if i, ok := days[label]; ok {
+			groups[i].Events = append(groups[i].Events, item)
+			continue
+		}
+		days[label] = len(groups)
+		groups = append(groups, digestGroup{Label: label, Events: []digestItem{item}})
+	}
+	return groups
+}
+
+// rangeLabel is the days of a multi-day event, e.g. "Du vendredi 14 au
+// dimanche 16/03", with both months if they differ.
+func rangeLabel(start, end time.Time) string {
+	from := start.Format("02")
+	if start.Month() != end.Month() {
+		from = start.Format("02/01")
+	}
+	return fmt.Sprintf("Du %s %s au %s %s", frenchWeekDay(start.Weekday()), from, frenchWeekDay(end.Weekday()), end.Format("02/01"))
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,94 @@
+package send
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestISOWeek(t *testing.T) {
+	tests := []struct {
+		year, week int
+		want       string
+	}{
+		{2024, 52, "2024-12-23"},
+		{2025, 1, "2024-12-30"},
+		{2026, 1, "2025-12-29"},
+		{2026, 29, "2026-07-13"},
+	}
+	for _, tt := range tests {
+		start, end := isoWeek(tt.year, tt.week, time.UTC)
+		if got := start.Format(time.DateOnly); got != tt.want {
+			t.Errorf("isoWeek(%d, %d) starts on %s, want %s", tt.year, tt.week, got, tt.want)
+		}
+		if year, week := start.ISOWeek(); year != tt.year || week != tt.week {
+			t.Errorf("isoWeek(%d, %d) starts in week %d of %d", tt.year, tt.week, week, year)
+		}
+		if end.Sub(start) != 7*24*time.Hour || end.Weekday() != time.Monday {
+			t.Errorf("isoWeek(%d, %d) ends on %v", tt.year, tt.week, end)
+		}
+	}
+}
+
+func TestMultiDay(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2025, 3, day, hour, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name       string
+		start, end time.Time
+		want       bool
+	}{
+		{"no end", at(14, 20), at(14, 20), false},
+		{"evening", at(14, 20), at(14, 23), false},
+		{"ends after midnight", at(14, 20), at(15, 2), false},
+		{"festival", at(14, 18), at(16, 23), true},
+		{"ends the next afternoon", at(14, 20), at(15, 14), true},
+	}
+	for _, tt := range tests {
+		if got := (digestEvent{Start: tt.start, End: tt.end}).multiDay(); got != tt.want {
+			t.Errorf("%s: multiDay = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWeekEvents(t *testing.T) {
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2025, month, day, hour, 0, 0, 0, time.UTC)
+	}
+	events := []digestEvent{
+		{Title: "bal", Start: at(3, 18, 19), End: at(3, 18, 23)},
+		{Title: "festival", Start: at(3, 14, 18), End: at(3, 16, 23)},
+		{Title: "stage", Start: at(3, 15, 10), End: at(3, 17, 18)},
+		{Title: "lundi minuit", Start: at(3, 17, 0), End: at(3, 17, 0)},
+		{Title: "semaine d'avant", Start: at(3, 12, 20), End: at(3, 12, 23)},
+		{Title: "bal du dimanche", Start: at(3, 16, 21), End: at(3, 17, 1)},
+		{Title: "semaine d'après", Start: at(3, 24, 0), End: at(3, 24, 2)},
+		{Title: "pratique", Start: at(3, 20, 20), End: at(3, 20, 22)},
+	}
+
+	// The week of monday 17 march
+	start, end := isoWeek(2025, 12, time.UTC)
+	var got []string
+	for _, e := range weekEvents(events, start, end) {
+		got = append(got, e.Title)
+	}
+	if want := "stage,lundi minuit,bal,pratique"; strings.Join(got, ",") != want {
+		t.Errorf("weekEvents = %v, want %s", got, want)
+	}
+}
+
+func TestRangeLabel(t *testing.T) {
+	tests := []struct {
+		start, end time.Time
+		want       string
+	}{
+		{time.Date(2025, 3, 14, 18, 0, 0, 0, time.UTC), time.Date(2025, 3, 16, 17, 0, 0, 0, time.UTC), "Du vendredi 14 au dimanche 16/03"},
+		{time.Date(2025, 2, 28, 18, 0, 0, 0, time.UTC), time.Date(2025, 3, 2, 17, 0, 0, 0, time.UTC), "Du vendredi 28/02 au dimanche 02/03"},
+	}
+	for _, tt := range tests {
+		if got := rangeLabel(tt.start, tt.end); got != tt.want {
+			t.Errorf("rangeLabel = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package send
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+)
+
+const (
+	// defaultLedgerPath is where the sent messages are remembered, not
+	// committed.
+	defaultLedgerPath = ".send-ledger.json"
+
+	// ledgerRetention is how long the sent messages are remembered.
+	ledgerRetention = 90 * 24 * time.Hour
+)
+
+// ledger remembers the messages sent to each chat, so that running a mode
+// again, e.g. when the scheduler catches up, doesn't send them twice.
+type ledger struct {
+	path string
+	// Sent is when a message was sent, by ledgerKey.
+	Sent map[string]time.Time `json:"sent"`
+}
+
+// ledgerKey identifies a message: its kind (digest, remind), what it is
+// about (the week, the event) and the chat.
+func ledgerKey(kind, id, chatID string) string {
+	return kind + " " + id + " " + chatID
+}
+
+// loadLedger reads the ledger at path, forgetting the messages older than
+// ledgerRetention. A missing file is an empty ledger.
+func loadLedger(path string) (*ledger, error) {
+	l := &ledger{path: path, Sent: map[string]time.Time{}}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, l); err != nil {
+		return nil, fmt.Errorf("failed to parse ledger %s: %v", path, err)
+	}
+	if l.Sent == nil {
+		l.Sent = map[string]time.Time{}
+	}
+
+	for key, at := range l.Sent {
+		if time.Since(at) > ledgerRetention {
+			delete(l.Sent, key)
+		}
+	}
+	return l, nil
+}
+
+// sent tells whether the message was already sent to the chat.
+func (l *ledger) sent(kind, id, chatID string) bool {
+	_, ok := l.Sent[ledgerKey(kind, id, chatID)]
+	return ok
+}
+
+// record remembers the message was sent to the chat, and saves the ledger
+// right away so a failure on the next chat doesn't lose it.
+func (l *ledger) record(kind, id, chatID string, at time.Time) error {
+	l.Sent[ledgerKey(kind, id, chatID)] = at
+
+	b, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
+
+// messageSender sends a message to a chat, like beeper.Client.
+type messageSender interface {
+	SendMessage(chatID string, message string) error
+}
+
+// failingSender fails every message with err.
+type failingSender struct {
+	err error
+}
+
+func (s failingSender) SendMessage(chatID string, message string) error {
+	return s.err
+}
+
+// delivery is the outcome of sending a message to a chat.
+type delivery struct {
+	// ID is what the message is about, e.g. the week or the event.
+	ID     string
+	ChatID string
+	// Skipped messages were already sent.
+	Skipped bool
+	Err     error
+}
+
+type deliveries []delivery
+
+// sendOnce sends the message to the chats it wasn't sent to yet. A chat
+// failing doesn't keep the message from the next ones.
+func sendOnce(sender messageSender, l *ledger, kind, id string, chatIDs []string, message string) deliveries {
+	var ds deliveries
+	for _, chatID := range chatIDs {
+		d := delivery{ID: id, ChatID: chatID}
+		if l.sent(kind, id, chatID) {
+			slog.Info("already sent", "kind", kind, "id", id, "chat_id", chatID)
+			d.Skipped = true
+			ds = append(ds, d)
+			continue
+		}
+
+		d.Err = sender.SendMessage(chatID, message)
+		if d.Err == nil {
+			err := l.record(kind, id, chatID, time.Now())
+			if err != nil {
+				d.Err = fmt.Errorf("sent, but failed to save ledger: %v", err)
+			}
+		}
+		if d.Err != nil {
+			slog.Error("send failed", "kind", kind, "id", id, "chat_id", chatID, "error", d.Err)
+		}
+		ds = append(ds, d)
+	}
+	return ds
+}
+
+// summary writes how many messages were sent, skipped and failed, and the
+// failures.
+func (ds deliveries) summary(w io.Writer) {
+	sent, skipped := 0, 0
+	for _, d := range ds {
+		switch {
+		case d.Skipped:
+			skipped++
+		case d.Err == nil:
+			sent++
+		}
+	}
+	fmt.Fprintf(w, "SUMMARY: %d sent, %d already sent, %d failed\n", sent, skipped, len(ds)-sent-skipped)
+	for _, d := range ds {
+		if d.Err != nil {
+			fmt.Fprintf(w, "FAILED: %s to %s: %v\n", d.ID, d.ChatID, d.Err)
+		}
+	}
+}
+
+// err is an error if a message couldn't be sent to a chat.
+func (ds deliveries) err() error {
+	failed := 0
+	for _, d := range ds {
+		if d.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d messages failed", failed, len(ds))
+	}
+	return nil
+}
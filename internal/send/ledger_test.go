@@ -0,0 +1,121 @@
+package send
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSender records the messages sent, and fails for the chats in fail.
+type fakeSender struct {
+	sent []string
+	fail map[string]bool
+}
+
+func (s *fakeSender) SendMessage(chatID string, message string) error {
+	if s.fail[chatID] {
+		return errors.New("chat unavailable")
+	}
+	s.sent = append(s.sent, chatID+": "+message)
+	return nil
+}
+
+func TestLedger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.json")
+
+	l, err := loadLedger(path)
+	if err != nil {
+		t.Fatalf("loadLedger: %v", err)
+	}
+	if l.sent("digest", "2024-W52", "chat-1") {
+		t.Error("empty ledger has a message")
+	}
+
+	if err := l.record("digest", "2024-W52", "chat-1", time.Now()); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	// Long forgotten
+	if err := l.record("remind", "240101-bal", "chat-1", time.Now().Add(-ledgerRetention-time.Hour)); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+
+	l, err = loadLedger(path)
+	if err != nil {
+		t.Fatalf("loadLedger: %v", err)
+	}
+	if !l.sent("digest", "2024-W52", "chat-1") {
+		t.Error("digest not remembered")
+	}
+	if l.sent("digest", "2024-W52", "chat-2") || l.sent("remind", "2024-W52", "chat-1") {
+		t.Error("ledger mixes chats or kinds")
+	}
+	if l.sent("remind", "240101-bal", "chat-1") {
+		t.Error("old message not forgotten")
+	}
+}
+
+func TestLoadLedgerInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.json")
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadLedger(path); err == nil {
+		t.Error("Expected error but got none")
+	}
+}
+
+func TestSendOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.json")
+	l, err := loadLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chatIDs := []string{"chat-1", "chat-2", "chat-3"}
+
+	// The second chat fails, the others get the message
+	sender := &fakeSender{fail: map[string]bool{"chat-2": true}}
+	ds := sendOnce(sender, l, "remind", "241224-bal", chatIDs, "Rappel")
+	if strings.Join(sender.sent, "|") != "chat-1: Rappel|chat-3: Rappel" {
+		t.Errorf("sent = %q", sender.sent)
+	}
+	var out strings.Builder
+	ds.summary(&out)
+	want := "SUMMARY: 2 sent, 0 already sent, 1 failed\nFAILED: 241224-bal to chat-2: chat unavailable\n"
+	if out.String() != want {
+		t.Errorf("summary = %q, want %q", out.String(), want)
+	}
+	if err := ds.err(); err == nil || err.Error() != "1 of 3 messages failed" {
+		t.Errorf("err = %v", err)
+	}
+
+	// Running again only sends to the second chat
+	l, err = loadLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender = &fakeSender{}
+	ds = sendOnce(sender, l, "remind", "241224-bal", chatIDs, "Rappel")
+	if err := ds.err(); err != nil {
+		t.Fatalf("sendOnce: %v", err)
+	}
+	if strings.Join(sender.sent, "|") != "chat-2: Rappel" {
+		t.Errorf("sent = %q", sender.sent)
+	}
+	out.Reset()
+	ds.summary(&out)
+	if out.String() != "SUMMARY: 1 sent, 2 already sent, 0 failed\n" {
+		t.Errorf("summary = %q", out.String())
+	}
+
+	// And then nothing
+	sender = &fakeSender{}
+	if err := sendOnce(sender, l, "remind", "241224-bal", chatIDs, "Rappel").err(); err != nil {
+		t.Fatalf("sendOnce: %v", err)
+	}
+	if len(sender.sent) != 0 {
+		t.Errorf("sent again: %q", sender.sent)
+	}
+}
@@ -0,0 +1,296 @@
+package send
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/event"
+)
+
+const messageTempl = `Bonjour à toutes et tous,
+
+Pour cette semaine, on a :
+{{ template "groups" . }}
+Au plaisir de vous y voir
+`
+
+// upcomingTempl is the digest of an empty week listing the next events.
+const upcomingTempl = `Bonjour à toutes et tous,
+
+Pas d'événement cette semaine, mais à venir :
+{{ template "groups" . }}
+Au plaisir de vous y voir
+`
+
+// groupsTempl lists the events by day.
+const groupsTempl = `{{ define "groups" }}{{ range . }}
+{{ .Label }} :
+{{ range .Events }}- {{ with .Hour }}{{ . }}, {{ end }}{{ .Title }} : {{ .URL }}
+{{ end }}{{ end }}{{ end }}`
+
+// defaultEmptyWeekMessage is the digest of an empty week in the message
+// mode.
+const defaultEmptyWeekMessage = `Bonjour à toutes et tous,
+
+Pas d'événement cette semaine, on se retrouve bientôt !
+L'agenda : https://forrostrasbourg.fr/evenements/
+`
+
+// What the digest does for a week without events.
+const (
+	emptyWeekSkip     = "skip"
+	emptyWeekMessage  = "message"
+	emptyWeekUpcoming = "upcoming"
+)
+
+var emptyWeekModes = []string{emptyWeekSkip, emptyWeekMessage, emptyWeekUpcoming}
+
+// defaultUpcoming is the number of next events listed for an empty week in
+// the upcoming mode.
+const defaultUpcoming = 3
+
+type config struct {
+	channels   []channel
+	send       bool
+	forWeek    int
+	ledgerPath string
+	strict     bool
+	// emptyWeek is what to do without events in the week: emptyWeekSkip,
+	// emptyWeekMessage or emptyWeekUpcoming.
+	emptyWeek        string
+	emptyWeekMessage string
+	upcoming         int
+	// digestTemplate replaces messageTempl if set.
+	digestTemplate string
+}
+
+// loadConfig reads the flags of the digest, and the channels of the
+// configuration.
+func loadConfig(args []string) (config, error) {
+	cfg := config{}
+	err := loadEnv()
+	if err != nil {
+		return cfg, err
+	}
+
+	fs := newFlagSet("", "")
+
+	configPath := fs.String("config", defaultConfigPath, "channels configuration, the env is used if it doesn't exist")
+	fs.BoolVar(&cfg.send, "send", false, "to actually send the message")
+	fs.IntVar(&cfg.forWeek, "for-week", 0, "to change the week this message is for (it's the number of the week of the current year)")
+	fs.StringVar(&cfg.ledgerPath, "ledger", defaultLedgerPath, "file remembering the messages sent, so they are sent once")
+	fs.BoolVar(&cfg.strict, "strict", false, "to fail instead of skipping the event pages that can't be read")
+	fs.StringVar(&cfg.emptyWeek, "empty-week", "", "what to do without events in the week, replacing the digest empty_week of the configuration: skip, message (send -empty-week-message) or upcoming (list the next events)")
+	fs.StringVar(&cfg.emptyWeekMessage, "empty-week-message", "", "message sent for a week without events with -empty-week message, replacing the digest empty_week_message of the configuration")
+	fs.IntVar(&cfg.upcoming, "upcoming", 0, "number of next events listed with -empty-week upcoming, replacing the digest upcoming of the configuration")
+
+	fs.Parse(args)
+
+	if cfg.emptyWeek != "" && !slices.Contains(emptyWeekModes, cfg.emptyWeek) {
+		return cfg, fmt.Errorf("invalid -empty-week %q, expected skip, message or upcoming", cfg.emptyWeek)
+	}
+
+	sendCfg, err := loadSendConfig(*configPath)
+	if err != nil {
+		return cfg, err
+	}
+	if !slices.Contains(emptyWeekModes, sendCfg.Digest.EmptyWeek) {
+		return cfg, fmt.Errorf("send configuration %s: invalid digest empty_week %q, expected skip, message or upcoming", *configPath, sendCfg.Digest.EmptyWeek)
+	}
+	cfg.channels = sendCfg.channels(messageDigest)
+	if cfg.emptyWeek == "" {
+		cfg.emptyWeek = sendCfg.Digest.EmptyWeek
+	}
+	if cfg.emptyWeekMessage == "" {
+		cfg.emptyWeekMessage = sendCfg.Digest.EmptyWeekMessage
+	}
+	if cfg.upcoming == 0 {
+		cfg.upcoming = sendCfg.Digest.Upcoming
+	}
+	return cfg, nil
+}
+
+func run(cfg config) error {
+	slog.Info("run", "channels", len(cfg.channels))
+
+	currentYear, currentWeek := time.Now().Add(24 * time.Hour).UTC().ISOWeek()
+	if cfg.forWeek != 0 {
+		currentWeek = cfg.forWeek
+	}
+	loc, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		return err
+	}
+	weekStart, weekEnd := isoWeek(currentYear, currentWeek, loc)
+
+	venues, err := event.LoadVenues(event.VenuesFile)
+	if err != nil {
+		return fmt.Errorf("failed to load venues: %v", err)
+	}
+	pages, err := loadEvents(venues, cfg.strict, os.Stdout)
+	if err != nil {
+		return err
+	}
+	allEvents := digestEvents(pages)
+
+	events := weekEvents(allEvents, weekStart, weekEnd)
+	fmt.Println("EVENTS:")
+	for _, e := range events {
+		fmt.Printf("- %s %s\n", e.Start.Format(time.DateTime), e.Title)
+	}
+
+	var l *ledger
+	if cfg.send {
+		l, err = loadLedger(cfg.ledgerPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	channels := cfg.channels
+	if len(channels) == 0 {
+		// Still draft the digest of all the events
+		channels = []channel{{Name: "all"}}
+	}
+
+	// Each channel gets the digest of its own events
+	week := fmt.Sprintf("%d-W%02d", currentYear, currentWeek)
+	var ds deliveries
+	for _, c := range channels {
+		chatCfg := cfg
+		chatCfg.digestTemplate = c.digestTemplate
+		if len(c.Categories) > 0 {
+			// Not every week has a festival: don't tell
+			chatCfg.emptyWeek = emptyWeekSkip
+		}
+		message, err := digestMessage(chatCfg, c.events(allEvents), c.events(events), weekEnd, loc)
+		if err != nil {
+			return err
+		}
+		if message == "" {
+			slog.Info("no event this week, not sending", "channel", c.Name)
+			continue
+		}
+		fmt.Printf("MESSAGE for %s:\n%s", c.Name, message)
+
+		if !cfg.send || c.ChatID == "" {
+			continue
+		}
+		sender := c.deliverySender()
+		ds = append(ds, sendOnce(sender, l, "digest", week, []string{c.ChatID}, message)...)
+	}
+
+	if !cfg.send {
+		slog.Info("not sending")
+		return nil
+	}
+	ds.summary(os.Stdout)
+
+	return ds.err()
+}
+
+// digestMessage is the digest of the events of the week ending at weekEnd,
+// or what to send instead for a week without events. It is empty if nothing
+// is to be sent.
+func digestMessage(cfg config, allEvents, events []digestEvent, weekEnd time.Time, loc *time.Location) (string, error) {
+	templ := messageTempl
+	if cfg.digestTemplate != "" {
+		templ = cfg.digestTemplate
+	}
+	if len(events) == 0 {
+		switch cfg.emptyWeek {
+		case emptyWeekMessage:
+			return cfg.emptyWeekMessage, nil
+		case emptyWeekUpcoming:
+			events = upcomingEvents(allEvents, weekEnd, cfg.upcoming)
+			templ = upcomingTempl
+		}
+		if len(events) == 0 {
+			return "", nil
+		}
+	}
+
+	t, err := parseDigestTemplate(templ)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = t.Execute(&buf, groupEvents(events, loc))
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// reportSkipped prints the event pages that couldn't be read, or fails on
+// them if strict.
+func reportSkipped(w io.Writer, skipped []event.Skipped, strict bool) error {
+	for _, sk := range skipped {
+		fmt.Fprintf(w, "SKIPPED: %s: %v\n", sk.Path, sk.Err)
+	}
+	if strict && len(skipped) > 0 {
+		return fmt.Errorf("%d event pages skipped", len(skipped))
+	}
+	return nil
+}
+
+// loadEvents reads the event pages of the site, their venues resolved,
+// skipping the ones that can't be read unless strict.
+func loadEvents(venues event.Venues, strict bool, w io.Writer) ([]event.Event, error) {
+	events, skipped, err := event.LoadDir(event.Dir, venues)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load events: %v", err)
+	}
+	if err := reportSkipped(w, skipped, strict); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// digestEvents returns the events of the pages as told in the digest, with
+// the link to their page.
+func digestEvents(pages []event.Event) []digestEvent {
+	events := make([]digestEvent, 0, len(pages))
+	for _, e := range pages {
+		end := e.EndDate
+		if end.IsZero() {
+			end = e.StartDate
+		}
+		events = append(events, digestEvent{
+			Start:    e.StartDate,
+			End:      end,
+			Title:    e.Title,
+			URL:      &url.URL{Scheme: "https", Host: "forrostrasbourg.fr", Path: "/evenements/" + e.Slug()},
+			Category: e.Category,
+			Tags:     e.Tags,
+		})
+	}
+	return events
+}
+
+func frenchWeekDay(day time.Weekday) string {
+	frenchDays := map[time.Weekday]string{
+		time.Monday:    "lundi",
+		time.Tuesday:   "mardi",
+		time.Wednesday: "mercredi",
+		time.Thursday:  "jeudi",
+		time.Friday:    "vendredi",
+		time.Saturday:  "samedi",
+		time.Sunday:    "dimanche",
+	}
+
+	d, ok := frenchDays[day]
+	if !ok {
+		return "jour"
+	}
+
+	return d
+}
@@ -0,0 +1,211 @@
+package send
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/event"
+)
+
+func TestDigestEvents(t *testing.T) {
+	t.Chdir(t.TempDir())
+	pages := map[string]string{
+		"_index.md":                 "---\ntitle: Les événements\n---\n",
+		"241224-bal.md":             "---\ntitle: Bal\nstartDate: 2024-12-24T19:00:00+01:00\ncategory: bal\ntags: [initiation]\n---\n",
+		"2024/241226-pratique.md":   "---\ntitle: Pratique\nstartDate: 2024-12-26T20:30:00+01:00\n---\n",
+		"241231-reveillon.md":       "---\ntitle: Réveillon\nstartDate: 2024-12-31T20:00:00+01:00\n---\n",
+		"241225-sans-entete.md":     "# Pas de front matter\n",
+		"241225-date-invalide.md":   "---\ntitle: Noël\nstartDate: le 25\n---\n",
+		"241225-yaml-invalide.md":   "---\ntitle: [Noël\n---\n",
+		"templates/bal.md.template": "---\ntitle: {{.Title}}\n---\n",
+		"241224.jpeg":               "not markdown",
+	}
+	for name, content := range pages {
+		path := filepath.Join(event.Dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	loaded, err := loadEvents(nil, false, &out)
+	if err != nil {
+		t.Fatalf("loadEvents: %v", err)
+	}
+	all := digestEvents(loaded)
+	if len(all) != 3 {
+		t.Errorf("got %d events, want 3", len(all))
+	}
+	start, end := isoWeek(2024, 52, time.UTC)
+	events := weekEvents(all, start, end)
+
+	var titles []string
+	for _, e := range events {
+		titles = append(titles, e.Title+" "+e.Start.Format(time.DateTime)+" "+e.URL.String())
+	}
+	want := []string{
+		"Bal 2024-12-24 19:00:00 https://forrostrasbourg.fr/evenements/241224-bal",
+		"Pratique 2024-12-26 20:30:00 https://forrostrasbourg.fr/evenements/241226-pratique",
+	}
+	if strings.Join(titles, "\n") != strings.Join(want, "\n") {
+		t.Errorf("events =\n%s\nwant\n%s", strings.Join(titles, "\n"), strings.Join(want, "\n"))
+	}
+	if events[0].Category != "bal" || strings.Join(events[0].Tags, ",") != "initiation" {
+		t.Errorf("bal category = %q, tags = %v", events[0].Category, events[0].Tags)
+	}
+
+	for _, name := range []string{"241225-date-invalide.md", "241225-sans-entete.md", "241225-yaml-invalide.md"} {
+		if !strings.Contains(out.String(), "SKIPPED: "+filepath.Join(event.Dir, name)+": ") {
+			t.Errorf("%s not skipped:\n%s", name, out.String())
+		}
+	}
+	if n := strings.Count(out.String(), "SKIPPED: "); n != 3 {
+		t.Errorf("%d pages skipped, want 3:\n%s", n, out.String())
+	}
+}
+
+func TestRunMissingDir(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	if err := run(config{}); err == nil {
+		t.Error("Expected error but got none")
+	}
+}
+
+func TestRunStrict(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dir := filepath.Join("content", "evenements")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bad.md"), []byte("---\ntitle: [\n---\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run(config{}); err != nil {
+		t.Errorf("run without -strict: %v", err)
+	}
+	if err := run(config{strict: true}); err == nil || !strings.Contains(err.Error(), "1 event pages skipped") {
+		t.Errorf("run with -strict: expected the skipped pages error, got %v", err)
+	}
+}
+
+func TestDigestMessage(t *testing.T) {
+	event := func(title string, year int, month time.Month, day, hour int) digestEvent {
+		start := time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+		return digestEvent{
+			Start: start,
+			End:   start,
+			Title: title,
+			URL:   &url.URL{Scheme: "https", Host: "forrostrasbourg.fr", Path: "/evenements/" + title},
+		}
+	}
+	festival := event("festival", 2024, 12, 27, 18)
+	festival.End = time.Date(2024, 12, 29, 23, 0, 0, 0, time.UTC)
+	all := []digestEvent{
+		event("passe", 2024, 12, 10, 19),
+		event("pratique", 2024, 12, 26, 20),
+		event("bal", 2024, 12, 24, 19),
+		event("cours", 2024, 12, 24, 18),
+		festival,
+		event("reveillon", 2024, 12, 31, 19),
+		event("galette", 2025, 1, 4, 19),
+		event("bal-janvier", 2025, 1, 7, 19),
+		event("carnaval", 2025, 2, 25, 19),
+	}
+
+	tests := []struct {
+		name string
+		cfg  config
+		year int
+		week int
+		want string
+	}{
+		{
+			name: "week with events",
+			cfg:  config{emptyWeek: emptyWeekSkip},
+			year: 2024,
+			week: 52,
+			want: `Bonjour à toutes et tous,
+
+Pour cette semaine, on a :
+
+Mardi 24/12 :
+- 18h00, cours : https://forrostrasbourg.fr/evenements/cours
+- 19h00, bal : https://forrostrasbourg.fr/evenements/bal
+
+Jeudi 26/12 :
+- 20h00, pratique : https://forrostrasbourg.fr/evenements/pratique
+
+Du vendredi 27 au dimanche 29/12 :
+- festival : https://forrostrasbourg.fr/evenements/festival
+
+Au plaisir de vous y voir
+`,
+		},
+		{
+			name: "channel template",
+			cfg:  config{emptyWeek: emptyWeekSkip, digestTemplate: "Cette semaine :\n{{ template \"groups\" . }}"},
+			year: 2025,
+			week: 1,
+			want: `Cette semaine :
+
+Mardi 31/12 :
+- 19h00, reveillon : https://forrostrasbourg.fr/evenements/reveillon
+
+Samedi 04/01 :
+- 19h00, galette : https://forrostrasbourg.fr/evenements/galette
+`,
+		},
+		{name: "skip", cfg: config{emptyWeek: emptyWeekSkip}, year: 2025, week: 3},
+		{
+			name: "message",
+			cfg:  config{emptyWeek: emptyWeekMessage, emptyWeekMessage: "Relâche cette semaine\n"},
+			year: 2025,
+			week: 3,
+			want: "Relâche cette semaine\n",
+		},
+		{
+			name: "upcoming",
+			cfg:  config{emptyWeek: emptyWeekUpcoming, upcoming: 2},
+			year: 2025,
+			week: 3,
+			want: `Bonjour à toutes et tous,
+
+Pas d'événement cette semaine, mais à venir :
+
+Mardi 25/02 :
+- 19h00, carnaval : https://forrostrasbourg.fr/evenements/carnaval
+
+Au plaisir de vous y voir
+`,
+		},
+		{
+			name: "nothing upcoming",
+			cfg:  config{emptyWeek: emptyWeekUpcoming, upcoming: 2},
+			year: 2025,
+			week: 20,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := isoWeek(tt.year, tt.week, time.UTC)
+			got, err := digestMessage(tt.cfg, all, weekEvents(all, start, end), end, time.UTC)
+			if err != nil {
+				t.Fatalf("digestMessage: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("message = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
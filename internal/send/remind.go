@@ -0,0 +1,124 @@
+package send
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/event"
+)
+
+// reminder is the day-of reminder of an event.
+type reminder struct {
+	Event   event.Event
+	Message string
+}
+
+// runRemind implements the "remind" mode: it drafts or sends a reminder of
+// the events starting within the lead time to the chats of their category,
+// once per event.
+func runRemind(args []string, w io.Writer) error {
+	fs := newFlagSet("remind", "")
+	lead := fs.Duration("lead", 3*time.Hour, "remind the events starting within this time")
+	send := fs.Bool("send", false, "to actually send the reminders")
+	ledgerPath := fs.String("ledger", defaultLedgerPath, "file remembering the messages sent, so they are sent once")
+	configPath := fs.String("config", defaultConfigPath, "channels configuration, the env is used if it doesn't exist")
+	strict := fs.Bool("strict", false, "to fail instead of skipping the event pages that can't be read")
+	fs.Parse(args)
+
+	venues, err := event.LoadVenues(event.VenuesFile)
+	if err != nil {
+		return fmt.Errorf("failed to load venues: %v", err)
+	}
+	events, err := loadEvents(venues, *strict, w)
+	if err != nil {
+		return err
+	}
+
+	loc, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		return err
+	}
+	reminders := dueReminders(events, venues, time.Now(), *lead, loc)
+	if len(reminders) == 0 {
+		fmt.Fprintf(w, "No event starting within %s\n", *lead)
+		return nil
+	}
+	for _, r := range reminders {
+		fmt.Fprintf(w, "EVENT: %s\n", r.Event.Path)
+		fmt.Fprintf(w, "MESSAGE:\n%s\n", r.Message)
+	}
+
+	if !*send {
+		slog.Info("not sending")
+		return nil
+	}
+
+	err = loadEnv()
+	if err != nil {
+		return err
+	}
+	cfg, err := loadSendConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	l, err := loadLedger(*ledgerPath)
+	if err != nil {
+		return err
+	}
+
+	var ds deliveries
+	for _, c := range cfg.channels(messageRemind) {
+		sender := c.deliverySender()
+		for _, r := range reminders {
+			if c.wants(r.Event.Category, r.Event.Tags) {
+				ds = append(ds, sendOnce(sender, l, "remind", r.Event.Slug(), []string{c.ChatID}, r.Message)...)
+			}
+		}
+	}
+	ds.summary(w)
+
+	return ds.err()
+}
+
+// dueReminders returns the reminders of the events starting after now and
+// within lead, by start date, telling the time in loc. Cancelled events
+// aren't reminded.
+func dueReminders(events []event.Event, venues event.Venues, now time.Time, lead time.Duration, loc *time.Location) []reminder {
+	var reminders []reminder
+	for _, e := range events {
+		if e.Cancelled || !e.StartDate.After(now) || e.StartDate.Sub(now) > lead {
+			continue
+		}
+		reminders = append(reminders, reminder{
+			Event:   e,
+			Message: reminderMessage(e, venues, now, loc),
+		})
+	}
+	sort.SliceStable(reminders, func(i, j int) bool {
+		return reminders[i].Event.StartDate.Before(reminders[j].Event.StartDate)
+	})
+	return reminders
+}
+
+// reminderMessage is the reminder of the event: when, where and its link.
+// The time and day are the ones of loc, whatever the zone of now.
+func reminderMessage(e event.Event, venues event.Venues, now time.Time, loc *time.Location) string {
+	start := e.StartDate.In(loc)
+	day := "aujourd'hui"
+	if start.Format(time.DateOnly) != now.In(loc).Format(time.DateOnly) {
+		day = frenchWeekDay(start.Weekday())
+	}
+
+	place := e.Place
+	if v, ok := venues[e.Venue]; ok {
+		place = v.FullAddress()
+	} else if e.City != "" {
+		place += ", " + e.City
+	}
+
+	return fmt.Sprintf("⏰ Rappel : %s, %s à %s\n📍 %s\n%s\n",
+		e.Title, day, start.Format("15h04"), place, "https://forrostrasbourg.fr/evenements/"+e.Slug())
+}
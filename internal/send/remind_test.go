@@ -0,0 +1,147 @@
+package send
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/event"
+)
+
+func TestDueReminders(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2024, 12, day, hour, min, 0, 0, paris)
+	}
+	venues := event.Venues{"kulture": {Name: "La Kulture", Address: "9 rue des Bateliers", PostalCode: "67000", City: "Strasbourg"}}
+	events := []event.Event{
+		{Title: "Bal", StartDate: at(24, 19, 0), Venue: "kulture", Path: "241224-bal.md"},
+		{Title: "Cours", StartDate: at(24, 18, 30), Place: "Foyer", City: "Strasbourg", Path: "241224-cours.md"},
+		{Title: "Annulé", StartDate: at(24, 18, 0), Cancelled: true, Path: "241224-annule.md"},
+		{Title: "Plus tard", StartDate: at(24, 21, 0), Path: "241224-plus-tard.md"},
+		{Title: "Commencé", StartDate: at(24, 15, 0), Path: "241224-commence.md"},
+		{Title: "Minuit", StartDate: at(25, 0, 30), Path: "241225-minuit.md"},
+	}
+
+	reminders := dueReminders(events, venues, at(24, 16, 0), 3*time.Hour, paris)
+	var got []string
+	for _, r := range reminders {
+		got = append(got, r.Event.Path)
+	}
+	if strings.Join(got, ",") != "241224-cours.md,241224-bal.md" {
+		t.Fatalf("reminders = %v", got)
+	}
+	want := "⏰ Rappel : Bal, aujourd'hui à 19h00\n📍 La Kulture, 9 rue des Bateliers, 67000 Strasbourg\nhttps://forrostrasbourg.fr/evenements/241224-bal\n"
+	if reminders[1].Message != want {
+		t.Errorf("message = %q, want %q", reminders[1].Message, want)
+	}
+	if !strings.Contains(reminders[0].Message, "📍 Foyer, Strasbourg\n") {
+		t.Errorf("message = %q", reminders[0].Message)
+	}
+
+	// After midnight, the weekday is given
+	reminders = dueReminders(events, venues, at(24, 22, 0), 3*time.Hour, paris)
+	if len(reminders) != 1 || !strings.HasPrefix(reminders[0].Message, "⏰ Rappel : Minuit, mercredi à 00h30") {
+		t.Errorf("reminders = %+v", reminders)
+	}
+
+	// On a host in UTC, the time is still told in Paris time
+	reminders = dueReminders(events, venues, at(24, 16, 0).UTC(), 3*time.Hour, paris)
+	if len(reminders) != 2 || reminders[1].Message != want {
+		t.Errorf("reminders = %+v", reminders)
+	}
+	// Starting on the 24th in UTC, but the 25th in Paris
+	reminders = dueReminders(events, venues, at(24, 23, 30).UTC(), 3*time.Hour, paris)
+	if len(reminders) != 1 || !strings.HasPrefix(reminders[0].Message, "⏰ Rappel : Minuit, mercredi à 00h30") {
+		t.Errorf("reminders = %+v", reminders)
+	}
+}
+
+func TestRunRemind(t *testing.T) {
+	t.Chdir(t.TempDir())
+	start := time.Now().Add(time.Hour).Truncate(time.Minute)
+	page := "---\ntitle: Bal\nstartDate: " + start.Format(time.RFC3339) + "\nplace: La Kulture\n---\n"
+	if err := os.MkdirAll(event.Dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(event.Dir, "bal.md"), []byte(page), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A broken page doesn't keep the others from being reminded
+	if err := os.WriteFile(filepath.Join(event.Dir, "broken.md"), []byte("---\ntitle: [\n---\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runRemind([]string{"-lead", "30m"}, &out); err != nil {
+		t.Fatalf("runRemind: %v", err)
+	}
+	if !strings.HasSuffix(out.String(), "No event starting within 30m0s\n") || !strings.Contains(out.String(), "SKIPPED: "+filepath.Join(event.Dir, "broken.md")) {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := runRemind([]string{"-lead", "2h"}, &out); err != nil {
+		t.Fatalf("runRemind: %v", err)
+	}
+	if !strings.Contains(out.String(), "EVENT: bal.md\nMESSAGE:\n⏰ Rappel : Bal,") || strings.Contains(out.String(), "MESSAGE SENT") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+
+	if err := runRemind([]string{"-lead", "2h", "-strict"}, &out); err == nil || !strings.Contains(err.Error(), "1 event pages skipped") {
+		t.Errorf("runRemind with -strict: expected the skipped pages error, got %v", err)
+	}
+}
+
+func TestRunRemindChannelWithoutToken(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("SECRETS_PROVIDERS", "env")
+	t.Setenv("BEEPER_ACCESS_TOKEN", "token")
+	start := time.Now().Add(time.Hour).Truncate(time.Minute)
+	page := "---\ntitle: Bal\nstartDate: " + start.Format(time.RFC3339) + "\nplace: La Kulture\n---\n"
+	if err := os.MkdirAll(event.Dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(event.Dir, "bal.md"), []byte(page), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := `channels:
+  - name: special
+    chat_id: chat-1
+    token: env:SPECIAL_BEEPER_TOKEN
+  - name: forrostrasbourg
+    chat_id: chat-2
+`
+	if err := os.WriteFile("send.yaml", []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The channels with a token send through the fake
+	sender := &fakeSender{}
+	origNewSender := newSender
+	defer func() { newSender = origNewSender }()
+	newSender = func(c channel) (messageSender, error) {
+		if _, err := c.sender(); err != nil {
+			return nil, err
+		}
+		return sender, nil
+	}
+
+	var out bytes.Buffer
+	err := runRemind([]string{"-lead", "2h", "-send", "-config", "send.yaml", "-ledger", "ledger.json"}, &out)
+	if err == nil || err.Error() != "1 of 2 messages failed" {
+		t.Errorf("expected the failed message error, got %v", err)
+	}
+	if len(sender.sent) != 1 || !strings.HasPrefix(sender.sent[0], "chat-2: ⏰ Rappel : Bal,") {
+		t.Errorf("sent = %q", sender.sent)
+	}
+	if !strings.Contains(out.String(), "SUMMARY: 1 sent, 0 already sent, 1 failed\nFAILED: bal to chat-1: channel special: ") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
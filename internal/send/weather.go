@@ -0,0 +1,172 @@
+package send
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/event"
+	"github.com/dolanor/forrostrasbourg.fr/internal/weather"
+)
+
+// defaultDuration is how long an outdoor event without an end date is
+// checked for.
+const defaultDuration = 3 * time.Hour
+
+// weatherAnnouncement is the go/no-go of an outdoor event.
+type weatherAnnouncement struct {
+	Event    event.Event
+	Decision weather.Decision
+	Message  string
+}
+
+// runWeatherCheck implements the "weather-check" mode: it checks the forecast
+// of the outdoor events of the day, and drafts or sends their go/no-go
+// announcement to the channels getting the weather messages.
+func runWeatherCheck(args []string, w io.Writer) error {
+	fs := newFlagSet("weather-check", "")
+	date := fs.String("date", time.Now().Format(time.DateOnly), "Day of the events to check (YYYY-MM-DD)")
+	forecastURL := fs.String("forecast-url", weather.DefaultURL, "Open-Meteo compatible forecast endpoint, or a local JSON forecast file")
+	maxProbability := fs.Int("max-rain-probability", weather.DefaultThresholds.MaxPrecipitationProbability, "Cancel above this chance of rain in an hour (%)")
+	maxRain := fs.Float64("max-rain", weather.DefaultThresholds.MaxPrecipitation, "Cancel above this much rain over the event (mm)")
+	minTemperature := fs.Float64("min-temperature", weather.DefaultThresholds.MinTemperature, "Cancel below this temperature (°C)")
+	send := fs.Bool("send", false, "to actually send the announcements")
+	ledgerPath := fs.String("ledger", defaultLedgerPath, "file remembering the messages sent, so they are sent once")
+	configPath := fs.String("config", defaultConfigPath, "channels configuration, the env is used if it doesn't exist")
+	strict := fs.Bool("strict", false, "to fail instead of skipping the event pages that can't be read")
+	fs.Parse(args)
+
+	loc, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		return err
+	}
+	day, err := time.ParseInLocation(time.DateOnly, *date, loc)
+	if err != nil {
+		return fmt.Errorf("invalid -date %q: %v", *date, err)
+	}
+
+	venues, err := event.LoadVenues(event.VenuesFile)
+	if err != nil {
+		return fmt.Errorf("failed to load venues: %v", err)
+	}
+	events, err := loadEvents(venues, *strict, w)
+	if err != nil {
+		return err
+	}
+
+	thresholds := weather.Thresholds{
+		MaxPrecipitationProbability: *maxProbability,
+		MaxPrecipitation:            *maxRain,
+		MinTemperature:              *minTemperature,
+	}
+	announcements, err := weatherAnnouncements(events, venues, day, weather.NewClient(*forecastURL), thresholds)
+	if err != nil {
+		return err
+	}
+	if len(announcements) == 0 {
+		fmt.Fprintf(w, "No outdoor event on %s\n", day.Format(time.DateOnly))
+		return nil
+	}
+
+	for _, a := range announcements {
+		status := "GO"
+		if !a.Decision.Go {
+			status = "CANCEL"
+		}
+		fmt.Fprintf(w, "EVENT: %s: %s\n", a.Event.Path, status)
+		fmt.Fprintf(w, "MESSAGE:\n%s\n", a.Message)
+		if !a.Decision.Go {
+			fmt.Fprintf(w, "Don't forget to set 'cancelled: true' in %s\n", a.Event.Path)
+		}
+	}
+
+	if !*send {
+		slog.Info("not sending")
+		return nil
+	}
+
+	err = loadEnv()
+	if err != nil {
+		return err
+	}
+	cfg, err := loadSendConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	channels := cfg.channels(messageWeather)
+	if len(channels) == 0 {
+		return errors.New("no channel gets the weather messages")
+	}
+
+	l, err := loadLedger(*ledgerPath)
+	if err != nil {
+		return err
+	}
+
+	// The forecast may change between runs: a cancellation is still sent
+	// after a go, but neither twice.
+	var ds deliveries
+	for _, c := range channels {
+		sender := c.deliverySender()
+		for _, a := range announcements {
+			kind := "weather-go"
+			if !a.Decision.Go {
+				kind = "weather-cancel"
+			}
+			ds = append(ds, sendOnce(sender, l, kind, a.Event.Slug(), []string{c.ChatID}, a.Message)...)
+		}
+	}
+	ds.summary(w)
+
+	return ds.err()
+}
+
+// weatherAnnouncements decides the go/no-go of the outdoor events of the day
+// from the forecast at their venue.
+func weatherAnnouncements(events []event.Event, venues event.Venues, day time.Time, client *weather.Client, thresholds weather.Thresholds) ([]weatherAnnouncement, error) {
+	var announcements []weatherAnnouncement
+	for _, e := range events {
+		start := e.StartDate.In(day.Location())
+		if !e.Outdoor || e.Cancelled || start.Format(time.DateOnly) != day.Format(time.DateOnly) {
+			continue
+		}
+		v := venues[e.Venue]
+		if !v.HasCoordinates() {
+			return nil, fmt.Errorf("%s: no coordinates to get the forecast, set the venue and its latitude/longitude in %s", e.Path, event.VenuesFile)
+		}
+
+		forecast, err := client.Fetch(v.Latitude, v.Longitude, day)
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to get the forecast: %v", e.Path, err)
+		}
+		end := e.EndDate
+		if end.IsZero() {
+			end = e.StartDate.Add(defaultDuration)
+		}
+		decision, err := forecast.Decide(e.StartDate, end, thresholds)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", e.Path, err)
+		}
+
+		announcements = append(announcements, weatherAnnouncement{
+			Event:    e,
+			Decision: decision,
+			Message:  weatherMessage(e, decision),
+		})
+	}
+	return announcements, nil
+}
+
+// weatherMessage is the go/no-go announcement of the event.
+func weatherMessage(e event.Event, d weather.Decision) string {
+	url := "https://forrostrasbourg.fr/evenements/" + e.Slug()
+	if !d.Go {
+		return fmt.Sprintf("🌧️ %s de ce soir est annulé à cause de la météo (%s).\nOn se retrouve la prochaine fois !\n%s\n",
+			e.Title, strings.Join(d.Reasons, ", "), url)
+	}
+	return fmt.Sprintf("☀️ %s ce soir à %s : la météo est avec nous, on maintient !\n(%d %% de risque de pluie, %.0f à %.0f °C)\n📍 %s\n%s\n",
+		e.Title, e.StartDate.Format("15h04"), d.PrecipitationProbability, d.MinTemperature, d.MaxTemperature, e.Place, url)
+}
@@ -0,0 +1,136 @@
+package send
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/event"
+)
+
+// rainyForecast is an Open-Meteo forecast of an evening getting rainy at 20h.
+const rainyForecast = `{
+  "timezone": "Europe/Paris",
+  "utc_offset_seconds": 7200,
+  "hourly": {
+    "time": ["2026-07-21T17:00", "2026-07-21T18:00", "2026-07-21T19:00", "2026-07-21T20:00", "2026-07-21T21:00"],
+    "temperature_2m": [24.1, 23.5, 22.0, 20.4, 19.2],
+    "precipitation_probability": [5, 10, 20, 80, 90],
+    "precipitation": [0, 0, 0, 2.5, 3.0]
+  }
+}`
+
+// writeTestSite writes the venues and event pages of a site in the current
+// directory, and the forecast fixture.
+func writeTestSite(t *testing.T) string {
+	t.Helper()
+	files := map[string]string{
+		event.VenuesFile: `quai:
+  address: 36 quai des Bateliers
+  city: Strasbourg
+  latitude: 48.58
+  longitude: 7.7531
+  outdoor: true
+kulture:
+  name: La Kulture
+  city: Strasbourg
+`,
+		filepath.Join(event.Dir, "260721-bal-sauvage.md"): "---\ntitle: Bal sauvage\nstartDate: 2026-07-21T18:30:00+02:00\nendDate: 2026-07-21T22:00:00+02:00\nvenue: quai\n---\n",
+		filepath.Join(event.Dir, "260721-apero.md"):       "---\ntitle: Apéro\nstartDate: 2026-07-21T18:00:00+02:00\nendDate: 2026-07-21T19:30:00+02:00\nvenue: quai\n---\n",
+		filepath.Join(event.Dir, "260721-bal-kulture.md"): "---\ntitle: Bal\nstartDate: 2026-07-21T20:00:00+02:00\nvenue: kulture\n---\n",
+		filepath.Join(event.Dir, "260721-annule.md"):      "---\ntitle: Annulé\nstartDate: 2026-07-21T18:30:00+02:00\nvenue: quai\ncancelled: true\n---\n",
+		filepath.Join(event.Dir, "260728-bal-sauvage.md"): "---\ntitle: Bal sauvage\nstartDate: 2026-07-28T18:30:00+02:00\nvenue: quai\n---\n",
+		filepath.Join(event.Dir, "260722-pique-nique.md"): "---\ntitle: Pique-nique\nstartDate: 2026-07-22T12:00:00+02:00\nplace: Orangerie\noutdoor: true\n---\n",
+		"forecast.json": rainyForecast,
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return "forecast.json"
+}
+
+func TestRunWeatherCheck(t *testing.T) {
+	t.Chdir(t.TempDir())
+	forecast := writeTestSite(t)
+
+	var out bytes.Buffer
+	err := runWeatherCheck([]string{"-date", "2026-07-21", "-forecast-url", forecast}, &out)
+	if err != nil {
+		t.Fatalf("runWeatherCheck: %v", err)
+	}
+
+	got := out.String()
+	for _, want := range []string{
+		"EVENT: 260721-apero.md: GO",
+		"☀️ Apéro ce soir à 18h00 : la météo est avec nous, on maintient !\n(20 % de risque de pluie, 22 à 24 °C)\n📍 36 quai des Bateliers\nhttps://forrostrasbourg.fr/evenements/260721-apero\n",
+		"EVENT: 260721-bal-sauvage.md: CANCEL",
+		"🌧️ Bal sauvage de ce soir est annulé à cause de la météo (90 % de risque de pluie, 5.5 mm de pluie prévus).",
+		"Don't forget to set 'cancelled: true' in 260721-bal-sauvage.md",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output doesn't contain %q:\n%s", want, got)
+		}
+	}
+	for _, notWant := range []string{"260721-bal-kulture", "260721-annule", "260728-bal-sauvage", "MESSAGE SENT"} {
+		if strings.Contains(got, notWant) {
+			t.Errorf("output contains %q:\n%s", notWant, got)
+		}
+	}
+
+	// With higher thresholds, the bal goes on
+	out.Reset()
+	err = runWeatherCheck([]string{"-date", "2026-07-21", "-forecast-url", forecast, "-max-rain-probability", "95", "-max-rain", "10"}, &out)
+	if err != nil {
+		t.Fatalf("runWeatherCheck: %v", err)
+	}
+	if !strings.Contains(out.String(), "EVENT: 260721-bal-sauvage.md: GO") {
+		t.Errorf("bal cancelled despite the thresholds:\n%s", out.String())
+	}
+}
+
+func TestRunWeatherCheckErrors(t *testing.T) {
+	t.Chdir(t.TempDir())
+	forecast := writeTestSite(t)
+
+	tests := []struct {
+		name        string
+		args        []string
+		errContains string
+	}{
+		{name: "invalid date", args: []string{"-date", "21/07"}, errContains: "invalid -date"},
+		{name: "no coordinates", args: []string{"-date", "2026-07-22", "-forecast-url", forecast}, errContains: "260722-pique-nique.md: no coordinates"},
+		{name: "no forecast file", args: []string{"-date", "2026-07-21", "-forecast-url", "missing.json"}, errContains: "failed to get the forecast"},
+		{name: "no forecast of the day", args: []string{"-date", "2026-07-28", "-forecast-url", forecast}, errContains: "no forecast from"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := runWeatherCheck(tt.args, &out)
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("expected error containing %q, got %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestRunWeatherCheckNoEvent(t *testing.T) {
+	t.Chdir(t.TempDir())
+	forecast := writeTestSite(t)
+
+	var out bytes.Buffer
+	err := runWeatherCheck([]string{"-date", "2026-07-23", "-forecast-url", forecast}, &out)
+	if err != nil {
+		t.Fatalf("runWeatherCheck: %v", err)
+	}
+	if got := out.String(); got != "No outdoor event on 2026-07-23\n" {
+		t.Errorf("output = %q", got)
+	}
+}
@@ -0,0 +1,244 @@
+// Package staticmap draws map images around a point from OpenStreetMap
+// tiles cached on disk, so the site never calls a map service when it is
+// built or browsed.
+package staticmap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TileSize is the size of the tiles in pixels.
+const TileSize = 256
+
+// DefaultTileURL is the OpenStreetMap tile server. Its usage policy asks to
+// identify the application and to cache the tiles.
+const DefaultTileURL = "https://tile.openstreetmap.org/{z}/{x}/{y}.png"
+
+// Attribution must be shown with the maps.
+const Attribution = "© OpenStreetMap contributors"
+
+// Tile is a tile of the slippy map.
+type Tile struct {
+	Z, X, Y int
+}
+
+// Path is where the tile is cached in the tiles directory, e.g. 16/34164/22901.png.
+func (t Tile) Path() string {
+	return filepath.Join(fmt.Sprint(t.Z), fmt.Sprint(t.X), fmt.Sprint(t.Y)+".png")
+}
+
+// Map is a map image centered on a point, with a marker on it.
+type Map struct {
+	Latitude, Longitude float64
+	Zoom                int
+	Width, Height       int
+}
+
+// pixel returns the position of the point in the world image at the zoom
+// level, in pixels.
+func (m Map) pixel() (x, y float64) {
+	n := math.Exp2(float64(m.Zoom)) * TileSize
+	lat := m.Latitude * math.Pi / 180
+	x = (m.Longitude + 180) / 360 * n
+	y = (1 - math.Log(math.Tan(lat)+1/math.Cos(lat))/math.Pi) / 2 * n
+	return x, y
+}
+
+// bounds is the area of the world image the map shows.
+func (m Map) bounds() image.Rectangle {
+	x, y := m.pixel()
+	min := image.Pt(int(math.Round(x))-m.Width/2, int(math.Round(y))-m.Height/2)
+	return image.Rectangle{Min: min, Max: min.Add(image.Pt(m.Width, m.Height))}
+}
+
+func (m Map) validate() error {
+	switch {
+	case m.Latitude < -85 || m.Latitude > 85 || m.Longitude < -180 || m.Longitude > 180:
+		return fmt.Errorf("invalid coordinates %v, %v", m.Latitude, m.Longitude)
+	case m.Zoom < 0 || m.Zoom > 19:
+		return fmt.Errorf("invalid zoom %d", m.Zoom)
+	case m.Width <= 0 || m.Height <= 0:
+		return fmt.Errorf("invalid size %dx%d", m.Width, m.Height)
+	}
+	return nil
+}
+
+// Tiles returns the tiles the map is drawn from.
+func (m Map) Tiles() []Tile {
+	b := m.bounds()
+	n := 1 << m.Zoom
+	var tiles []Tile
+	for y := floorDiv(b.Min.Y, TileSize); y <= floorDiv(b.Max.Y-1, TileSize); y++ {
+		if y < 0 || y >= n {
+			continue
+		}
+		for x := floorDiv(b.Min.X, TileSize); x <= floorDiv(b.Max.X-1, TileSize); x++ {
+			// The world wraps around horizontally
+			tiles = append(tiles, Tile{Z: m.Zoom, X: ((x % n) + n) % n, Y: y})
+		}
+	}
+	return tiles
+}
+
+func floorDiv(a, b int) int {
+	return int(math.Floor(float64(a) / float64(b)))
+}
+
+// Render draws the map from the tiles cached in dir. Missing tiles are an
+// error listing them all, see Fetch.
+func (m Map) Render(dir string) (image.Image, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+
+	b := m.bounds()
+	img := image.NewRGBA(image.Rect(0, 0, m.Width, m.Height))
+	n := 1 << m.Zoom
+
+	var missing []string
+	for ty := floorDiv(b.Min.Y, TileSize); ty <= floorDiv(b.Max.Y-1, TileSize); ty++ {
+		for tx := floorDiv(b.Min.X, TileSize); tx <= floorDiv(b.Max.X-1, TileSize); tx++ {
+			// Where the tile goes on the map
+			at := image.Pt(tx*TileSize, ty*TileSize).Sub(b.Min)
+			r := image.Rectangle{Min: at, Max: at.Add(image.Pt(TileSize, TileSize))}
+			if ty < 0 || ty >= n {
+				draw.Draw(img, r, image.NewUniform(color.White), image.Point{}, draw.Src)
+				continue
+			}
+
+			t := Tile{Z: m.Zoom, X: ((tx % n) + n) % n, Y: ty}
+			tile, err := readTile(filepath.Join(dir, t.Path()))
+			if os.IsNotExist(err) {
+				missing = append(missing, t.Path())
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("tile %s: %v", t.Path(), err)
+			}
+			draw.Draw(img, r, tile, tile.Bounds().Min, draw.Src)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing tiles in %s: %s", dir, strings.Join(missing, ", "))
+	}
+
+	drawMarker(img, image.Pt(m.Width/2, m.Height/2))
+	return img, nil
+}
+
+func readTile(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+// Marker colors, the red of the site
+var (
+	markerColor  = color.RGBA{R: 0xd9, G: 0x3b, B: 0x2b, A: 0xff}
+	markerBorder = color.White
+)
+
+// drawMarker draws a dot with a white border on the point.
+func drawMarker(img draw.Image, at image.Point) {
+	const radius, border = 9, 3
+	for y := -radius - border; y <= radius+border; y++ {
+		for x := -radius - border; x <= radius+border; x++ {
+			d := math.Hypot(float64(x), float64(y))
+			switch {
+			case d <= radius:
+				img.Set(at.X+x, at.Y+y, markerColor)
+			case d <= radius+border:
+				img.Set(at.X+x, at.Y+y, markerBorder)
+			}
+		}
+	}
+}
+
+// WritePNG renders the map and writes it as PNG.
+func (m Map) WritePNG(w io.Writer, dir string) error {
+	img, err := m.Render(dir)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// Fetch downloads the tiles of the map missing from dir, from the tile
+// server at urlTemplate (see DefaultTileURL). userAgent identifies the
+// application, as the OpenStreetMap usage policy requires.
+func (m Map) Fetch(client *http.Client, urlTemplate, userAgent, dir string) (int, error) {
+	if err := m.validate(); err != nil {
+		return 0, err
+	}
+
+	fetched := 0
+	var errs []error
+	for _, t := range m.Tiles() {
+		path := filepath.Join(dir, t.Path())
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		if err := fetchTile(client, urlTemplate, userAgent, t, path); err != nil {
+			errs = append(errs, fmt.Errorf("tile %s: %v", t.Path(), err))
+			continue
+		}
+		fetched++
+	}
+	return fetched, errors.Join(errs...)
+}
+
+func fetchTile(client *http.Client, urlTemplate, userAgent string, t Tile, path string) error {
+	url := strings.NewReplacer(
+		"{z}", fmt.Sprint(t.Z),
+		"{x}", fmt.Sprint(t.X),
+		"{y}", fmt.Sprint(t.Y),
+	).Replace(urlTemplate)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s from %s", resp.Status, url)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	// Don't cache error pages as tiles
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("%s is not a PNG image: %v", url, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// OSMURL is the link to the point on openstreetmap.org.
+func OSMURL(latitude, longitude float64, zoom int) string {
+	return fmt.Sprintf("https://www.openstreetmap.org/?mlat=%.5f&mlon=%.5f#map=%d/%.5f/%.5f", latitude, longitude, zoom, latitude, longitude)
+}
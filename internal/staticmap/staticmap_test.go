@@ -0,0 +1,236 @@
+package staticmap
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTile writes a tile of a single color in dir.
+func writeTile(t *testing.T, dir string, tile Tile, c color.Color) {
+	t.Helper()
+	path := filepath.Join(dir, tile.Path())
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, tilePNG(t, c), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func tilePNG(t *testing.T, c color.Color) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, TileSize, TileSize))
+	for y := 0; y < TileSize; y++ {
+		for x := 0; x < TileSize; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+var (
+	red    = color.RGBA{R: 0xff, A: 0xff}
+	green  = color.RGBA{G: 0xff, A: 0xff}
+	blue   = color.RGBA{B: 0xff, A: 0xff}
+	yellow = color.RGBA{R: 0xff, G: 0xff, A: 0xff}
+)
+
+func TestTiles(t *testing.T) {
+	tests := []struct {
+		name string
+		m    Map
+		want []Tile
+	}{
+		{
+			// The center of the world is the corner of the 4 tiles of zoom 1
+			name: "four tiles",
+			m:    Map{Latitude: 0, Longitude: 0, Zoom: 1, Width: 100, Height: 100},
+			want: []Tile{{1, 0, 0}, {1, 1, 0}, {1, 0, 1}, {1, 1, 1}},
+		},
+		{
+			name: "inside one tile",
+			m:    Map{Latitude: 40, Longitude: -90, Zoom: 1, Width: 10, Height: 10},
+			want: []Tile{{1, 0, 0}},
+		},
+		{
+			name: "wraps around the antimeridian",
+			m:    Map{Latitude: 40, Longitude: 179.9, Zoom: 1, Width: 100, Height: 10},
+			want: []Tile{{1, 1, 0}, {1, 0, 0}},
+		},
+		{
+			name: "Strasbourg",
+			m:    Map{Latitude: 48.5806, Longitude: 7.7527, Zoom: 16, Width: 600, Height: 300},
+			want: []Tile{
+				{16, 34178, 22621}, {16, 34179, 22621}, {16, 34180, 22621},
+				{16, 34178, 22622}, {16, 34179, 22622}, {16, 34180, 22622},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.m.Tiles(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tiles = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	dir := t.TempDir()
+	writeTile(t, dir, Tile{1, 0, 0}, red)
+	writeTile(t, dir, Tile{1, 1, 0}, green)
+	writeTile(t, dir, Tile{1, 0, 1}, blue)
+	writeTile(t, dir, Tile{1, 1, 1}, yellow)
+
+	m := Map{Latitude: 0, Longitude: 0, Zoom: 1, Width: 100, Height: 80}
+	img, err := m.Render(dir)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 100, 80) {
+		t.Fatalf("Bounds = %v", img.Bounds())
+	}
+
+	tests := []struct {
+		x, y int
+		want color.Color
+	}{
+		{0, 0, red},
+		{99, 0, green},
+		{0, 79, blue},
+		{99, 79, yellow},
+		{50, 40, markerColor},
+		{50 + 10, 40, markerBorder},
+	}
+	for _, tt := range tests {
+		r1, g1, b1, a1 := img.At(tt.x, tt.y).RGBA()
+		r2, g2, b2, a2 := tt.want.RGBA()
+		if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+			t.Errorf("pixel %d,%d = %v, want %v", tt.x, tt.y, img.At(tt.x, tt.y), tt.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := m.WritePNG(&buf, dir); err != nil {
+		t.Fatalf("WritePNG: %v", err)
+	}
+	if _, err := png.Decode(&buf); err != nil {
+		t.Errorf("WritePNG didn't write a PNG: %v", err)
+	}
+}
+
+func TestRenderMissingTiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTile(t, dir, Tile{1, 0, 0}, red)
+
+	_, err := Map{Latitude: 0, Longitude: 0, Zoom: 1, Width: 100, Height: 100}.Render(dir)
+	if err == nil {
+		t.Fatal("Expected error but got none")
+	}
+	for _, want := range []string{Tile{1, 1, 0}.Path(), Tile{1, 0, 1}.Path(), Tile{1, 1, 1}.Path()} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error doesn't list %s: %v", want, err)
+		}
+	}
+}
+
+func TestRenderInvalid(t *testing.T) {
+	for _, m := range []Map{
+		{Latitude: 91, Zoom: 1, Width: 10, Height: 10},
+		{Zoom: 20, Width: 10, Height: 10},
+		{Zoom: 1},
+	} {
+		if _, err := m.Render(t.TempDir()); err == nil {
+			t.Errorf("Render(%+v): expected error but got none", m)
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		if r.Header.Get("User-Agent") != "test-agent" {
+			http.Error(w, "identify yourself", http.StatusForbidden)
+			return
+		}
+		w.Write(tilePNG(t, green))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	// Already cached
+	writeTile(t, dir, Tile{1, 0, 0}, red)
+
+	m := Map{Latitude: 0, Longitude: 0, Zoom: 1, Width: 100, Height: 100}
+	n, err := m.Fetch(server.Client(), server.URL+"/{z}/{x}/{y}.png", "test-agent", dir)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("fetched %d tiles, want 3", n)
+	}
+	want := []string{"/1/1/0.png", "/1/0/1.png", "/1/1/1.png"}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+	if _, err := m.Render(dir); err != nil {
+		t.Errorf("Render after Fetch: %v", err)
+	}
+
+	// Everything is cached now
+	n, err = m.Fetch(server.Client(), server.URL+"/{z}/{x}/{y}.png", "test-agent", dir)
+	if err != nil || n != 0 {
+		t.Errorf("second Fetch = %d, %v, want 0, nil", n, err)
+	}
+}
+
+func TestFetchErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/1/0/") {
+			w.Write([]byte("<html>rate limited</html>"))
+			return
+		}
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	m := Map{Latitude: 0, Longitude: 0, Zoom: 1, Width: 100, Height: 100}
+	n, err := m.Fetch(server.Client(), server.URL+"/{z}/{x}/{y}.png", "test-agent", dir)
+	if err == nil {
+		t.Fatal("Expected error but got none")
+	}
+	if n != 0 {
+		t.Errorf("fetched %d tiles, want 0", n)
+	}
+	if !strings.Contains(err.Error(), "is not a PNG image") || !strings.Contains(err.Error(), "404") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	// Nothing is cached
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("cached %v", entries)
+	}
+}
+
+func TestOSMURL(t *testing.T) {
+	got := OSMURL(48.5806, 7.7527, 17)
+	want := "https://www.openstreetmap.org/?mlat=48.58060&mlon=7.75270#map=17/48.58060/7.75270"
+	if got != want {
+		t.Errorf("OSMURL = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,217 @@
+// Package weather reads hourly forecasts from an Open-Meteo compatible
+// endpoint and decides whether an outdoor event can go on.
+package weather
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultURL is the Open-Meteo forecast endpoint.
+const DefaultURL = "https://api.open-meteo.com/v1/forecast"
+
+// hourlyVariables are the hourly variables requested from the endpoint.
+const hourlyVariables = "temperature_2m,precipitation_probability,precipitation"
+
+// Hour is the forecast of an hour.
+type Hour struct {
+	Time time.Time
+	// Temperature in °C.
+	Temperature float64
+	// PrecipitationProbability in %.
+	PrecipitationProbability int
+	// Precipitation in mm.
+	Precipitation float64
+}
+
+// Forecast is an hourly forecast, sorted by time.
+type Forecast []Hour
+
+// Client reads forecasts from URL, either an Open-Meteo compatible endpoint
+// or a local JSON file (a path or a file:// URL) with the same content, e.g.
+// to test with a fixture.
+type Client struct {
+	URL        string
+	HTTPClient *http.Client
+}
+
+// NewClient returns a client for the forecast endpoint or file at u.
+func NewClient(u string) *Client {
+	return &Client{
+		URL:        u,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// Fetch reads the hourly forecast of the day at the coordinates. Files are
+// read as is, whatever the coordinates and day.
+func (c *Client) Fetch(latitude, longitude float64, day time.Time) (Forecast, error) {
+	if path, ok := c.file(); ok {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return Decode(f)
+	}
+
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid forecast URL: %v", err)
+	}
+	q := u.Query()
+	q.Set("latitude", fmt.Sprintf("%.4f", latitude))
+	q.Set("longitude", fmt.Sprintf("%.4f", longitude))
+	q.Set("hourly", hourlyVariables)
+	q.Set("timezone", day.Location().String())
+	q.Set("start_date", day.Format(time.DateOnly))
+	q.Set("end_date", day.Format(time.DateOnly))
+	u.RawQuery = q.Encode()
+
+	resp, err := c.HTTPClient.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("unexpected status: %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return Decode(resp.Body)
+}
+
+// file is the path of the forecast file, if the client reads one.
+func (c *Client) file() (string, bool) {
+	if path, ok := strings.CutPrefix(c.URL, "file://"); ok {
+		return path, true
+	}
+	return c.URL, !strings.HasPrefix(c.URL, "http://") && !strings.HasPrefix(c.URL, "https://")
+}
+
+// response is the part of an Open-Meteo forecast we use.
+type response struct {
+	Timezone         string `json:"timezone"`
+	UTCOffsetSeconds int    `json:"utc_offset_seconds"`
+	Hourly           struct {
+		Time                     []string   `json:"time"`
+		Temperature              []*float64 `json:"temperature_2m"`
+		PrecipitationProbability []*int     `json:"precipitation_probability"`
+		Precipitation            []*float64 `json:"precipitation"`
+	} `json:"hourly"`
+	// Error and Reason are set on invalid requests.
+	Error  bool   `json:"error"`
+	Reason string `json:"reason"`
+}
+
+// Decode reads an Open-Meteo forecast. Hours missing a value are skipped.
+func Decode(r io.Reader) (Forecast, error) {
+	var resp response
+	if err := json.NewDecoder(r).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to read forecast: %v", err)
+	}
+	if resp.Error {
+		return nil, fmt.Errorf("forecast error: %s", resp.Reason)
+	}
+
+	h := resp.Hourly
+	if len(h.Temperature) != len(h.Time) || len(h.PrecipitationProbability) != len(h.Time) || len(h.Precipitation) != len(h.Time) {
+		return nil, fmt.Errorf("forecast without the hourly %s", hourlyVariables)
+	}
+
+	loc, err := time.LoadLocation(resp.Timezone)
+	if err != nil || resp.Timezone == "" {
+		loc = time.FixedZone(resp.Timezone, resp.UTCOffsetSeconds)
+	}
+
+	var forecast Forecast
+	for i, s := range h.Time {
+		t, err := time.ParseInLocation("2006-01-02T15:04", s, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid forecast time %q: %v", s, err)
+		}
+		if h.Temperature[i] == nil || h.PrecipitationProbability[i] == nil || h.Precipitation[i] == nil {
+			continue
+		}
+		forecast = append(forecast, Hour{
+			Time:                     t,
+			Temperature:              *h.Temperature[i],
+			PrecipitationProbability: *h.PrecipitationProbability[i],
+			Precipitation:            *h.Precipitation[i],
+		})
+	}
+	return forecast, nil
+}
+
+// Thresholds are the weather an outdoor event is cancelled for.
+type Thresholds struct {
+	// MaxPrecipitationProbability is the highest chance of rain in an hour,
+	// in %.
+	MaxPrecipitationProbability int
+	// MaxPrecipitation is the most rain over the event, in mm.
+	MaxPrecipitation float64
+	// MinTemperature is the coldest it can get, in °C.
+	MinTemperature float64
+}
+
+// DefaultThresholds are the usual limits for a bal sauvage.
+var DefaultThresholds = Thresholds{
+	MaxPrecipitationProbability: 60,
+	MaxPrecipitation:            1,
+	MinTemperature:              12,
+}
+
+// Decision is the go/no-go of an outdoor event.
+type Decision struct {
+	Go bool
+	// Reasons the event is cancelled for, in French for the announcement.
+	Reasons []string
+
+	// The weather over the event.
+	PrecipitationProbability int
+	Precipitation            float64
+	MinTemperature           float64
+	MaxTemperature           float64
+}
+
+// Decide applies the thresholds to the hours of the forecast overlapping
+// the event from start to end.
+func (f Forecast) Decide(start, end time.Time, th Thresholds) (Decision, error) {
+	d := Decision{Go: true}
+	hours := 0
+	for _, h := range f {
+		if !h.Time.Add(time.Hour).After(start) || !h.Time.Before(end) {
+			continue
+		}
+		if hours == 0 || h.Temperature < d.MinTemperature {
+			d.MinTemperature = h.Temperature
+		}
+		if hours == 0 || h.Temperature > d.MaxTemperature {
+			d.MaxTemperature = h.Temperature
+		}
+		d.PrecipitationProbability = max(d.PrecipitationProbability, h.PrecipitationProbability)
+		d.Precipitation += h.Precipitation
+		hours++
+	}
+	if hours == 0 {
+		return d, fmt.Errorf("no forecast from %s to %s", start.Format(time.DateTime), end.Format(time.DateTime))
+	}
+
+	if d.PrecipitationProbability > th.MaxPrecipitationProbability {
+		d.Reasons = append(d.Reasons, fmt.Sprintf("%d %% de risque de pluie", d.PrecipitationProbability))
+	}
+	if d.Precipitation > th.MaxPrecipitation {
+		d.Reasons = append(d.Reasons, fmt.Sprintf("%.1f mm de pluie prévus", d.Precipitation))
+	}
+	if d.MinTemperature < th.MinTemperature {
+		d.Reasons = append(d.Reasons, fmt.Sprintf("%.0f °C au plus froid", d.MinTemperature))
+	}
+	d.Go = len(d.Reasons) == 0
+	return d, nil
+}
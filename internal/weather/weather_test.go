@@ -0,0 +1,197 @@
+package weather
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// forecastJSON is an Open-Meteo forecast of a rainy evening.
+const forecastJSON = `{
+  "latitude": 48.58,
+  "longitude": 7.75,
+  "timezone": "Europe/Paris",
+  "utc_offset_seconds": 7200,
+  "hourly": {
+    "time": ["2026-07-21T17:00", "2026-07-21T18:00", "2026-07-21T19:00", "2026-07-21T20:00", "2026-07-21T21:00", "2026-07-21T22:00"],
+    "temperature_2m": [24.1, 23.5, 22.0, 20.4, 19.2, 18.0],
+    "precipitation_probability": [5, 10, 20, 70, 40, 90],
+    "precipitation": [0, 0, 0.2, 1.4, 0.3, 3.0]
+  }
+}`
+
+func paris(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestDecode(t *testing.T) {
+	f, err := Decode(strings.NewReader(forecastJSON))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(f) != 6 {
+		t.Fatalf("got %d hours, want 6", len(f))
+	}
+	want := Hour{Time: time.Date(2026, 7, 21, 20, 0, 0, 0, paris(t)), Temperature: 20.4, PrecipitationProbability: 70, Precipitation: 1.4}
+	if got := f[3]; !got.Time.Equal(want.Time) || got.Temperature != want.Temperature || got.PrecipitationProbability != want.PrecipitationProbability || got.Precipitation != want.Precipitation {
+		t.Errorf("hour 3 = %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		errContains string
+	}{
+		{name: "not JSON", content: "<html>", errContains: "failed to read forecast"},
+		{name: "error", content: `{"error": true, "reason": "Latitude must be in range of -90 to 90°."}`, errContains: "Latitude must be"},
+		{name: "missing variable", content: `{"hourly": {"time": ["2026-07-21T17:00"], "temperature_2m": [20]}}`, errContains: "forecast without the hourly"},
+		{name: "invalid time", content: `{"hourly": {"time": ["17h"], "temperature_2m": [20], "precipitation_probability": [0], "precipitation": [0]}}`, errContains: "invalid forecast time"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(strings.NewReader(tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("expected error containing %q, got %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestFetch(t *testing.T) {
+	var query map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = map[string]string{}
+		for k := range r.URL.Query() {
+			query[k] = r.URL.Query().Get(k)
+		}
+		w.Write([]byte(forecastJSON))
+	}))
+	defer server.Close()
+
+	day := time.Date(2026, 7, 21, 0, 0, 0, 0, paris(t))
+	f, err := NewClient(server.URL+"/v1/forecast").Fetch(48.58, 7.7531, day)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(f) != 6 {
+		t.Errorf("got %d hours, want 6", len(f))
+	}
+
+	want := map[string]string{
+		"latitude":   "48.5800",
+		"longitude":  "7.7531",
+		"hourly":     hourlyVariables,
+		"timezone":   "Europe/Paris",
+		"start_date": "2026-07-21",
+		"end_date":   "2026-07-21",
+	}
+	for k, v := range want {
+		if query[k] != v {
+			t.Errorf("%s = %q, want %q", k, query[k], v)
+		}
+	}
+}
+
+func TestFetchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "forecast.json")
+	if err := os.WriteFile(path, []byte(forecastJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, u := range []string{path, "file://" + path} {
+		f, err := NewClient(u).Fetch(0, 0, time.Now())
+		if err != nil {
+			t.Errorf("Fetch(%s): %v", u, err)
+			continue
+		}
+		if len(f) != 6 {
+			t.Errorf("Fetch(%s): got %d hours, want 6", u, len(f))
+		}
+	}
+}
+
+func TestFetchStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	_, err := NewClient(server.URL).Fetch(48.58, 7.75, time.Now())
+	if err == nil || !strings.Contains(err.Error(), "unexpected status: 429") {
+		t.Errorf("expected a status error, got %v", err)
+	}
+}
+
+func TestDecide(t *testing.T) {
+	f, err := Decode(strings.NewReader(forecastJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(hour, min int) time.Time {
+		return time.Date(2026, 7, 21, hour, min, 0, 0, paris(t))
+	}
+
+	tests := []struct {
+		name        string
+		start, end  time.Time
+		thresholds  Thresholds
+		wantGo      bool
+		wantReasons []string
+		wantErr     bool
+	}{
+		{
+			name:  "dry before the rain",
+			start: at(18, 30), end: at(20, 0),
+			thresholds: DefaultThresholds,
+			wantGo:     true,
+		},
+		{
+			name:  "rain",
+			start: at(18, 30), end: at(22, 0),
+			thresholds:  DefaultThresholds,
+			wantReasons: []string{"70 % de risque de pluie", "1.9 mm de pluie prévus"},
+		},
+		{
+			name:  "cold",
+			start: at(18, 30), end: at(20, 0),
+			thresholds:  Thresholds{MaxPrecipitationProbability: 100, MaxPrecipitation: 10, MinTemperature: 23},
+			wantReasons: []string{"22 °C au plus froid"},
+		},
+		{
+			name:  "no forecast",
+			start: at(23, 0), end: at(23, 59),
+			thresholds: DefaultThresholds,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := f.Decide(tt.start, tt.end, tt.thresholds)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if d.Go != tt.wantGo || strings.Join(d.Reasons, "; ") != strings.Join(tt.wantReasons, "; ") {
+				t.Errorf("Decide = %v %q, want %v %q", d.Go, d.Reasons, tt.wantGo, tt.wantReasons)
+			}
+		})
+	}
+}
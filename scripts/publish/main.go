@@ -0,0 +1,16 @@
+// Command publish publishes the events of the site. It is kept for the
+// existing scripts, forro runs the same commands.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/dolanor/forrostrasbourg.fr/internal/publish"
+)
+
+func main() {
+	if err := publish.Run(os.Args[1:], os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}